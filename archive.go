@@ -0,0 +1,204 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// archives holds the Go files read from archives given on the command
+// line, keyed by their paths within the archives, like pkg/a.go, or
+// those joined to the archive names, like src.tar/pkg/a.go, if other
+// paths are given too, and the source read from stdin.
+var archives = &archiveStore{
+	files: make(map[string][]byte),
+	from:  make(map[string]string),
+}
+
+type archiveStore struct {
+	mu    sync.RWMutex
+	files map[string][]byte
+	from  map[string]string // archive the file was read from, or "-"
+}
+
+// sources caches the files read from disk, so that each is read at most
+// once per scan, when parsed, however many fragments the printers and
+// filters later find in it.
+var sources = struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}{files: make(map[string][]byte)}
+
+// readFile reads the named file, either from an archive or from disk.
+func readFile(name string) ([]byte, error) {
+	archives.mu.RLock()
+	src, ok := archives.files[name]
+	archives.mu.RUnlock()
+	if ok {
+		return src, nil
+	}
+	sources.mu.Lock()
+	defer sources.mu.Unlock()
+	if src, ok := sources.files[name]; ok {
+		return src, nil
+	}
+	src, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	sources.files[name] = src
+	return src, nil
+}
+
+// forgetSources drops the cached names, or all the files if no names
+// are given, so that they are read anew. All of them include the files
+// read from archives, which would otherwise shadow those on disk of
+// the same paths in a later scan.
+func forgetSources(names ...string) {
+	sources.mu.Lock()
+	if len(names) == 0 {
+		sources.files = make(map[string][]byte)
+		archives.mu.Lock()
+		for name, from := range archives.from {
+			if isArchive(from) {
+				delete(archives.files, name)
+				delete(archives.from, name)
+			}
+		}
+		archives.mu.Unlock()
+	}
+	for _, name := range names {
+		delete(sources.files, name)
+	}
+	sources.mu.Unlock()
+}
+
+// archiveOf returns the archive the named file was read from,
+// "-" for stdin, or an empty string.
+func archiveOf(name string) string {
+	archives.mu.RLock()
+	defer archives.mu.RUnlock()
+	return archives.from[name]
+}
+
+// stdinName is the name of the file read from stdin.
+const stdinName = "<stdin>"
+
+// readStdin reads the Go source from stdin into memory.
+func readStdin() error {
+	src, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+	archives.mu.Lock()
+	archives.files[stdinName] = src
+	archives.from[stdinName] = "-"
+	archives.mu.Unlock()
+	return nil
+}
+
+func isArchive(name string) bool {
+	for _, ext := range []string{".tar", ".tar.gz", ".tgz", ".zip"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// readArchive reads all Go files from the archive into memory and
+// sends their names to emit: their paths within the archive, or, if
+// prefixed, the name of the archive joined with them, so that neither
+// the files of the same path in other archives nor those on disk are
+// shadowed. It returns errStopped once the scan is stopped.
+func readArchive(name string, prefixed bool, emit func(string)) error {
+	add := func(file string, r io.Reader) error {
+		if stopped() {
+			return errStopped
+		}
+		file = path.Clean(strings.TrimPrefix(file, "./"))
+		if !strings.HasSuffix(file, ".go") || !vendorScanned(file) {
+			return nil
+		}
+		src, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		file = filepath.FromSlash(file)
+		if prefixed {
+			file = filepath.Join(name, file)
+		}
+		archives.mu.Lock()
+		archives.files[file] = src
+		archives.from[file] = name
+		archives.mu.Unlock()
+		emit(file)
+		return nil
+	}
+	if strings.HasSuffix(name, ".zip") {
+		return readZip(name, add)
+	}
+	return readTar(name, add)
+}
+
+func readZip(name string, add func(string, io.Reader) error) error {
+	zr, err := zip.OpenReader(name)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = add(f.Name, r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readTar(name string, add func(string, io.Reader) error) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if !strings.HasSuffix(name, ".tar") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if err := add(hdr.Name, tr); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func writeTar(t *testing.T, name string, files map[string]string) {
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var w io.Writer = f
+	if !strings.HasSuffix(name, ".tar") {
+		gz := gzip.NewWriter(f)
+		defer func() {
+			if err := gz.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}()
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	for file, src := range files {
+		hdr := &tar.Header{Name: file, Mode: 0644, Size: int64(len(src)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(src)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeZip(t *testing.T, name string, files map[string]string) {
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for file, src := range files {
+		w, err := zw.Create(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(src)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// addMemFile adds the source to the files read from memory, like
+// an archive member, until the test ends.
+func addMemFile(t *testing.T, name, src string) {
+	archives.mu.Lock()
+	archives.files[name] = []byte(src)
+	archives.from[name] = "test"
+	archives.mu.Unlock()
+	t.Cleanup(func() {
+		archives.mu.Lock()
+		delete(archives.files, name)
+		delete(archives.from, name)
+		archives.mu.Unlock()
+	})
+}
+
+func TestReadArchivesSamePath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dupl-archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	a, b := filepath.Join(dir, "a.tar"), filepath.Join(dir, "b.tar")
+	writeTar(t, a, map[string]string{"pkg/x.go": "package a\n"})
+	writeTar(t, b, map[string]string{"./pkg/x.go": "package b\n"})
+
+	var names []string
+	for _, archive := range []string{a, b} {
+		if err := readArchive(archive, true, func(name string) { names = append(names, name) }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		for _, name := range names {
+			delete(archives.files, name)
+			delete(archives.from, name)
+		}
+	}()
+	want := []string{filepath.Join(a, "pkg", "x.go"), filepath.Join(b, "pkg", "x.go")}
+	if len(names) != 2 || names[0] != want[0] || names[1] != want[1] {
+		t.Fatalf("got the files %q, want %q", names, want)
+	}
+	for i, pkg := range []string{"package a\n", "package b\n"} {
+		src, err := readFile(names[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(src) != pkg {
+			t.Errorf("%s: got %q, want %q", names[i], src, pkg)
+		}
+	}
+	if _, err := readFile(filepath.Join("pkg", "x.go")); err == nil {
+		t.Error("the path within the archives reads a file")
+	}
+}
+
+func TestScanArchives(t *testing.T) {
+	defer func(nc bool, from, to int) {
+		*noCrawlCache, *fromThreshold, *toThreshold = nc, from, to
+	}(*noCrawlCache, *fromThreshold, *toThreshold)
+	*noCrawlCache = true
+	*fromThreshold, *toThreshold = 15, 15
+	defer forgetSources()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"pkg/a.go":     serveSrc,
+		"./pkg/b.go":   serveSrc,
+		"pkg/c.txt":    serveSrc,
+		"pkg/d/e.go":   serveOther,
+		"vendor/v.go":  serveSrc,
+		"pkg/empty.go": "package p\n",
+	}
+	disk := filepath.Join(dir, "disk")
+	if err := os.Mkdir(disk, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeServeFile(t, filepath.Join(disk, "f.go"), serveSrc)
+
+	for _, ext := range []string{".tar", ".tar.gz", ".tgz", ".zip"} {
+		archive := filepath.Join(dir, "src"+ext)
+		if ext == ".zip" {
+			writeZip(t, archive, files)
+		} else {
+			writeTar(t, archive, files)
+		}
+		testCases := []struct {
+			paths []string
+			want  []string
+		}{
+			// A single archive has its files named by their paths in
+			// it, the text and vendored files left out.
+			{[]string{archive}, []string{"pkg/a.go", "pkg/b.go"}},
+			{[]string{archive, disk}, []string{filepath.ToSlash(archive) + "/pkg/a.go", filepath.ToSlash(archive) + "/pkg/b.go", filepath.ToSlash(disk) + "/f.go"}},
+		}
+		for _, tc := range testCases {
+			forgetSources()
+			out := scanForTest(t, tc.paths)
+			var doc struct {
+				Groups []struct{ Fragments []struct{ File string } }
+			}
+			if err := json.Unmarshal(out, &doc); err != nil {
+				t.Fatal(err)
+			}
+			if len(doc.Groups) != 1 {
+				t.Errorf("%s: got %d groups, want 1:\n%s", tc.paths, len(doc.Groups), out)
+				continue
+			}
+			var got []string
+			for _, frag := range doc.Groups[0].Fragments {
+				got = append(got, filepath.ToSlash(frag.File))
+			}
+			sort.Strings(got)
+			sort.Strings(tc.want)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("%s: got the files %q, want %q", tc.paths, got, tc.want)
+			}
+		}
+	}
+}
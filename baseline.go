@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/mibk/dupl/printer"
+)
+
+// baselineFile is the file the baseline command writes the ids of the
+// reported clone groups into.
+var baselineFile string
+
+// baselinePrinter writes the ids of the reported clone groups, sorted,
+// one at each line, in the format -ignore-hashes reads.
+type baselinePrinter struct {
+	w   io.Writer
+	ids map[string]bool
+}
+
+func newBaselinePrinter(w io.Writer, _ printer.ReadFile, _ printer.Options) printer.Printer {
+	return &baselinePrinter{w: w, ids: make(map[string]bool)}
+}
+
+func (p *baselinePrinter) PrintHeader() error { return nil }
+
+func (p *baselinePrinter) PrintClones(g printer.Group) error {
+	p.ids[g.ID()] = true
+	return nil
+}
+
+func (p *baselinePrinter) PrintFooter(printer.Totals) error {
+	ids := make([]string, 0, len(p.ids))
+	for id := range p.ids {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	if _, err := fmt.Fprintf(p.w, "# dupl baseline: %d accepted clone groups\n", len(ids)); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if _, err := fmt.Fprintln(p.w, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
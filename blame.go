@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/mibk/dupl/printer"
+)
+
+// blames caches the authors of the lines of the files, so that Git
+// blames each file at most once however many fragments it has.
+var blames = struct {
+	mu    sync.Mutex
+	files map[string][]string
+}{files: make(map[string][]string)}
+
+// checkBlame reports whether the current directory is in a Git
+// repository, writing a note to stderr if it is not, in which case the
+// fragments are not annotated.
+func checkBlame() bool {
+	if err := exec.Command("git", "rev-parse", "--show-toplevel").Run(); err != nil {
+		fmt.Fprintln(os.Stderr, "dupl: -blame: not in a Git repository; reporting the clones without their authors")
+		return false
+	}
+	return true
+}
+
+// annotateAuthors sets the author of most of the lines of every
+// fragment of the group, as git blame tells. The fragments of the files
+// Git does not know, like those read from archives or not committed
+// yet, are left as they are.
+func annotateAuthors(g *printer.Group) error {
+	for i := range g.Frags {
+		frag := &g.Frags[i]
+		authors := lineAuthors(frag.Filename)
+		if authors == nil {
+			continue
+		}
+		file, err := readFile(frag.Filename)
+		if err != nil {
+			return err
+		}
+		start := bytes.Count(file[:frag.Pos], []byte{'\n'})
+		end := start + bytes.Count(file[frag.Pos:frag.End], []byte{'\n'}) + 1
+		if end > len(authors) {
+			// The file has changed since it was blamed.
+			continue
+		}
+		frag.Author = predominantAuthor(authors[start:end])
+	}
+	return nil
+}
+
+// lineAuthors returns the authors of the lines of the file, like
+// "Name <email>", or nil if Git cannot blame it.
+func lineAuthors(name string) []string {
+	blames.mu.Lock()
+	defer blames.mu.Unlock()
+	if authors, ok := blames.files[name]; ok {
+		return authors
+	}
+	var authors []string
+	if archiveOf(name) == "" {
+		authors = blame(name)
+	}
+	blames.files[name] = authors
+	return authors
+}
+
+// blame runs git blame on the file and returns the authors of its
+// lines, or nil if it fails.
+func blame(name string) []string {
+	out, err := exec.Command("git", "blame", "--line-porcelain", "--", name).Output()
+	if err != nil {
+		return nil
+	}
+	var authors []string
+	var author, mail string
+	s := bufio.NewScanner(bytes.NewReader(out))
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		line := s.Text()
+		switch {
+		case strings.HasPrefix(line, "\t"):
+			// The line itself ends the information about it.
+			authors = append(authors, author+" "+mail)
+		case strings.HasPrefix(line, "author "):
+			author = strings.TrimPrefix(line, "author ")
+		case strings.HasPrefix(line, "author-mail "):
+			mail = strings.TrimPrefix(line, "author-mail ")
+		}
+	}
+	if s.Err() != nil {
+		return nil
+	}
+	return authors
+}
+
+// predominantAuthor returns the author of the most lines, the first one
+// to have them in case of a tie.
+func predominantAuthor(authors []string) string {
+	counts := make(map[string]int)
+	best := ""
+	for _, a := range authors {
+		counts[a]++
+		if counts[a] > counts[best] {
+			best = a
+		}
+	}
+	return best
+}
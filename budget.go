@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/mibk/dupl/printer"
+	"github.com/mibk/dupl/syntax"
+)
+
+// duplication measures the reported clones.
+type duplication struct {
+	files  map[string]bool // files with a reported fragment
+	groups int
+	list   []printer.Group       // the reported groups, for -sqlite
+	nodes  map[*syntax.Node]bool // tokens in reported fragments
+
+	// truncated is set if the clones were not all collected
+	// because of -max-total-fragments or -max-memory.
+	truncated bool
+}
+
+func newDuplication() *duplication {
+	return &duplication{files: make(map[string]bool), nodes: make(map[*syntax.Node]bool)}
+}
+
+// add adds the reported group to the measures. The tokens shared by
+// overlapping fragments are counted only once.
+func (d *duplication) add(g printer.Group) {
+	d.groups++
+	if *sqlitePath != "" {
+		d.list = append(d.list, g)
+	}
+	var walk func(n *syntax.Node)
+	walk = func(n *syntax.Node) {
+		d.nodes[n] = true
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	for _, frag := range g.Frags {
+		d.files[frag.Filename] = true
+		for _, n := range frag.Nodes {
+			walk(n)
+		}
+	}
+}
+
+// checkBudgets writes a message to w for every budget exceeded by
+// the duplication and reports whether all budgets are kept.
+func checkBudgets(w io.Writer, d *duplication, t printer.Totals) bool {
+	ok := true
+	exceeded := func(format string, v ...interface{}) {
+		fmt.Fprintf(w, "dupl: budget exceeded: "+format+"\n", v...)
+		ok = false
+	}
+	if *maxGroups >= 0 && d.groups > *maxGroups {
+		exceeded("%d clone groups, more than -max-groups %d", d.groups, *maxGroups)
+	}
+	tokens := len(d.nodes)
+	if *maxDuplTokens >= 0 && tokens > *maxDuplTokens {
+		exceeded("%d duplicated tokens, more than -max-duplicated-tokens %d", tokens, *maxDuplTokens)
+	}
+	if *maxDuplPercent >= 0 && t.Tokens > 0 {
+		if pct := 100 * float64(tokens) / float64(t.Tokens); pct > *maxDuplPercent {
+			exceeded("%.2f%% of tokens duplicated, more than -max-duplicated-percent %g", pct, *maxDuplPercent)
+		}
+	}
+	return ok
+}
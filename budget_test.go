@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mibk/dupl/printer"
+	"github.com/mibk/dupl/syntax"
+)
+
+func TestDuplicationAdd(t *testing.T) {
+	// Two statements of two tokens each, and of one.
+	a := &syntax.Node{Children: []*syntax.Node{{}}}
+	b := &syntax.Node{Children: []*syntax.Node{{}}}
+	c := &syntax.Node{}
+	d := newDuplication()
+	d.add(printer.Group{Frags: []printer.Fragment{
+		{Filename: "a.go", Nodes: []*syntax.Node{a}},
+		{Filename: "b.go", Nodes: []*syntax.Node{b}},
+	}})
+	// The overlapping fragment counts its new tokens only.
+	d.add(printer.Group{Frags: []printer.Fragment{
+		{Filename: "a.go", Nodes: []*syntax.Node{a, c}},
+		{Filename: "c.go", Nodes: []*syntax.Node{c}},
+	}})
+	if d.groups != 2 || len(d.nodes) != 5 || len(d.files) != 3 {
+		t.Errorf("got %d groups, %d tokens, %d files; want 2, 5, 3", d.groups, len(d.nodes), len(d.files))
+	}
+}
+
+func TestCheckBudgets(t *testing.T) {
+	defer func(groups, tokens int, pct float64) {
+		*maxGroups, *maxDuplTokens, *maxDuplPercent = groups, tokens, pct
+	}(*maxGroups, *maxDuplTokens, *maxDuplPercent)
+
+	// Two groups of 10 duplicated tokens in all.
+	d := newDuplication()
+	for i := 0; i < 2; i++ {
+		var nodes []*syntax.Node
+		for j := 0; j < 5; j++ {
+			nodes = append(nodes, &syntax.Node{})
+		}
+		d.add(printer.Group{Frags: []printer.Fragment{{Filename: "a.go", Nodes: nodes}}})
+	}
+
+	testCases := []struct {
+		name        string
+		groups      int
+		tokens      int
+		pct         float64
+		totalTokens int
+		ok          bool
+		messages    []string
+	}{
+		{"no budgets", -1, -1, -1, 100, true, nil},
+		{"all kept", 2, 10, 10, 100, true, nil},
+		{"zero budgets", 0, 0, 0, 100, false, []string{
+			"2 clone groups, more than -max-groups 0",
+			"10 duplicated tokens, more than -max-duplicated-tokens 0",
+			"10.00% of tokens duplicated, more than -max-duplicated-percent 0",
+		}},
+		{"groups", 1, -1, -1, 100, false, []string{"2 clone groups, more than -max-groups 1"}},
+		{"tokens", -1, 9, -1, 100, false, []string{"10 duplicated tokens, more than -max-duplicated-tokens 9"}},
+		{"percent", -1, -1, 2.5, 200, false, []string{"5.00% of tokens duplicated, more than -max-duplicated-percent 2.5"}},
+		{"percent kept", -1, -1, 5, 200, true, nil},
+		// Without tokens, there is no percentage to exceed.
+		{"no tokens", -1, -1, 0, 0, true, nil},
+	}
+	for _, tc := range testCases {
+		*maxGroups, *maxDuplTokens, *maxDuplPercent = tc.groups, tc.tokens, tc.pct
+		var buf bytes.Buffer
+		ok := checkBudgets(&buf, d, printer.Totals{Tokens: tc.totalTokens})
+		var want string
+		for _, m := range tc.messages {
+			want += "dupl: budget exceeded: " + m + "\n"
+		}
+		if ok != tc.ok || buf.String() != want {
+			t.Errorf("%s: got %v, %q; want %v, %q", tc.name, ok, buf.String(), tc.ok, want)
+		}
+	}
+}
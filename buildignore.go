@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// maxConstraintTags bounds the number of tags of the build constraints
+// that buildIgnored tries the assignments of; the files with more are
+// taken for built.
+const maxConstraintTags = 16
+
+// buildIgnoredFile reports whether the build constraints of the named
+// Go file exclude it from every build, like those of the generators
+// marked by //go:build ignore. The files that cannot be read are not
+// ignored; their parsing reports the error.
+func buildIgnoredFile(name string) bool {
+	src, err := readFile(name)
+	if err != nil {
+		return false
+	}
+	return buildIgnored(src)
+}
+
+// buildIgnored reports whether no set of build tags satisfies the build
+// constraints of the Go source, where the ignore tag, which no build
+// sets, is taken for unset. The //go:build line takes precedence over
+// the // +build lines, as for the go tool. Invalid constraints do not
+// exclude the file.
+func buildIgnored(src []byte) bool {
+	expr, err := buildConstraint(src)
+	if err != nil || expr == nil {
+		return false
+	}
+	tags := make(map[string]bool)
+	expr.tags(tags)
+	delete(tags, "ignore")
+	if len(tags) > maxConstraintTags {
+		return false
+	}
+	var names []string
+	for tag := range tags {
+		names = append(names, tag)
+	}
+	set := make(map[string]bool)
+	for bits := 0; bits < 1<<len(names); bits++ {
+		for i, tag := range names {
+			set[tag] = bits&(1<<i) != 0
+		}
+		if expr.eval(set) {
+			return false
+		}
+	}
+	return true
+}
+
+// buildConstraint returns the build constraint of the Go source found
+// before its package clause, or nil if it has none.
+func buildConstraint(src []byte) (constraintExpr, error) {
+	var plus []constraintExpr
+	sc := bufio.NewScanner(bytes.NewReader(src))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "//") {
+			break
+		}
+		if strings.HasPrefix(line, "//go:build ") {
+			return parseGoBuild(strings.TrimPrefix(line, "//go:build "))
+		}
+		if f := strings.Fields(line); len(f) > 2 && f[0] == "//" && f[1] == "+build" {
+			plus = append(plus, parsePlusBuild(f[2:]))
+		}
+	}
+	if len(plus) == 0 {
+		return nil, nil
+	}
+	expr := plus[0]
+	for _, e := range plus[1:] {
+		expr = andExpr{expr, e}
+	}
+	return expr, nil
+}
+
+// constraintExpr is a build constraint expression.
+type constraintExpr interface {
+	eval(set map[string]bool) bool
+	tags(m map[string]bool)
+}
+
+type (
+	tagExpr string
+	notExpr struct{ x constraintExpr }
+	andExpr struct{ x, y constraintExpr }
+	orExpr  struct{ x, y constraintExpr }
+)
+
+func (e tagExpr) eval(set map[string]bool) bool { return set[string(e)] }
+func (e notExpr) eval(set map[string]bool) bool { return !e.x.eval(set) }
+func (e andExpr) eval(set map[string]bool) bool { return e.x.eval(set) && e.y.eval(set) }
+func (e orExpr) eval(set map[string]bool) bool  { return e.x.eval(set) || e.y.eval(set) }
+
+func (e tagExpr) tags(m map[string]bool) { m[string(e)] = true }
+func (e notExpr) tags(m map[string]bool) { e.x.tags(m) }
+func (e andExpr) tags(m map[string]bool) { e.x.tags(m); e.y.tags(m) }
+func (e orExpr) tags(m map[string]bool)  { e.x.tags(m); e.y.tags(m) }
+
+// parsePlusBuild parses the options of a // +build line: the line is
+// satisfied by any of them, and an option by all its comma-separated
+// terms, each a tag or a negated one.
+func parsePlusBuild(options []string) constraintExpr {
+	var line constraintExpr
+	for _, opt := range options {
+		var and constraintExpr
+		for _, term := range strings.Split(opt, ",") {
+			var x constraintExpr = tagExpr(strings.TrimPrefix(term, "!"))
+			if strings.HasPrefix(term, "!") {
+				x = notExpr{x}
+			}
+			if and == nil {
+				and = x
+			} else {
+				and = andExpr{and, x}
+			}
+		}
+		if line == nil {
+			line = and
+		} else {
+			line = orExpr{line, and}
+		}
+	}
+	return line
+}
+
+// parseGoBuild parses the expression of a //go:build line.
+func parseGoBuild(s string) (constraintExpr, error) {
+	p := &constraintParser{s: s}
+	x, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.next(); tok != "" {
+		return nil, fmt.Errorf("unexpected %q in build constraint", tok)
+	}
+	return x, nil
+}
+
+// constraintParser parses a //go:build expression by recursive descent.
+type constraintParser struct {
+	s   string
+	tok string // the token peeked at, if any
+}
+
+// next returns the next token and consumes it.
+func (p *constraintParser) next() string {
+	tok := p.peek()
+	p.tok = ""
+	return tok
+}
+
+// peek returns the next token: an operator, a parenthesis, a tag, or an
+// empty string at the end.
+func (p *constraintParser) peek() string {
+	if p.tok != "" {
+		return p.tok
+	}
+	p.s = strings.TrimLeft(p.s, " \t")
+	if p.s == "" {
+		return ""
+	}
+	n := 1
+	switch {
+	case strings.HasPrefix(p.s, "&&"), strings.HasPrefix(p.s, "||"):
+		n = 2
+	case strings.ContainsRune("!()", rune(p.s[0])):
+	default:
+		n = strings.IndexAny(p.s, " \t!()&|")
+		if n < 0 {
+			n = len(p.s)
+		} else if n == 0 {
+			n = 1
+		}
+	}
+	p.tok, p.s = p.s[:n], p.s[n:]
+	return p.tok
+}
+
+func (p *constraintParser) or() (constraintExpr, error) {
+	x, err := p.and()
+	for err == nil && p.peek() == "||" {
+		p.next()
+		var y constraintExpr
+		if y, err = p.and(); err == nil {
+			x = orExpr{x, y}
+		}
+	}
+	return x, err
+}
+
+func (p *constraintParser) and() (constraintExpr, error) {
+	x, err := p.not()
+	for err == nil && p.peek() == "&&" {
+		p.next()
+		var y constraintExpr
+		if y, err = p.not(); err == nil {
+			x = andExpr{x, y}
+		}
+	}
+	return x, err
+}
+
+func (p *constraintParser) not() (constraintExpr, error) {
+	switch tok := p.next(); tok {
+	case "!":
+		x, err := p.not()
+		return notExpr{x}, err
+	case "(":
+		x, err := p.or()
+		if err == nil && p.next() != ")" {
+			err = fmt.Errorf("missing ) in build constraint")
+		}
+		return x, err
+	case "", ")", "&&", "||", "&", "|":
+		return nil, fmt.Errorf("unexpected %q in build constraint", tok)
+	default:
+		return tagExpr(tok), nil
+	}
+}
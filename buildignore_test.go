@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBuildIgnored(t *testing.T) {
+	testCases := []struct {
+		header  string
+		ignored bool
+	}{
+		{"", false},
+		{"//go:build ignore\n\n", true},
+		{"// Copyright\n\n//go:build ignore\n\n", true},
+		{"//go:build linux && ignore\n\n", true},
+		{"//go:build (linux || darwin) && !(ignore || !ignore)\n\n", true},
+		{"//go:build linux || ignore\n\n", false},
+		{"//go:build !ignore\n\n", false},
+		{"//go:build tools\n\n", false},
+		{"//go:build linux && !linux\n\n", true},
+		{"// +build ignore\n\n", true},
+		{"// +build linux ignore\n\n", false},
+		{"// +build linux,ignore\n\n", true},
+		{"// +build linux\n// +build ignore\n\n", true},
+		// The //go:build line takes precedence.
+		{"//go:build linux\n// +build ignore\n\n", false},
+		// Invalid constraints do not exclude the file.
+		{"//go:build linux &&\n\n", false},
+		// Constraints after the package clause are comments.
+		{"package p\n\n//go:build ignore\n", false},
+	}
+	for _, tc := range testCases {
+		src := []byte(tc.header + "package p\n")
+		if got := buildIgnored(src); got != tc.ignored {
+			t.Errorf("buildIgnored(%q) = %v, want %v", tc.header, got, tc.ignored)
+		}
+	}
+}
+
+func TestSkipBuildIgnored(t *testing.T) {
+	defer func(nc bool, th, to int) {
+		*noCrawlCache, *fromThreshold, *toThreshold = nc, th, to
+	}(*noCrawlCache, *fromThreshold, *toThreshold)
+	*noCrawlCache = true
+	*fromThreshold, *toThreshold = 10, 10
+
+	// The generator in testdata/buildignore/gen.go is a clone of a.go.
+	if out := scanForTest(t, []string{"testdata/buildignore"}); bytes.Contains(out, []byte(`"fragments"`)) {
+		t.Errorf("clones reported in a build-ignored file:\n%s", out)
+	}
+	if out := scanForTest(t, []string{"testdata/buildignore/a.go", "testdata/buildignore/gen.go"}); !bytes.Contains(out, []byte(`"fragments"`)) {
+		t.Errorf("no clones reported in the build-ignored file given:\n%s", out)
+	}
+
+	*includeIgnored = true
+	defer func() { *includeIgnored = false }()
+	if out := scanForTest(t, []string{"testdata/buildignore"}); !bytes.Contains(out, []byte(`"fragments"`)) {
+		t.Errorf("no clones reported with -include-ignored:\n%s", out)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// commands are the subcommands by their names, each run with the
+// arguments following its name. Any other first argument is a flag
+// or a path of the scan command.
+var commands = map[string]func(args []string){
+	"scan":     runScan,
+	"baseline": runBaseline,
+	"serve":    runServe,
+	"version":  runVersion,
+}
+
+// outputFlags are the flags of the scan command choosing what is
+// written and how, or another mode of operation. The serve and baseline
+// commands, which write their own output, do not take them.
+var outputFlags = []string{
+	"html", "html-append", "plumbing", "json", "json-pretty", "csv",
+	"github", "dot", "suggest-fix", "text-template", "diff-against",
+	"count", "output-dir", "gzip", "sqlite", "serve", "selftest",
+	"export-fingerprints", "reference", "index", "paginate", "color",
+	"no-color", "with-source", "without-source", "message-template",
+	"rule-id",
+}
+
+// presentationFlags are the flags of the scan command adding details
+// to the reported clones, which the baseline command does not write.
+var presentationFlags = []string{
+	"fragment-detail", "show-package", "show-scope", "func-signatures",
+	"fragment-ids", "similarity", "template-view", "explain", "blame",
+	"tab-width",
+}
+
+// newCommandFlags returns the flag set of the subcommand with the flags
+// of the scan command, which set the same variables, but those listed
+// in except.
+func newCommandFlags(name string, except ...[]string) *flag.FlagSet {
+	skip := make(map[string]bool)
+	for _, names := range except {
+		for _, name := range names {
+			skip[name] = true
+		}
+	}
+	fs := flag.NewFlagSet("dupl "+name, flag.ExitOnError)
+	flag.VisitAll(func(f *flag.Flag) {
+		if !skip[f.Name] {
+			fs.Var(f.Value, f.Name, f.Usage)
+		}
+	})
+	return fs
+}
+
+// commandUsage returns the usage function of a subcommand taking the
+// flags of the scan command but those listed in except.
+func commandUsage(synopsis string, except ...[]string) func() {
+	return func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s\n\n", synopsis)
+		fmt.Fprintln(os.Stderr, "The flags are those of the scan command, listed by dupl -h, except:")
+		line := " "
+		for _, names := range except {
+			for _, name := range names {
+				if len(line)+len(name)+2 > 72 {
+					fmt.Fprintln(os.Stderr, line)
+					line = " "
+				}
+				line += " -" + name
+			}
+		}
+		fmt.Fprintln(os.Stderr, line)
+	}
+}
+
+// runScan searches for clones in the paths given by args.
+func runScan(args []string) {
+	fs := newCommandFlags("scan")
+	fs.Usage = usage
+	fs.Parse(args)
+	scan(fs.Args())
+}
+
+// runBaseline writes the ids of the clone groups of the paths given by
+// args into the file given by the first argument, to be accepted by
+// -ignore-hashes.
+func runBaseline(args []string) {
+	fs := newCommandFlags("baseline", outputFlags, presentationFlags)
+	fs.Usage = commandUsage("dupl baseline [flags] file [paths]", outputFlags, presentationFlags)
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "dupl baseline: missing file to write the baseline into")
+		fs.Usage()
+		os.Exit(2)
+	}
+	baselineFile = fs.Arg(0)
+	scan(fs.Args()[1:])
+}
+
+// runServe serves the clones of the paths given by args over HTTP
+// on the address given by the first argument.
+func runServe(args []string) {
+	fs := newCommandFlags("serve", outputFlags)
+	fs.Usage = commandUsage("dupl serve [flags] addr [paths]", outputFlags)
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "dupl serve: missing address to listen on")
+		fs.Usage()
+		os.Exit(2)
+	}
+	*serveAddr = fs.Arg(0)
+	scan(fs.Args()[1:])
+}
+
+// runVersion prints the version of dupl.
+func runVersion(args []string) {
+	fs := flag.NewFlagSet("dupl version", flag.ExitOnError)
+	fs.Usage = usage
+	fs.Parse(args)
+	v := version()
+	if v == "" {
+		v = "(unknown)"
+	}
+	fmt.Println("dupl", v)
+}
+
+// warnCommandPath writes a note to stderr if the command is also the
+// name of a file or directory, which it is taken for no longer.
+func warnCommandPath(name string) {
+	if _, err := os.Stat(name); err == nil {
+		fmt.Fprintf(os.Stderr, "dupl: running the %s command; to scan the path %s, write it as ./%s\n", name, name, name)
+	}
+}
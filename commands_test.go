@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+
+	"github.com/mibk/dupl/printer"
+)
+
+func TestCommandFlags(t *testing.T) {
+	for _, names := range [][]string{outputFlags, presentationFlags} {
+		for _, name := range names {
+			if flag.Lookup(name) == nil {
+				t.Errorf("-%s is not a flag of the scan command", name)
+			}
+		}
+	}
+
+	serve := newCommandFlags("serve", outputFlags)
+	baseline := newCommandFlags("baseline", outputFlags, presentationFlags)
+	for _, tc := range []struct {
+		fs   *flag.FlagSet
+		name string
+		ok   bool
+	}{
+		{serve, "t", true},
+		{serve, "exclude-func", true},
+		{serve, "show-package", true},
+		{serve, "html", false},
+		{serve, "serve", false},
+		{baseline, "t", true},
+		{baseline, "ignore-hashes", true},
+		{baseline, "json", false},
+		{baseline, "show-package", false},
+	} {
+		if ok := tc.fs.Lookup(tc.name) != nil; ok != tc.ok {
+			t.Errorf("%s: got -%s defined %v, want %v", tc.fs.Name(), tc.name, ok, tc.ok)
+		}
+	}
+}
+
+func TestBaselinePrinter(t *testing.T) {
+	var buf bytes.Buffer
+	p := newBaselinePrinter(&buf, nil, printer.Options{})
+	for _, hash := range []string{"\xbb\x01\x02\x03\x04\x05\x06\x07\x08", "\xaa\x01\x02\x03\x04\x05\x06\x07\x08", "\xbb\x01\x02\x03\x04\x05\x06\x07\x09"} {
+		if err := p.PrintClones(printer.Group{Hash: hash}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.PrintFooter(printer.Totals{}); err != nil {
+		t.Fatal(err)
+	}
+	// The last two hashes share the group id.
+	want := "# dupl baseline: 2 accepted clone groups\naa01020304050607\nbb01020304050607\n"
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+
+}
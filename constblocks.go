@@ -0,0 +1,52 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/mibk/dupl/printer"
+	"github.com/mibk/dupl/syntax"
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+// inSinglePackage reports whether all fragments of the group are found
+// in the same directory.
+func inSinglePackage(g *printer.Group) bool {
+	dir := filepath.Dir(g.Frags[0].Filename)
+	for _, frag := range g.Frags[1:] {
+		if filepath.Dir(frag.Filename) != dir {
+			return false
+		}
+	}
+	return true
+}
+
+// nameConsts sets the context of every fragment of the group of const
+// declarations to the names of the constants.
+func nameConsts(g *printer.Group) error {
+	for i := range g.Frags {
+		frag := &g.Frags[i]
+		file, err := readFile(frag.Filename)
+		if err != nil {
+			return err
+		}
+		var names []string
+		for _, n := range frag.Nodes {
+			names = appendConstNames(names, n, file)
+		}
+		frag.Context = strings.Join(names, ", ")
+	}
+	return nil
+}
+
+// appendConstNames appends the names of the constants declared by
+// the node to names.
+func appendConstNames(names []string, n *syntax.Node, file []byte) []string {
+	if golang.IsIdent(n.Type) {
+		return append(names, string(file[n.Pos:n.End]))
+	}
+	for _, c := range n.Children {
+		names = appendConstNames(names, c, file)
+	}
+	return names
+}
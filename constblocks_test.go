@@ -0,0 +1,56 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/mibk/dupl/printer"
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+func TestInSinglePackage(t *testing.T) {
+	frag := func(name string) printer.Fragment { return printer.Fragment{Filename: filepath.FromSlash(name)} }
+	testCases := []struct {
+		files []string
+		want  bool
+	}{
+		{[]string{"a/x.go", "a/y.go"}, true},
+		{[]string{"a/x.go", "a/x.go"}, true},
+		{[]string{"a/x.go", "b/x.go"}, false},
+		{[]string{"a/x.go", "a/y.go", "a/b/z.go"}, false},
+	}
+	for _, tc := range testCases {
+		var g printer.Group
+		for _, f := range tc.files {
+			g.Frags = append(g.Frags, frag(f))
+		}
+		if got := inSinglePackage(&g); got != tc.want {
+			t.Errorf("%v: got %v, want %v", tc.files, got, tc.want)
+		}
+	}
+}
+
+func TestNameConsts(t *testing.T) {
+	srcs := map[string]string{
+		"a.go": "package a\n\nconst (\n\tStatusOK = 200\n\tStatusNotFound = 404\n)\n",
+		"b.go": "package b\n\nconst StatusOK, StatusNotFound = \"ok\", \"not found\"\n",
+	}
+	var g printer.Group
+	for _, name := range []string{"a.go", "b.go"} {
+		addMemFile(t, name, srcs[name])
+		root, err := golang.Parse(name, []byte(srcs[name]), golang.Options{ConstBlocks: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		decl := root.Children[0]
+		g.Frags = append(g.Frags, printer.Fragment{Filename: name, Pos: decl.Pos, End: decl.End, Nodes: root.Children})
+	}
+	if err := nameConsts(&g); err != nil {
+		t.Fatal(err)
+	}
+	for _, frag := range g.Frags {
+		if want := "StatusOK, StatusNotFound"; frag.Context != want {
+			t.Errorf("%s: got the context %q, want %q", frag.Filename, frag.Context, want)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/mibk/dupl/printer"
+)
+
+// countPrinter writes only the number of the reported clone groups.
+type countPrinter struct {
+	w      io.Writer
+	groups int
+}
+
+func newCountPrinter(w io.Writer, _ printer.ReadFile, _ printer.Options) printer.Printer {
+	return &countPrinter{w: w}
+}
+
+func (p *countPrinter) PrintHeader() error { return nil }
+
+func (p *countPrinter) PrintClones(printer.Group) error {
+	p.groups++
+	return nil
+}
+
+func (p *countPrinter) PrintFooter(printer.Totals) error {
+	_, err := fmt.Fprintln(p.w, p.groups)
+	return err
+}
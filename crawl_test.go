@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSkipTestdata(t *testing.T) {
+	defer func(nc bool, th, to int) {
+		*noCrawlCache, *fromThreshold, *toThreshold = nc, th, to
+	}(*noCrawlCache, *fromThreshold, *toThreshold)
+	*noCrawlCache = true
+	*fromThreshold, *toThreshold = 10, 10
+
+	// The fixtures in testdata/crawl/testdata are clones.
+	if out := scanForTest(t, []string{"testdata/crawl"}); bytes.Contains(out, []byte(`"fragments"`)) {
+		t.Errorf("clones reported in a testdata directory:\n%s", out)
+	}
+	if out := scanForTest(t, []string{"testdata/crawl/testdata"}); !bytes.Contains(out, []byte(`"fragments"`)) {
+		t.Errorf("no clones reported in the testdata directory given:\n%s", out)
+	}
+
+	*includeTestdata = true
+	defer func() { *includeTestdata = false }()
+	if out := scanForTest(t, []string{"testdata/crawl"}); !bytes.Contains(out, []byte(`"fragments"`)) {
+		t.Errorf("no clones reported with -include-testdata:\n%s", out)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/gob"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// crawlCache is a stored result of walking a directory tree. It stays
+// valid as long as none of the walked directories has been modified,
+// which is what adding, removing, or renaming an entry does. All paths
+// are relative to the walked root.
+type crawlCache struct {
+	Dirs  map[string]int64 // modification time in nanoseconds
+	Files []string
+
+	// Ignores are the .gitignore files read by their absolute paths,
+	// with their modification times, with -respect-gitignore.
+	Ignores map[string]int64
+}
+
+// crawlDir sends all files found in the directory root to emit. The file
+// list is served from the cache if valid, otherwise the directory is
+// walked and the cache is rewritten.
+func crawlDir(root string, emit func(string)) error {
+	if *noCrawlCache {
+		_, err := walkDir(root, emit)
+		return err
+	}
+	cpath, err := crawlCachePath(root)
+	if err != nil {
+		if *verbose {
+			log.Println("Cannot use crawl cache:", err)
+		}
+		_, err := walkDir(root, emit)
+		return err
+	}
+	if c, err := loadCrawlCache(cpath); err == nil && c.valid(root) {
+		for _, f := range c.Files {
+			emit(filepath.Join(root, f))
+		}
+		return nil
+	}
+	c, err := walkDir(root, emit)
+	if err != nil {
+		return err
+	}
+	if err := c.save(cpath); err != nil && *verbose {
+		log.Println("Cannot save crawl cache:", err)
+	}
+	return nil
+}
+
+// walkDir walks the directory root, emitting the files while walking,
+// and returns the collected file list. It returns errStopped once the
+// scan is stopped, so that the partial list is not cached.
+func walkDir(root string, emit func(string)) (*crawlCache, error) {
+	c := &crawlCache{Dirs: make(map[string]int64)}
+	var ign *gitignore
+	if *respectGitignore {
+		var err error
+		if ign, err = newGitignore(root); err != nil {
+			return nil, err
+		}
+		c.Ignores = ign.files
+	}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if stopped() {
+			return errStopped
+		}
+		if !*vendor && !*vendorOnly && isVendored(path) {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && rel != "." && info.Name() == "testdata" && !*includeTestdata {
+			// ignored by the go tool too
+			return filepath.SkipDir
+		}
+		if ign != nil && rel != "." && (info.Name() == ".git" || ign.ignored(path, info.IsDir())) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ign != nil && info.IsDir() {
+			if err := ign.load(path); err != nil {
+				return err
+			}
+		}
+		if info.IsDir() {
+			c.Dirs[rel] = info.ModTime().UnixNano()
+		} else if strings.HasSuffix(info.Name(), ".go") && vendorScanned(path) {
+			c.Files = append(c.Files, rel)
+			emit(path)
+		}
+		return nil
+	})
+	return c, err
+}
+
+func isVendored(path string) bool {
+	return strings.HasPrefix(path, vendorDirPrefix) || strings.Contains(path, vendorDirInPath)
+}
+
+// vendorScanned reports whether the file is scanned as far as vendoring
+// goes: with -vendor-only only the vendored files are, with -vendor all,
+// and otherwise those not vendored.
+func vendorScanned(path string) bool {
+	if *vendorOnly {
+		return isVendored(path)
+	}
+	return *vendor || !isVendored(path)
+}
+
+func (c *crawlCache) valid(root string) bool {
+	if len(c.Dirs) == 0 {
+		return false
+	}
+	for dir, mtime := range c.Dirs {
+		info, err := os.Lstat(filepath.Join(root, dir))
+		if err != nil || !info.IsDir() || info.ModTime().UnixNano() != mtime {
+			return false
+		}
+	}
+	for file, mtime := range c.Ignores {
+		info, err := os.Stat(file)
+		if err != nil || info.ModTime().UnixNano() != mtime {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *crawlCache) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(f).Encode(c); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func loadCrawlCache(path string) (*crawlCache, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	c := new(crawlCache)
+	if err := gob.NewDecoder(f).Decode(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// crawlCachePath returns the cache file for the directory root. The key
+// includes every option that affects which files are crawled.
+func crawlCachePath(root string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	key := fmt.Sprintf("%s\x00vendor=%t", abs, *vendor)
+	if *vendorOnly {
+		key += "\x00vendor-only"
+	}
+	if *respectGitignore {
+		key += "\x00respect-gitignore"
+	}
+	if *includeTestdata {
+		key += "\x00include-testdata"
+	}
+	return filepath.Join(dir, "dupl", fmt.Sprintf("crawl-%x", sha1.Sum([]byte(key)))), nil
+}
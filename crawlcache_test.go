@@ -0,0 +1,151 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// crawlForTest crawls the directory and returns the files found,
+// relative to it and sorted.
+func crawlForTest(t *testing.T, root string) []string {
+	t.Helper()
+	var files []string
+	err := crawlDir(root, func(file string) {
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, filepath.ToSlash(rel))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// useTestCacheDir makes the user cache directory, where the crawl
+// cache is, one of the test.
+func useTestCacheDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("LocalAppData", dir)
+}
+
+func TestCrawlCache(t *testing.T) {
+	useTestCacheDir(t)
+	defer func(old bool) { *noCrawlCache = old }(*noCrawlCache)
+	*noCrawlCache = false
+
+	root := t.TempDir()
+	write := func(name string) {
+		t.Helper()
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("package p\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// setMtime sets the modification time of the directory.
+	setMtime := func(name string, mtime time.Time) {
+		t.Helper()
+		if err := os.Chtimes(filepath.Join(root, filepath.FromSlash(name)), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(step string, want ...string) {
+		t.Helper()
+		if got := crawlForTest(t, root); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", step, got, want)
+		}
+	}
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	write("a.go")
+	write("sub/b.go")
+	setMtime(".", past)
+	setMtime("sub", past)
+	check("first crawl", "a.go", "sub/b.go")
+
+	// The cache is valid as long as the mtimes of the directories are
+	// the same, so a file added without changing them is not found.
+	write("c.go")
+	setMtime(".", past)
+	check("unchanged mtimes", "a.go", "sub/b.go")
+
+	setMtime(".", past.Add(time.Second))
+	check("root modified", "a.go", "c.go", "sub/b.go")
+
+	write("sub/d.go")
+	setMtime("sub", past.Add(time.Second))
+	check("subdirectory modified", "a.go", "c.go", "sub/b.go", "sub/d.go")
+
+	if err := os.Remove(filepath.Join(root, "sub", "b.go")); err != nil {
+		t.Fatal(err)
+	}
+	check("file removed", "a.go", "c.go", "sub/d.go")
+
+	*noCrawlCache = true
+	write("e.go")
+	setMtime(".", past.Add(time.Second))
+	check("-no-crawl-cache", "a.go", "c.go", "e.go", "sub/d.go")
+}
+
+func TestCrawlCacheOptions(t *testing.T) {
+	useTestCacheDir(t)
+	defer func(old bool) { *noCrawlCache = old }(*noCrawlCache)
+	*noCrawlCache = false
+	defer func(testdata, vendored, only bool) {
+		*includeTestdata, *vendor, *vendorOnly = testdata, vendored, only
+	}(*includeTestdata, *vendor, *vendorOnly)
+
+	root := t.TempDir()
+	for _, name := range []string{"a.go", "testdata/b.go", "vendor/c.go"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("package p\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		name                           string
+		testdata, vendored, vendorOnly bool
+		want                           []string
+	}{
+		{"default", false, false, false, []string{"a.go"}},
+		{"-include-testdata", true, false, false, []string{"a.go", "testdata/b.go"}},
+		{"-vendor", false, true, false, []string{"a.go", "vendor/c.go"}},
+		{"-vendor-only", false, false, true, []string{"vendor/c.go"}},
+	}
+	seen := make(map[string]string)
+	// The second time, the files are listed from the cache.
+	for _, tc := range append(testCases, testCases...) {
+		*includeTestdata, *vendor, *vendorOnly = tc.testdata, tc.vendored, tc.vendorOnly
+		if got := crawlForTest(t, root); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+		cpath, err := crawlCachePath(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Each set of options has a cache file of its own, kept while
+		// crawling with the others.
+		if name, ok := seen[cpath]; ok && name != tc.name {
+			t.Errorf("%s: the same cache file as %s", tc.name, name)
+		}
+		seen[cpath] = tc.name
+		if _, err := os.Stat(cpath); err != nil {
+			t.Errorf("%s: no cache file: %v", tc.name, err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/mibk/dupl/job"
+	"github.com/mibk/dupl/printer"
+	"github.com/mibk/dupl/syntax"
+)
+
+// scanForTest scans the paths and returns the JSON output.
+func scanForTest(t *testing.T, ps []string) []byte {
+	fchan, errc := crawlPaths(ps)
+	out := scanFeedForTest(t, fchan)
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// scanFeedForTest scans the files of the feed and returns the JSON output.
+func scanFeedForTest(t *testing.T, feed chan string) []byte {
+	var stats job.Stats
+	schan := job.Parse(feed, readFile, parseOptions(), &stats, nil)
+	tree, data, done := job.BuildTree(schan)
+	<-done
+	tree.Update(&syntax.Node{Type: -1})
+	mchan := tree.FindDuplOver(*fromThreshold)
+	duplChan := make(chan syntax.Match)
+	go findDuplicates(data, *fromThreshold, *toThreshold, mchan, duplChan)
+
+	var buf bytes.Buffer
+	p := printer.NewJSON(&buf, readFile, printer.Options{})
+	_, err := printDupls(p, duplChan, func() printer.Totals {
+		return printer.Totals{Files: stats.Files, Lines: stats.Lines, Tokens: stats.Tokens}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDeterministicOutput(t *testing.T) {
+	defer func(nc bool, from, to int) {
+		*noCrawlCache, *fromThreshold, *toThreshold = nc, from, to
+	}(*noCrawlCache, *fromThreshold, *toThreshold)
+	*noCrawlCache = true
+	*fromThreshold, *toThreshold = 10, 30
+
+	// The fixture has the same functions in different orders in its
+	// files, which makes groups of two and of three clones.
+	ps := []string{filepath.Join("testdata", "determinism")}
+	want := scanForTest(t, ps)
+	if !bytes.Contains(want, []byte(`"fragments"`)) {
+		t.Fatalf("no clone groups found:\n%s", want)
+	}
+	for i := 1; i < 50; i++ {
+		if got := scanForTest(t, ps); !bytes.Equal(got, want) {
+			t.Fatalf("run %d: output differs from the first run:\n%s\nwant:\n%s", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mibk/dupl/printer"
+)
+
+// diffPaths returns the paths to scan for -diff-against: the single
+// file given on the command line and the file compared against.
+func diffPaths(args []string) ([]string, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("-diff-against %s: want a single file to compare, got %d paths", *diffAgainst, len(args))
+	}
+	ps := []string{args[0], *diffAgainst}
+	for _, p := range ps {
+		if info, err := os.Stat(p); err == nil && info.IsDir() {
+			return nil, fmt.Errorf("-diff-against: %s is a directory, not a file", p)
+		}
+	}
+	return ps, nil
+}
+
+// diffPrinter writes the clones between the two files of -diff-against
+// side by side, the file given on the command line on the left, in the
+// order of their positions in it.
+type diffPrinter struct {
+	w       io.Writer
+	regions []diffRegion
+	read    printer.ReadFile
+}
+
+// diffRegion is the side-by-side diff of a clone.
+type diffRegion struct {
+	pos  int // of the left fragment
+	text string
+}
+
+func newDiffPrinter(w io.Writer, fread printer.ReadFile, _ printer.Options) printer.Printer {
+	return &diffPrinter{w: w, read: fread}
+}
+
+func (p *diffPrinter) PrintHeader() error { return nil }
+
+func (p *diffPrinter) PrintClones(g printer.Group) error {
+	var left, right []printer.Fragment
+	for _, frag := range g.Frags {
+		if filepath.Clean(frag.Filename) == filepath.Clean(*diffAgainst) {
+			right = append(right, frag)
+		} else {
+			left = append(left, frag)
+		}
+	}
+	if len(left) == 0 || len(right) == 0 {
+		return nil
+	}
+	a, as, err := p.lines(left[0])
+	if err != nil {
+		return err
+	}
+	b, bs, err := p.lines(right[0])
+	if err != nil {
+		return err
+	}
+	var w strings.Builder
+	width := len(as)
+	for _, l := range a {
+		if len(l) > width {
+			width = len(l)
+		}
+	}
+	fmt.Fprintf(&w, "%-*s   %s\n", width, as, bs)
+	if more := len(left) + len(right) - 2; more > 0 {
+		fmt.Fprintf(&w, "(%d more copies of %d tokens)\n", more, g.Tokens)
+	}
+	for _, r := range alignLines(a, b) {
+		fmt.Fprintf(&w, "%-*s %c %s\n", width, r.a, r.mark, r.b)
+	}
+	p.regions = append(p.regions, diffRegion{left[0].Pos, w.String()})
+	return nil
+}
+
+func (p *diffPrinter) PrintFooter(printer.Totals) error {
+	sort.SliceStable(p.regions, func(i, j int) bool { return p.regions[i].pos < p.regions[j].pos })
+	for _, r := range p.regions {
+		fmt.Fprintf(p.w, "%s\n", r.text)
+	}
+	_, err := fmt.Fprintf(p.w, "Found total %d shared regions.\n", len(p.regions))
+	return err
+}
+
+// lines returns the whole lines of the fragment, with the tabs
+// expanded and the common indentation removed, and its position.
+func (p *diffPrinter) lines(frag printer.Fragment) ([]string, string, error) {
+	file, err := p.read(frag.Filename)
+	if err != nil {
+		return nil, "", err
+	}
+	start := bytes.LastIndexByte(file[:frag.Pos], '\n') + 1
+	end := frag.End + bytes.IndexByte(file[frag.End:], '\n')
+	if end < frag.End {
+		end = len(file)
+	}
+	first := bytes.Count(file[:start], []byte{'\n'}) + 1
+	lines := strings.Split(string(file[start:end]), "\n")
+	indent := -1
+	for i, l := range lines {
+		lines[i] = strings.Replace(l, "\t", "    ", -1)
+		if trimmed := strings.TrimLeft(lines[i], " "); trimmed != "" {
+			if n := len(lines[i]) - len(trimmed); indent < 0 || n < indent {
+				indent = n
+			}
+		}
+	}
+	for i, l := range lines {
+		if len(l) >= indent && indent > 0 {
+			lines[i] = l[indent:]
+		}
+	}
+	pos := fmt.Sprintf("%s:%d-%d", frag.Filename, first, first+len(lines)-1)
+	return lines, pos, nil
+}
+
+// diffRow is a row of the side-by-side diff. The mark is a space for
+// lines the same but for white space, | for differing ones, and < or >
+// for a line only on the left or the right.
+type diffRow struct {
+	a, b string
+	mark byte
+}
+
+// alignLines aligns the lines a and b by their longest common
+// subsequence, comparing the lines without the surrounding white space.
+func alignLines(a, b []string) []diffRow {
+	same := func(i, j int) bool { return strings.TrimSpace(a[i]) == strings.TrimSpace(b[j]) }
+	// lcs[i][j] is the length of the LCS of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if same(i, j) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var rows []diffRow
+	var onlyA, onlyB []string
+	flush := func() {
+		// Pair the differing lines between two common ones.
+		for len(onlyA) > 0 && len(onlyB) > 0 {
+			rows = append(rows, diffRow{onlyA[0], onlyB[0], '|'})
+			onlyA, onlyB = onlyA[1:], onlyB[1:]
+		}
+		for _, l := range onlyA {
+			rows = append(rows, diffRow{l, "", '<'})
+		}
+		for _, l := range onlyB {
+			rows = append(rows, diffRow{"", l, '>'})
+		}
+		onlyA, onlyB = nil, nil
+	}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case same(i, j):
+			flush()
+			rows = append(rows, diffRow{a[i], b[j], ' '})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			onlyA = append(onlyA, a[i])
+			i++
+		default:
+			onlyB = append(onlyB, b[j])
+			j++
+		}
+	}
+	onlyA = append(onlyA, a[i:]...)
+	onlyB = append(onlyB, b[j:]...)
+	flush()
+	return rows
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mibk/dupl/printer"
+)
+
+func TestAlignLines(t *testing.T) {
+	testCases := []struct {
+		a, b string
+		want []diffRow
+	}{
+		{"x\ny\nz", "x\n  y\nz", []diffRow{{"x", "x", ' '}, {"y", "  y", ' '}, {"z", "z", ' '}}},
+		{"x\ny\nz", "x\nY\nz", []diffRow{{"x", "x", ' '}, {"y", "Y", '|'}, {"z", "z", ' '}}},
+		{"x\ny\nz", "x\nz", []diffRow{{"x", "x", ' '}, {"y", "", '<'}, {"z", "z", ' '}}},
+		{"x\nz", "x\nw\nz", []diffRow{{"x", "x", ' '}, {"", "w", '>'}, {"z", "z", ' '}}},
+		{"x\ny1\ny2", "x\nY1", []diffRow{{"x", "x", ' '}, {"y1", "Y1", '|'}, {"y2", "", '<'}}},
+	}
+	for _, tc := range testCases {
+		got := alignLines(strings.Split(tc.a, "\n"), strings.Split(tc.b, "\n"))
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q and %q: got %q, want %q", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestDiffPaths(t *testing.T) {
+	defer func(against string) { *diffAgainst = against }(*diffAgainst)
+	*diffAgainst = "b.go"
+	if ps, err := diffPaths([]string{"a.go"}); err != nil || !reflect.DeepEqual(ps, []string{"a.go", "b.go"}) {
+		t.Errorf("got %q, %v; want a.go and b.go", ps, err)
+	}
+	if _, err := diffPaths([]string{"a.go", "c.go"}); err == nil {
+		t.Error("two files to compare accepted")
+	}
+	if _, err := diffPaths([]string{"testdata"}); err == nil {
+		t.Error("a directory to compare accepted")
+	}
+}
+
+func TestDiffPrinter(t *testing.T) {
+	defer func(against string) { *diffAgainst = against }(*diffAgainst)
+	*diffAgainst = "b.go"
+	srcA := "package p\n\nfunc f() {\n\tx := 1\n\tprintln(x)\n}\n"
+	srcB := "package p\n\nfunc g() {\n\ty := 1\n\tprintln(y)\n}\n"
+	addMemFile(t, "a.go", srcA)
+	addMemFile(t, "b.go", srcB)
+	shared := printer.Group{Tokens: 10, Frags: []printer.Fragment{
+		fixFragment(t, "b.go", srcB, "y := 1", "println(y)"),
+		fixFragment(t, "a.go", srcA, "x := 1", "println(x)"),
+	}}
+	// A clone within the file compared is not shown.
+	within := printer.Group{Tokens: 10, Frags: []printer.Fragment{
+		fixFragment(t, "a.go", srcA, "x := 1", "x := 1"),
+		fixFragment(t, "a.go", srcA, "println(x)", "println(x)"),
+	}}
+
+	var buf bytes.Buffer
+	p := newDiffPrinter(&buf, readFile, printer.Options{})
+	for _, g := range []printer.Group{within, shared} {
+		if err := p.PrintClones(g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.PrintFooter(printer.Totals{}); err != nil {
+		t.Fatal(err)
+	}
+	want := "a.go:4-5     b.go:4-5\n" +
+		"x := 1     | y := 1\n" +
+		"println(x) | println(y)\n" +
+		"\nFound total 1 shared regions.\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
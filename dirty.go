@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/mibk/dupl/printer"
+)
+
+// dirtyFiles are the absolute paths of the Go files modified, staged,
+// or untracked in the working tree, or nil if they are not known.
+var dirtyFiles map[string]bool
+
+// loadDirtyFiles asks Git for the Go files changed in the working tree
+// of the current directory. If it is not in a Git repository, all the
+// files stay eligible and a note is written to stderr.
+func loadDirtyFiles() error {
+	out, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "dupl: -dirty: not in a Git repository; reporting the clones of all the files")
+		return nil
+	}
+	top := strings.TrimSpace(string(out))
+	out, err = exec.Command("git", "status", "--porcelain", "-z", "--untracked-files=all").Output()
+	if err != nil {
+		return fmt.Errorf("git status: %v", err)
+	}
+	dirtyFiles = make(map[string]bool)
+	entries := bytes.Split(out, []byte{0})
+	for i := 0; i < len(entries); i++ {
+		e := string(entries[i])
+		if len(e) < 4 {
+			continue
+		}
+		status, path := e[:2], e[3:]
+		if status[0] == 'R' || status[0] == 'C' {
+			// The original path of a rename or copy follows.
+			i++
+		}
+		if strings.Contains(status, "D") || !strings.HasSuffix(path, ".go") {
+			continue
+		}
+		dirtyFiles[foldPath(filepath.Join(top, filepath.FromSlash(path)))] = true
+	}
+	return nil
+}
+
+// isDirty reports whether the file is changed in the working tree.
+func isDirty(file string) bool {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return false
+	}
+	return dirtyFiles[foldPath(abs)]
+}
+
+// hasDirtyFrag reports whether any fragment of the group is found
+// in a file changed in the working tree.
+func hasDirtyFrag(g *printer.Group) bool {
+	for _, frag := range g.Frags {
+		if isDirty(frag.Filename) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// envFlagsVar holds the default flags of all the commands.
+const envFlagsVar = "DUPL_FLAGS"
+
+// parseEnvFlags sets the flags given by DUPL_FLAGS, before those of
+// the command line, which thus override them, or add to them in case
+// of the repeatable flags.
+func parseEnvFlags() error {
+	args, err := splitArgs(os.Getenv(envFlagsVar))
+	if err != nil || len(args) == 0 {
+		return err
+	}
+	fs := flag.NewFlagSet(envFlagsVar, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	flag.VisitAll(func(f *flag.Flag) {
+		fs.Var(f.Value, f.Name, f.Usage)
+	})
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("only flags are allowed, found %q", fs.Arg(0))
+	}
+	return nil
+}
+
+// splitArgs splits s into words like a POSIX shell would, without
+// any expansions: the words are separated by white space, which is
+// kept in single or double quotes, and a backslash escapes the next
+// character, except in single quotes, and in double quotes unless
+// the character is $, `, ", \, or a newline.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '\\':
+			inWord = true
+			if i+1 < len(s) {
+				i++
+				if s[i] != '\n' {
+					word.WriteByte(s[i])
+				}
+			}
+		case c == '\'':
+			inWord = true
+			j := strings.IndexByte(s[i+1:], '\'')
+			if j < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			word.WriteString(s[i+1 : i+1+j])
+			i += j + 1
+		case c == '"':
+			inWord = true
+			for i++; ; i++ {
+				if i == len(s) {
+					return nil, errors.New("unterminated double quote")
+				}
+				if s[i] == '"' {
+					break
+				}
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("$`\"\\\n", s[i+1]) >= 0 {
+					i++
+					if s[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteByte(s[i])
+			}
+		default:
+			inWord = true
+			word.WriteByte(c)
+		}
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"  -t 30\t-exact\n", []string{"-t", "30", "-exact"}},
+		{`-ignore-dir 'generated code'`, []string{"-ignore-dir", "generated code"}},
+		{`-ignore-dir "a \"b\" \c"`, []string{"-ignore-dir", `a "b" \c`}},
+		{`-rule-id=a\ b 'it''s' ""`, []string{"-rule-id=a b", "its", ""}},
+	}
+	for _, tt := range tests {
+		got, err := splitArgs(tt.in)
+		if err != nil {
+			t.Errorf("splitArgs(%q): %v", tt.in, err)
+		} else if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitArgs(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	for _, in := range []string{`-t "30`, `-t '30`} {
+		if _, err := splitArgs(in); err == nil {
+			t.Errorf("splitArgs(%q) accepted an unterminated quote", in)
+		}
+	}
+}
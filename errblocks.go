@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/mibk/dupl/printer"
+	"github.com/mibk/dupl/syntax"
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+// errorBlocks counts the clone groups of error handling left out
+// of the report by -error-blocks=summarize.
+var errorBlocks struct {
+	groups, frags int
+}
+
+// errCheck matches the condition of an error check, without spaces.
+var errCheck = regexp.MustCompile(`^\w*[eE]rr\w*!=nil$`)
+
+// isErrorHandling reports whether all fragments of the group handle
+// errors. Such a fragment consists of `if err != nil` statements and
+// assignments, the last being an `if err != nil` statement.
+func isErrorHandling(g *printer.Group) (bool, error) {
+	for _, frag := range g.Frags {
+		file, err := readFile(frag.Filename)
+		if err != nil {
+			return false, err
+		}
+		nodes := frag.Nodes
+		if !isErrCheck(nodes[len(nodes)-1], file) {
+			return false, nil
+		}
+		for _, n := range nodes[:len(nodes)-1] {
+			if n.Type != golang.AssignStmt && !isErrCheck(n, file) {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// isErrCheck reports whether the node is an if statement with
+// a condition like err != nil.
+func isErrCheck(n *syntax.Node, file []byte) bool {
+	if n.Type != golang.IfStmt {
+		return false
+	}
+	cond := n.Children[0]
+	if cond.Type != golang.BinaryExpr && len(n.Children) > 1 {
+		// skip the init statement
+		cond = n.Children[1]
+	}
+	if cond.Type != golang.BinaryExpr {
+		return false
+	}
+	var text []byte
+	for _, b := range file[cond.Pos:cond.End] {
+		if b != ' ' && b != '\t' {
+			text = append(text, b)
+		}
+	}
+	return errCheck.Match(text)
+}
+
+// errorBlocksFlag is the mode of reporting clones of error handling.
+type errorBlocksFlag string
+
+func (f *errorBlocksFlag) String() string { return string(*f) }
+
+func (f *errorBlocksFlag) Set(value string) error {
+	switch value {
+	case "report", "summarize", "ignore":
+		*f = errorBlocksFlag(value)
+		return nil
+	}
+	return fmt.Errorf("invalid mode %q; want report, summarize, or ignore", value)
+}
+
+// printErrorBlocks writes the summary of the clones of error handling
+// left out of the report to w.
+func printErrorBlocks(w io.Writer) {
+	if errorBlocks.groups == 0 {
+		return
+	}
+	fmt.Fprintf(w, "dupl: %d near-identical error handling blocks in %d clone groups not reported; "+
+		"use -error-blocks=report to list them\n", errorBlocks.frags, errorBlocks.groups)
+}
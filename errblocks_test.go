@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mibk/dupl/printer"
+	"github.com/mibk/dupl/syntax"
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+// bodyFragment returns the fragment of the statements of the first
+// function of the source, which is read from memory as the file name.
+func bodyFragment(t *testing.T, name, src string) printer.Fragment {
+	t.Helper()
+	addMemFile(t, name, src)
+	root, err := golang.Parse(name, []byte(src), golang.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range syntax.Serialize(root) {
+		if n.Type == golang.BlockStmt && len(n.Children) > 0 {
+			stmts := n.Children
+			return printer.Fragment{Filename: name, Pos: stmts[0].Pos, End: stmts[len(stmts)-1].End, Nodes: stmts}
+		}
+	}
+	t.Fatalf("no function body in %s", name)
+	return printer.Fragment{}
+}
+
+func TestIsErrorHandling(t *testing.T) {
+	testCases := []struct {
+		body string
+		want bool
+	}{
+		{"x, err := f()\n\tif err != nil {\n\t\treturn err\n\t}", true},
+		{"if err := f(); err != nil {\n\t\treturn err\n\t}", true},
+		{"x, errRead := f()\n\tif errRead!=nil {\n\t\treturn errRead\n\t}", true},
+		{"if err != nil {\n\t\treturn err\n\t}\n\tif err = g(); err != nil {\n\t\treturn err\n\t}", true},
+		{"if err != nil {\n\t\treturn err\n\t}\n\tprintln(x)", false},
+		{"println(x)\n\tif err != nil {\n\t\treturn err\n\t}", false},
+		{"if x != nil {\n\t\treturn x\n\t}", false},
+		{"if err == nil {\n\t\treturn nil\n\t}", false},
+		{"if err != nil && x {\n\t\treturn err\n\t}", false},
+	}
+	for i, tc := range testCases {
+		src := "package p\n\nfunc f() error {\n\t" + tc.body + "\n}\n"
+		frag := bodyFragment(t, fmt.Sprintf("errblocks%d.go", i), src)
+		g := printer.Group{Frags: []printer.Fragment{frag, frag}}
+		got, err := isErrorHandling(&g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("%q: got %v, want %v", tc.body, got, tc.want)
+		}
+	}
+
+	// All the fragments must handle errors.
+	check := bodyFragment(t, "check.go", "package p\n\nfunc f() error {\n\tif err != nil {\n\t\treturn err\n\t}\n}\n")
+	other := bodyFragment(t, "other.go", "package p\n\nfunc f() error {\n\tif x != nil {\n\t\treturn x\n\t}\n}\n")
+	g := printer.Group{Frags: []printer.Fragment{check, other}}
+	if ok, err := isErrorHandling(&g); err != nil || ok {
+		t.Errorf("a group with a fragment not handling errors: got %v, %v; want false", ok, err)
+	}
+}
+
+func TestErrorBlocksFlag(t *testing.T) {
+	for _, mode := range []string{"report", "summarize", "ignore"} {
+		var f errorBlocksFlag
+		if err := f.Set(mode); err != nil || string(f) != mode {
+			t.Errorf("Set(%q): got %q, %v", mode, f, err)
+		}
+	}
+	var f errorBlocksFlag
+	if err := f.Set("hide"); err == nil {
+		t.Error("Set(\"hide\") accepted")
+	}
+}
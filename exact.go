@@ -0,0 +1,76 @@
+package main
+
+import (
+	"go/scanner"
+	"go/token"
+
+	"github.com/mibk/dupl/printer"
+)
+
+// markExact sets g.Exact if the source texts of all fragments of the
+// group differ at most in whitespace.
+func markExact(g *printer.Group) error {
+	exact, err := isExact(*g)
+	if err != nil {
+		return err
+	}
+	g.Exact = exact
+	return nil
+}
+
+// isExact reports whether the source texts of all fragments of the
+// group differ at most in whitespace.
+func isExact(g printer.Group) (bool, error) {
+	if g.Exact {
+		return true, nil
+	}
+	var first []string
+	for i, frag := range g.Frags {
+		file, err := readFile(frag.Filename)
+		if err != nil {
+			return false, err
+		}
+		toks := sourceTokens(file[frag.Pos:frag.End])
+		if i == 0 {
+			first = toks
+		} else if !equalStrings(toks, first) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// sourceTokens returns the Go tokens of the source with their texts,
+// the comments included, so that two sources have the same tokens if
+// they differ at most in whitespace. The semicolons inserted at the
+// ends of the lines are left out, like the line breaks.
+func sourceTokens(src []byte) []string {
+	var s scanner.Scanner
+	fset := token.NewFileSet()
+	s.Init(fset.AddFile("", fset.Base(), len(src)), src, nil, scanner.ScanComments)
+	var toks []string
+	for {
+		_, tok, lit := s.Scan()
+		switch {
+		case tok == token.EOF:
+			return toks
+		case tok == token.SEMICOLON && lit != ";":
+		case lit != "":
+			toks = append(toks, lit)
+		default:
+			toks = append(toks, tok.String())
+		}
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
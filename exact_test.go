@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mibk/dupl/printer"
+)
+
+func TestIsExact(t *testing.T) {
+	testCases := []struct {
+		name string
+		a, b string
+		want bool
+	}{
+		{"same", "x := a + b", "x := a + b", true},
+		{"spaces around an operator", "x := a+b", "x := a + b", true},
+		{"indentation", "if a {\n\tf()\n}", "if a {\n        f()\n}", true},
+		{"line breaks", "f(a,\n\tb)", "f(a, b)", true},
+		{"other names", "x := a + b", "x := c + d", false},
+		{"other literals", `f("a")`, `f("b")`, false},
+		{"other comment", "f() // a", "f() // b", false},
+		{"comment left out", "f() // a", "f()", false},
+		{"spaces in a string", `f("a b")`, `f("a  b")`, false},
+		{"semicolon for a line break", "f(); g()", "f()\ng()", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			addMemFile(t, "a.go", tc.a)
+			addMemFile(t, "b.go", tc.b)
+			g := printer.Group{Frags: []printer.Fragment{
+				{Filename: "a.go", Pos: 0, End: len(tc.a)},
+				{Filename: "b.go", Pos: 0, End: len(tc.b)},
+			}}
+			got, err := isExact(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("isExact(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+			}
+		})
+	}
+}
+
+// TestReportMarksExact checks that the groups are classified without
+// -exact-only too, which only drops those that are not exact.
+func TestReportMarksExact(t *testing.T) {
+	const (
+		a = "package p\n\nfunc f() {\n\tx := a+b\n\tprintln(x)\n}\n"
+		b = "package p\n\nfunc g() {\n\tx := a + b\n\tprintln(x)\n}\n"
+		c = "package p\n\nfunc h() {\n\ty := c + d\n\tprintln(y)\n}\n"
+	)
+	addMemFile(t, "a.go", a)
+	addMemFile(t, "b.go", b)
+	addMemFile(t, "c.go", c)
+	group := func(names ...string) *printer.Group {
+		srcs := map[string]string{"a.go": a, "b.go": b, "c.go": c}
+		g := new(printer.Group)
+		for _, name := range names {
+			g.Frags = append(g.Frags, fixFragment(t, name, srcs[name], "\t", ")\n"))
+		}
+		return g
+	}
+	defer func(old bool) { *exactOnly = old }(*exactOnly)
+
+	testCases := []struct {
+		exactOnly bool
+		names     []string
+		report    bool
+		exact     bool
+	}{
+		{false, []string{"a.go", "b.go"}, true, true},
+		{false, []string{"a.go", "c.go"}, true, false},
+		{true, []string{"a.go", "b.go"}, true, true},
+		{true, []string{"a.go", "c.go"}, false, false},
+	}
+	for _, tc := range testCases {
+		*exactOnly = tc.exactOnly
+		g := group(tc.names...)
+		ok, err := report(g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != tc.report || g.Exact != tc.exact {
+			t.Errorf("-exact-only=%v %v: report = %v, exact = %v, want %v, %v",
+				tc.exactOnly, tc.names, ok, g.Exact, tc.report, tc.exact)
+		}
+	}
+}
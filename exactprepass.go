@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"go/scanner"
+	"go/token"
+	"sort"
+
+	"github.com/mibk/dupl/printer"
+)
+
+// exactWindowLines is the number of lines of the windows -exact-prepass
+// hashes, and so the least size of the regions it reports.
+const exactWindowLines = 10
+
+// lineWindow is a window of the lines of a file by its first line.
+type lineWindow struct {
+	file string
+	line int // 0-based
+}
+
+// exactFile is a file split into its lines, with the hashes of its
+// windows.
+type exactFile struct {
+	src    []byte
+	starts []int // byte offsets of the lines
+	hashes [][sha1.Size]byte
+}
+
+func (f *exactFile) lineEnd(i int) int {
+	if i+1 < len(f.starts) {
+		return f.starts[i+1] - 1
+	}
+	return len(bytes.TrimSuffix(f.src, []byte("\n")))
+}
+
+func (f *exactFile) blank(i int) bool {
+	return len(bytes.TrimSpace(f.src[f.starts[i]:f.lineEnd(i)])) == 0
+}
+
+// newExactFile splits the source into its lines and hashes the windows,
+// leaving the trivial ones, mostly blank lines and lone braces, unhashed.
+func newExactFile(src []byte) *exactFile {
+	f := &exactFile{src: src, starts: []int{0}}
+	for i, c := range src {
+		if c == '\n' && i+1 < len(src) {
+			f.starts = append(f.starts, i+1)
+		}
+	}
+	trivial := make([]int, len(f.starts)+1) // trivial lines before each
+	for i, start := range f.starts {
+		trivial[i+1] = trivial[i]
+		if len(bytes.TrimSpace(src[start:f.lineEnd(i)])) <= 1 {
+			trivial[i+1]++
+		}
+	}
+	for i := 0; i+exactWindowLines <= len(f.starts); i++ {
+		var sum [sha1.Size]byte
+		if trivial[i+exactWindowLines]-trivial[i] <= exactWindowLines/2 {
+			sum = sha1.Sum(src[f.starts[i]:f.lineEnd(i+exactWindowLines-1)])
+		}
+		f.hashes = append(f.hashes, sum)
+	}
+	return f
+}
+
+// findExactRegions returns the groups of the byte-identical regions of
+// at least exactWindowLines whole lines in the files. They are found by
+// hashing every window of the lines and extending the windows found more
+// than once for as long as all their copies stay the same.
+func findExactRegions(files []string) []printer.Group {
+	sorted := append([]string(nil), files...)
+	sort.Strings(sorted)
+	exact := make(map[string]*exactFile)
+	index := make(map[[sha1.Size]byte][]lineWindow)
+	for _, name := range sorted {
+		src, err := readFile(name)
+		if err != nil {
+			continue
+		}
+		f := newExactFile(src)
+		exact[name] = f
+		for i, sum := range f.hashes {
+			if sum != ([sha1.Size]byte{}) {
+				index[sum] = append(index[sum], lineWindow{name, i})
+			}
+		}
+	}
+
+	var groups []printer.Group
+	covered := make(map[lineWindow]bool)
+	for _, name := range sorted {
+		f := exact[name]
+		if f == nil {
+			continue
+		}
+		for i, sum := range f.hashes {
+			if covered[lineWindow{name, i}] || sum == ([sha1.Size]byte{}) {
+				continue
+			}
+			copies := nonOverlapping(index[sum])
+			if len(copies) < 2 {
+				continue
+			}
+			n := 1
+			for extendable(exact, copies, n) {
+				n++
+			}
+			g := printer.Group{Exact: true, Explanation: "byte-identical lines, found by -exact-prepass"}
+			for _, w := range copies {
+				cf := exact[w.file]
+				for k := 0; k < n; k++ {
+					covered[lineWindow{w.file, w.line + k}] = true
+				}
+				// The copies are the same, so are their blank lines.
+				first, last := w.line, w.line+n+exactWindowLines-2
+				for cf.blank(first) {
+					first++
+				}
+				for cf.blank(last) {
+					last--
+				}
+				g.Frags = append(g.Frags, printer.Fragment{
+					Filename: w.file,
+					Pos:      cf.starts[first],
+					End:      cf.lineEnd(last),
+				})
+			}
+			region := f.src[g.Frags[0].Pos:g.Frags[0].End]
+			sum := sha1.Sum(region)
+			g.Hash = string(sum[:])
+			g.Tokens = countTokens(region)
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+// nonOverlapping returns the windows but those overlapping a preceding
+// one in the same file, like the repeated lines of a single run.
+func nonOverlapping(ws []lineWindow) []lineWindow {
+	var kept []lineWindow
+	last := make(map[string]int)
+	for _, w := range ws {
+		if l, ok := last[w.file]; ok && w.line < l+exactWindowLines {
+			continue
+		}
+		last[w.file] = w.line
+		kept = append(kept, w)
+	}
+	return kept
+}
+
+// extendable reports whether the regions of the n windows starting at
+// the copies can take one more window: it must hash the same in all
+// of them, and not run into the next copy in the same file.
+func extendable(exact map[string]*exactFile, copies []lineWindow, n int) bool {
+	var sum [sha1.Size]byte
+	for i, w := range copies {
+		f := exact[w.file]
+		if w.line+n >= len(f.hashes) {
+			return false
+		}
+		if i+1 < len(copies) && copies[i+1].file == w.file && w.line+n+exactWindowLines > copies[i+1].line {
+			return false
+		}
+		h := f.hashes[w.line+n]
+		if h == ([sha1.Size]byte{}) || i > 0 && h != sum {
+			return false
+		}
+		sum = h
+	}
+	return true
+}
+
+// countTokens returns the number of Go tokens of the source, without
+// the comments and the semicolons inserted at the ends of the lines.
+func countTokens(src []byte) int {
+	var s scanner.Scanner
+	fset := token.NewFileSet()
+	s.Init(fset.AddFile("", fset.Base(), len(src)), src, nil, 0)
+	n := 0
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			return n
+		}
+		if tok != token.SEMICOLON || lit == ";" {
+			n++
+		}
+	}
+}
+
+// exactPrepassPrinter prints the groups of -exact-prepass right after
+// the header, as a section of their own before the token-based groups.
+type exactPrepassPrinter struct {
+	printer.Printer
+	groups []printer.Group
+}
+
+func (p *exactPrepassPrinter) PrintHeader() error {
+	if err := p.Printer.PrintHeader(); err != nil {
+		return err
+	}
+	for _, g := range p.groups {
+		if err := p.Printer.PrintClones(g); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindExactRegions(t *testing.T) {
+	var block strings.Builder
+	block.WriteString("func sum(xs []int) int {\n\ttotal := 0\n")
+	for i := 0; i < 10; i++ {
+		block.WriteString("\ttotal += xs[" + string(rune('0'+i)) + "]\n")
+	}
+	block.WriteString("\treturn total\n}\n")
+	files := map[string]string{
+		"exact/a.go": "package a\n\n" + block.String(),
+		"exact/b.go": "package b\n\n// sum is copied.\n" + block.String() + "\nvar x = 1\n",
+		"exact/c.go": "package c\n\nfunc other() {}\n",
+	}
+	var names []string
+	for name, src := range files {
+		archives.files[name] = []byte(src)
+		names = append(names, name)
+	}
+	defer func() {
+		for _, name := range names {
+			delete(archives.files, name)
+		}
+	}()
+
+	groups := findExactRegions(names)
+	if len(groups) != 1 {
+		t.Fatalf("got %d groups, want 1", len(groups))
+	}
+	g := groups[0]
+	if len(g.Frags) != 2 || !g.Exact {
+		t.Fatalf("got %d fragments, exact %t; want 2 exact ones", len(g.Frags), g.Exact)
+	}
+	for i, want := range []struct {
+		file   string
+		offset int
+	}{{"exact/a.go", 11}, {"exact/b.go", 29}} {
+		frag := g.Frags[i]
+		if frag.Filename != want.file || frag.Pos != want.offset || frag.End-frag.Pos != block.Len()-1 {
+			t.Errorf("fragment %d is %s[%d:%d], want %s[%d:%d]", i, frag.Filename, frag.Pos, frag.End, want.file, want.offset, want.offset+block.Len()-1)
+		}
+	}
+}
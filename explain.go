@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mibk/dupl/printer"
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+// explain returns a one-line rationale of why the fragments of the
+// group are clones.
+func explain(g *printer.Group) (string, error) {
+	ignored := []string{"identifier names", "literal values"}
+	switch golang.Normalization(normalize) {
+	case golang.NormalizeLocals:
+		ignored[0] = "the names declared in the file"
+	case golang.NormalizeTypes:
+		ignored[0] = "the names declared in the file but those of types"
+	case golang.NormalizeNone:
+		ignored = ignored[1:]
+	}
+	if *tables {
+		ignored = []string{"all identifiers and literals"}
+	}
+	if *normReceivers {
+		ignored = append(ignored, "method receivers")
+	}
+	if *genericsAware {
+		ignored = append(ignored, "types")
+	}
+	if *reorderTolerant {
+		ignored = append(ignored, "the order of independent statements")
+	}
+	exact, err := isExact(*g)
+	if err != nil {
+		return "", err
+	}
+	kind := "a type-2 clone: the fragments have the same structure but may differ in the ignored details"
+	if exact {
+		kind = "a type-1 clone: the fragments are the same text, differing at most in whitespace"
+	}
+	return fmt.Sprintf("These %d fragments share the same sequence of %d syntax tokens, ignoring %s; %s.",
+		len(g.Frags), g.Tokens, joinList(ignored), kind), nil
+}
+
+// joinList joins the items into an English list.
+func joinList(items []string) string {
+	if len(items) < 2 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " and " + items[len(items)-1]
+}
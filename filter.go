@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mibk/dupl/printer"
+	"github.com/mibk/dupl/syntax"
+)
+
+// report reports whether the clone group passes all the filters.
+// The filters may annotate the fragments of the group.
+func report(g *printer.Group) (bool, error) {
+	if dirtyFiles != nil && !hasDirtyFrag(g) {
+		return reject(g, "no fragment in a file changed in the working tree")
+	}
+	if len(thresholds) > 0 || len(pathThresholds) > 0 {
+		if t := groupThreshold(g); g.Tokens < t {
+			return reject(g, fmt.Sprintf("fewer than the %d tokens of its threshold", t))
+		}
+	}
+	if allowPairs.allows(g.Frags) {
+		return reject(g, "within an allowed directory pair")
+	}
+	if *vendorPairs {
+		keepVendorPairs(g)
+		if len(g.Frags) < 2 {
+			return reject(g, "no vendored file paired with a file out of vendor")
+		}
+	}
+	if *crossModule {
+		root := moduleRoot(g.Frags[0].Filename)
+		single := true
+		for _, frag := range g.Frags[1:] {
+			if moduleRoot(frag.Filename) != root {
+				single = false
+				break
+			}
+		}
+		if single {
+			return reject(g, "within a single module")
+		}
+	}
+	if len(ignoreDirs) > 0 {
+		frags := g.Frags[:0]
+		for _, frag := range g.Frags {
+			if !ignoreDirs.contains(frag.Filename) {
+				frags = append(frags, frag)
+			}
+		}
+		g.Frags = frags
+		if len(g.Frags) < 2 {
+			return reject(g, "fragments in ignored directories")
+		}
+	}
+	if len(excludeLines) > 0 {
+		frags := g.Frags[:0]
+		for _, frag := range g.Frags {
+			excluded, err := excludeLines.excludes(frag)
+			if err != nil {
+				return false, err
+			}
+			if !excluded {
+				frags = append(frags, frag)
+			}
+		}
+		g.Frags = frags
+		if len(g.Frags) < 2 {
+			return reject(g, "fragments in excluded line ranges")
+		}
+	}
+	if len(excludeFuncs) > 0 {
+		if err := dropExcludedFuncs(g); err != nil {
+			return false, err
+		}
+		if len(g.Frags) < 2 {
+			return reject(g, "fragments in excluded functions")
+		}
+	}
+	if len(ignorePatterns) > 0 {
+		frag := g.Frags[representative(g)]
+		file, err := readFile(frag.Filename)
+		if err != nil {
+			return false, err
+		}
+		if ignorePatterns.matchSource(file[frag.Pos:frag.End]) {
+			return reject(g, "source matches an ignored pattern")
+		}
+	}
+	if *constBlocks {
+		if inSinglePackage(g) {
+			return reject(g, "within a single package")
+		}
+		if err := nameConsts(g); err != nil {
+			return false, err
+		}
+	}
+	if *testSetup {
+		if err := nameTests(g); err != nil {
+			return false, err
+		}
+	}
+	if *switchCases {
+		ok, err := caseBodies(g)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return reject(g, "not bodies of case clauses")
+		}
+	}
+	if errorBlocksMode != "report" {
+		ok, err := isErrorHandling(g)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			if errorBlocksMode == "summarize" {
+				errorBlocks.groups++
+				errorBlocks.frags += len(g.Frags)
+			}
+			return reject(g, "error handling")
+		}
+	}
+	if *minTokenKinds > 1 {
+		// All fragments of a group have the same structure.
+		if n := tokenKinds(g.Frags[0].Nodes); n < *minTokenKinds {
+			return reject(g, fmt.Sprintf("only %d distinct token kinds", n))
+		}
+	}
+	if *maxSpanLines > 0 {
+		for _, frag := range g.Frags {
+			n, err := spanLines(frag)
+			if err != nil {
+				return false, err
+			}
+			if n > *maxSpanLines {
+				return reject(g, fmt.Sprintf("a fragment spans %d lines", n))
+			}
+		}
+	}
+	if err := markExact(g); err != nil {
+		return false, err
+	}
+	if *exactOnly && !g.Exact {
+		return reject(g, "fragments differ in more than whitespace")
+	}
+	if err := annotateFuncs(g); err != nil {
+		return false, err
+	}
+	if err := annotateScopes(g); err != nil {
+		return false, err
+	}
+	if *blameAuthors {
+		if err := annotateAuthors(g); err != nil {
+			return false, err
+		}
+	}
+	if *similarity {
+		if err := scoreSimilarity(g); err != nil {
+			return false, err
+		}
+	}
+	if *templateView {
+		if err := shapeGroup(g); err != nil {
+			return false, err
+		}
+	}
+	if *explainGroups {
+		e, err := explain(g)
+		if err != nil {
+			return false, err
+		}
+		g.Explanation = e
+	}
+	return true, nil
+}
+
+// spanLines returns the number of lines the fragment spans.
+func spanLines(frag printer.Fragment) (int, error) {
+	file, err := readFile(frag.Filename)
+	if err != nil {
+		return 0, err
+	}
+	return bytes.Count(file[frag.Pos:frag.End], []byte{'\n'}) + 1, nil
+}
+
+// collapseClose drops the fragments of the group starting less than gap
+// lines after the end of a preceding fragment in the same file, including
+// the overlapping ones.
+func collapseClose(g *printer.Group, gap int) error {
+	type span struct {
+		frag       printer.Fragment
+		start, end int
+	}
+	spans := make([]span, len(g.Frags))
+	for i, frag := range g.Frags {
+		file, err := readFile(frag.Filename)
+		if err != nil {
+			return err
+		}
+		start := bytes.Count(file[:frag.Pos], []byte{'\n'}) + 1
+		end := start + bytes.Count(file[frag.Pos:frag.End], []byte{'\n'})
+		spans[i] = span{frag, start, end}
+	}
+	sort.SliceStable(spans, func(i, j int) bool {
+		if spans[i].frag.Filename != spans[j].frag.Filename {
+			return spans[i].frag.Filename < spans[j].frag.Filename
+		}
+		return spans[i].start < spans[j].start
+	})
+	frags := g.Frags[:0]
+	var last span
+	for i, s := range spans {
+		if i > 0 && s.frag.Filename == last.frag.Filename && s.start-last.end-1 < gap {
+			continue
+		}
+		frags = append(frags, s.frag)
+		last = s
+	}
+	g.Frags = frags
+	return nil
+}
+
+// tokenKinds returns the number of distinct node types in the syntax
+// units rooted at nodes.
+func tokenKinds(nodes []*syntax.Node) int {
+	kinds := make(map[int]struct{})
+	var walk func(n *syntax.Node)
+	walk = func(n *syntax.Node) {
+		kinds[n.Type] = struct{}{}
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	for _, n := range nodes {
+		walk(n)
+	}
+	return len(kinds)
+}
+
+// regexps is a repeatable flag of regular expressions.
+type regexps []*regexp.Regexp
+
+func (r *regexps) String() string {
+	var s []string
+	for _, re := range *r {
+		s = append(s, re.String())
+	}
+	return strings.Join(s, ",")
+}
+
+func (r *regexps) Set(value string) error {
+	re, err := regexp.Compile(value)
+	if err != nil {
+		return err
+	}
+	*r = append(*r, re)
+	return nil
+}
+
+func (r regexps) match(s string) bool {
+	for _, re := range r {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r regexps) matchSource(src []byte) bool {
+	for _, re := range r {
+		if re.Match(src) {
+			return true
+		}
+	}
+	return false
+}
+
+// dropExcludedFuncs removes the fragments found in functions whose
+// names match any of the excluded patterns.
+func dropExcludedFuncs(g *printer.Group) error {
+	frags := g.Frags[:0]
+	for _, frag := range g.Frags {
+		decl := enclosingFunc(frag.Nodes[0])
+		if decl != nil {
+			file, err := readFile(frag.Filename)
+			if err != nil {
+				return err
+			}
+			if excludeFuncs.match(funcName(decl, file)) {
+				continue
+			}
+		}
+		frags = append(frags, frag)
+	}
+	g.Frags = frags
+	return nil
+}
+
+// dirPairs is a repeatable flag of directory pairs that may duplicate
+// each other, each given as dirA:dirB.
+type dirPairs [][2]string
+
+func (p *dirPairs) String() string {
+	var s []string
+	for _, pair := range *p {
+		s = append(s, pair[0]+":"+pair[1])
+	}
+	return strings.Join(s, ",")
+}
+
+func (p *dirPairs) Set(value string) error {
+	i := strings.LastIndex(value, ":")
+	if i <= 0 || i == len(value)-1 {
+		return fmt.Errorf("directory pair must be dirA:dirB, got %q", value)
+	}
+	*p = append(*p, [2]string{filepath.Clean(value[:i]), filepath.Clean(value[i+1:])})
+	return nil
+}
+
+// allows reports whether all fragments of the group lie within
+// the directories of a single pair.
+func (p dirPairs) allows(frags []printer.Fragment) bool {
+Pairs:
+	for _, pair := range p {
+		for _, frag := range frags {
+			if !inDir(frag.Filename, pair[0]) && !inDir(frag.Filename, pair[1]) {
+				continue Pairs
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// inDir reports whether file is located in dir or any of its
+// subdirectories.
+func inDir(file, dir string) bool {
+	rel, err := filepath.Rel(foldPath(dir), foldPath(file))
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// splitByDir splits the group into the groups of its fragments in the
+// same directory, dropping the fragments alone in their directories.
+func splitByDir(g printer.Group) []printer.Group {
+	var dirs []string
+	frags := make(map[string][]printer.Fragment)
+	for _, frag := range g.Frags {
+		dir := foldPath(filepath.Dir(frag.Filename))
+		if _, ok := frags[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		frags[dir] = append(frags[dir], frag)
+	}
+	var gs []printer.Group
+	for _, dir := range dirs {
+		sub := g
+		sub.Frags = frags[dir]
+		if len(sub.Frags) < 2 {
+			reject(&sub, "no other fragment in its directory")
+			continue
+		}
+		gs = append(gs, sub)
+	}
+	return gs
+}
+
+// groupDir returns the directory of the first fragment of the group.
+func groupDir(g printer.Group) string {
+	return foldPath(filepath.Dir(g.Frags[0].Filename))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mibk/dupl/printer"
+)
+
+func TestCollapseClose(t *testing.T) {
+	var src strings.Builder
+	for i := 1; i <= 20; i++ {
+		fmt.Fprintf(&src, "line%d\n", i)
+	}
+	addMemFile(t, "a.go", src.String())
+	addMemFile(t, "b.go", src.String())
+	type lines struct {
+		file       string
+		start, end int
+	}
+
+	testCases := []struct {
+		name  string
+		frags []lines
+		want  []lines
+	}{
+		{"far enough", []lines{{"a.go", 1, 3}, {"a.go", 7, 9}}, []lines{{"a.go", 1, 3}, {"a.go", 7, 9}}},
+		{"too close", []lines{{"a.go", 1, 3}, {"a.go", 6, 8}}, []lines{{"a.go", 1, 3}}},
+		{"adjacent", []lines{{"a.go", 1, 3}, {"a.go", 4, 6}}, []lines{{"a.go", 1, 3}}},
+		{"overlapping", []lines{{"a.go", 1, 5}, {"a.go", 3, 7}}, []lines{{"a.go", 1, 5}}},
+		{"other file", []lines{{"a.go", 1, 3}, {"b.go", 2, 4}}, []lines{{"a.go", 1, 3}, {"b.go", 2, 4}}},
+		{"out of order", []lines{{"a.go", 6, 8}, {"b.go", 1, 3}, {"a.go", 1, 3}}, []lines{{"a.go", 1, 3}, {"b.go", 1, 3}}},
+		// A dropped fragment does not push the next one away.
+		{"after a dropped one", []lines{{"a.go", 1, 3}, {"a.go", 5, 6}, {"a.go", 7, 8}}, []lines{{"a.go", 1, 3}, {"a.go", 7, 8}}},
+	}
+	for _, tc := range testCases {
+		var g printer.Group
+		for _, l := range tc.frags {
+			g.Frags = append(g.Frags, lineFragment(l.file, src.String(), l.start, l.end))
+		}
+		if err := collapseClose(&g, 3); err != nil {
+			t.Fatal(err)
+		}
+		var want []printer.Fragment
+		for _, l := range tc.want {
+			want = append(want, lineFragment(l.file, src.String(), l.start, l.end))
+		}
+		if !reflect.DeepEqual(g.Frags, want) {
+			t.Errorf("%s: got %v, want %v", tc.name, g.Frags, want)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+// Package fingerprint reads and writes sets of syntax unit fingerprints.
+//
+// A fingerprint file of version 1 starts with the line
+// "dupl fingerprints 1", followed by the number of fingerprints and the
+// fingerprints themselves, each prefixed by its length.
+//
+// A file of version 2 starts with the line "dupl fingerprints 2" and
+// also records where the units are. The fingerprints follow like in
+// version 1, sorted, and then the number of files, and for each file
+// its name, prefixed by its length, the number of its units, and for
+// each unit the index of its fingerprint among those listed, its first
+// line, the number of its lines but the first, and the number of its
+// tokens. The units of a file are in the order of their positions.
+//
+// All numbers are unsigned varints. The readers accept both versions.
+package fingerprint
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+const (
+	magic  = "dupl fingerprints 1\n"
+	magic2 = "dupl fingerprints 2\n"
+)
+
+// maxNameLen limits the length of a file name when reading.
+const maxNameLen = 1 << 12
+
+// maxCount limits the number of fingerprints, files, and units of
+// a file when reading, so that a corrupt count fails rather than
+// allocating too much.
+const maxCount = 1 << 30
+
+// maxLen limits the length of a single fingerprint when reading.
+const maxLen = 1 << 10
+
+// Set is a set of fingerprints.
+type Set map[string]struct{}
+
+// Add adds the fingerprint hash to the set.
+func (s Set) Add(hash string) { s[hash] = struct{}{} }
+
+// Has reports whether the set contains the fingerprint hash.
+func (s Set) Has(hash string) bool {
+	_, ok := s[hash]
+	return ok
+}
+
+// Write writes the set to w, sorted in order to produce the same
+// output for the same set.
+func Write(w io.Writer, s Set) error {
+	hashes := make([]string, 0, len(s))
+	for h := range s {
+		hashes = append(hashes, h)
+	}
+	sort.Strings(hashes)
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(magic)
+	writeUvarint(bw, uint64(len(hashes)))
+	for _, h := range hashes {
+		writeUvarint(bw, uint64(len(h)))
+		bw.WriteString(h)
+	}
+	return bw.Flush()
+}
+
+func writeUvarint(w *bufio.Writer, x uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], x)
+	w.Write(buf[:n])
+}
+
+// Unit is a syntax unit of a file by its fingerprint.
+type Unit struct {
+	Hash               string
+	LineStart, LineEnd int
+	Tokens             int
+}
+
+// File is a file by its units.
+type File struct {
+	Name  string
+	Units []Unit
+}
+
+// WriteFiles writes the files, with the units sorted by position,
+// to w in the format of version 2.
+func WriteFiles(w io.Writer, files []File) error {
+	s := make(Set)
+	for _, f := range files {
+		for _, u := range f.Units {
+			s.Add(u.Hash)
+		}
+	}
+	hashes := make([]string, 0, len(s))
+	for h := range s {
+		hashes = append(hashes, h)
+	}
+	sort.Strings(hashes)
+	index := make(map[string]int, len(hashes))
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(magic2)
+	writeUvarint(bw, uint64(len(hashes)))
+	for i, h := range hashes {
+		index[h] = i
+		writeUvarint(bw, uint64(len(h)))
+		bw.WriteString(h)
+	}
+	writeUvarint(bw, uint64(len(files)))
+	for _, f := range files {
+		units := append([]Unit(nil), f.Units...)
+		sort.SliceStable(units, func(i, j int) bool {
+			if units[i].LineStart != units[j].LineStart {
+				return units[i].LineStart < units[j].LineStart
+			}
+			return units[i].LineEnd > units[j].LineEnd
+		})
+		writeUvarint(bw, uint64(len(f.Name)))
+		bw.WriteString(f.Name)
+		writeUvarint(bw, uint64(len(units)))
+		for _, u := range units {
+			if u.LineEnd < u.LineStart {
+				return fmt.Errorf("%s: unit ending at line %d before its start at line %d", f.Name, u.LineEnd, u.LineStart)
+			}
+			writeUvarint(bw, uint64(index[u.Hash]))
+			writeUvarint(bw, uint64(u.LineStart))
+			writeUvarint(bw, uint64(u.LineEnd-u.LineStart))
+			writeUvarint(bw, uint64(u.Tokens))
+		}
+	}
+	return bw.Flush()
+}
+
+// Read reads the set of the fingerprints of a file of either version
+// from r.
+func Read(r io.Reader) (Set, error) {
+	s, _, err := read(r)
+	return s, err
+}
+
+// ReadFiles reads the files of a file of version 2 from r. The files of
+// version 1 do not have them.
+func ReadFiles(r io.Reader) ([]File, error) {
+	_, files, err := read(r)
+	if err == nil && files == nil {
+		err = errors.New("fingerprint file of version 1 without the positions of the units")
+	}
+	return files, err
+}
+
+func read(r io.Reader) (Set, []File, error) {
+	br := bufio.NewReader(r)
+	head := make([]byte, len(magic))
+	if _, err := io.ReadFull(br, head); err != nil || string(head) != magic && string(head) != magic2 {
+		return nil, nil, errors.New("not a dupl fingerprint file")
+	}
+	cnt, err := readCount(br)
+	if err != nil {
+		return nil, nil, err
+	}
+	s := make(Set)
+	var hashes []string
+	for i := uint64(0); i < cnt; i++ {
+		h, err := readString(br, maxLen, errors.New("fingerprint too long"))
+		if err != nil {
+			return nil, nil, err
+		}
+		s.Add(h)
+		hashes = append(hashes, h)
+	}
+	if string(head) == magic {
+		return s, nil, nil
+	}
+
+	nfiles, err := readCount(br)
+	if err != nil {
+		return nil, nil, err
+	}
+	files := make([]File, 0, nfiles)
+	for i := uint64(0); i < nfiles; i++ {
+		var f File
+		if f.Name, err = readString(br, maxNameLen, errors.New("file name too long")); err != nil {
+			return nil, nil, err
+		}
+		nunits, err := readCount(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		for j := uint64(0); j < nunits; j++ {
+			var v [4]uint64
+			for k := range v {
+				if v[k], err = binary.ReadUvarint(br); err != nil {
+					return nil, nil, err
+				}
+			}
+			if v[0] >= uint64(len(hashes)) {
+				return nil, nil, fmt.Errorf("%s: fingerprint %d out of %d", f.Name, v[0], len(hashes))
+			}
+			f.Units = append(f.Units, Unit{
+				Hash:      hashes[v[0]],
+				LineStart: int(v[1]),
+				LineEnd:   int(v[1] + v[2]),
+				Tokens:    int(v[3]),
+			})
+		}
+		files = append(files, f)
+	}
+	return s, files, nil
+}
+
+func readCount(br *bufio.Reader) (uint64, error) {
+	n, err := binary.ReadUvarint(br)
+	if err == nil && n > maxCount {
+		err = errors.New("fingerprint file with too many entries")
+	}
+	return n, err
+}
+
+// readString reads a string prefixed by its length, failing with
+// the error tooLong for those of more than max bytes.
+func readString(br *bufio.Reader, max uint64, tooLong error) (string, error) {
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return "", err
+	}
+	if n > max {
+		return "", tooLong
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(br, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
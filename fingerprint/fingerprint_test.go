@@ -0,0 +1,82 @@
+package fingerprint
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestReadWrite(t *testing.T) {
+	s := make(Set)
+	s.Add("abc")
+	s.Add("\x00\xff\x10")
+	s.Add("")
+
+	var buf bytes.Buffer
+	if err := Write(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(s) {
+		t.Fatalf("got %d fingerprints, want %d", len(got), len(s))
+	}
+	for h := range s {
+		if !got.Has(h) {
+			t.Errorf("missing fingerprint %q", h)
+		}
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	if _, err := Read(bytes.NewBufferString("something else")); err == nil {
+		t.Error("expected an error for invalid input")
+	}
+}
+
+func TestReadWriteFiles(t *testing.T) {
+	files := []File{
+		{"a.go", []Unit{{"def", 10, 12, 30}, {"abc", 3, 20, 90}}},
+		{"b.go", []Unit{{"abc", 7, 24, 90}}},
+		{"c.go", nil},
+	}
+	var buf bytes.Buffer
+	if err := WriteFiles(&buf, files); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	got, err := ReadFiles(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []File{
+		{"a.go", []Unit{{"abc", 3, 20, 90}, {"def", 10, 12, 30}}},
+		{"b.go", []Unit{{"abc", 7, 24, 90}}},
+		{"c.go", nil},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	s, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s) != 2 || !s.Has("abc") || !s.Has("def") {
+		t.Errorf("got the set %v, want abc and def", s)
+	}
+}
+
+func TestReadFilesVersion1(t *testing.T) {
+	s := make(Set)
+	s.Add("abc")
+	var buf bytes.Buffer
+	if err := Write(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadFiles(&buf); err == nil {
+		t.Error("expected an error for a file without the positions")
+	}
+}
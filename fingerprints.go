@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/mibk/dupl/fingerprint"
+	"github.com/mibk/dupl/printer"
+	"github.com/mibk/dupl/syntax"
+)
+
+// exportFingerprintsTo writes the fingerprints of all syntax units
+// of the scanned files, with the files and the lines they are found at,
+// into the file path.
+func exportFingerprintsTo(path string, data []*syntax.Node) error {
+	var files []fingerprint.File
+	byName := make(map[string]int)
+	starts := make(map[string][]int) // offsets of the lines of the files
+	for _, u := range syntax.Units(data, *fromThreshold) {
+		n := u.Nodes[0]
+		i, ok := byName[n.Filename]
+		if !ok {
+			src, err := readFile(n.Filename)
+			if err != nil {
+				return err
+			}
+			starts[n.Filename] = lineStarts(src)
+			i = len(files)
+			byName[n.Filename] = i
+			files = append(files, fingerprint.File{Name: n.Filename})
+		}
+		ls := starts[n.Filename]
+		files[i].Units = append(files[i].Units, fingerprint.Unit{
+			Hash:      u.Hash,
+			LineStart: sort.SearchInts(ls, n.Pos+1),
+			LineEnd:   sort.SearchInts(ls, n.End),
+			Tokens:    len(u.Nodes),
+		})
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := fingerprint.WriteFiles(f, files); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// lineStarts returns the offsets of the lines of the source.
+func lineStarts(src []byte) []int {
+	starts := []int{0}
+	for i, c := range src {
+		if c == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
+
+// printReferenceMatches prints the syntax units of the scanned files
+// whose fingerprints are found in the reference file path. Units nested
+// in an already matched unit are not reported separately.
+func printReferenceMatches(p printer.Printer, path string, data []*syntax.Node, totals func() printer.Totals) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	ref, err := fingerprint.Read(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	size := len(syntax.SeqHasher.Sum(nil))
+	for h := range ref {
+		if len(h) != size {
+			return fmt.Errorf("%s: the fingerprints are of %d bytes, those of -hash %s of %d; export them with the same -hash",
+				path, len(h), hashName.String(), size)
+		}
+		break
+	}
+
+	groups := make(map[string]*syntax.Match)
+	var keys []string
+	end := 0
+	for _, u := range syntax.Units(data, *fromThreshold) {
+		if u.Index < end || !ref.Has(u.Hash) {
+			continue
+		}
+		end = u.Index + len(u.Nodes)
+		m, ok := groups[u.Hash]
+		if !ok {
+			m = &syntax.Match{Hash: u.Hash, Tokens: len(u.Nodes)}
+			groups[u.Hash] = m
+			keys = append(keys, u.Hash)
+		}
+		m.Frags = append(m.Frags, u.Nodes[:1])
+	}
+
+	if err := p.PrintHeader(); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := p.PrintClones(printer.NewGroup(*groups[k])); err != nil {
+			return err
+		}
+	}
+	return p.PrintFooter(totals())
+}
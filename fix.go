@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mibk/dupl/printer"
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+// fixContext is the number of context lines in the suggested patches.
+const fixContext = 3
+
+// fixPrinter writes, for each clone group that can obviously be safely
+// extracted into a helper function, a patch doing so. The other groups
+// are skipped.
+type fixPrinter struct {
+	w            io.Writer
+	groups, done int
+}
+
+func newFixPrinter(w io.Writer, _ printer.ReadFile, _ printer.Options) printer.Printer {
+	return &fixPrinter{w: w}
+}
+
+func (p *fixPrinter) PrintHeader() error { return nil }
+
+func (p *fixPrinter) PrintClones(g printer.Group) error {
+	p.groups++
+	patch, reason, err := suggestFix(g)
+	if err != nil {
+		return err
+	}
+	if patch == "" {
+		tracef("no fix suggested for group %s: %s", g.ID(), reason)
+		return nil
+	}
+	p.done++
+	fmt.Fprintf(p.w, "# dupl: best-effort suggestion for clone group %s; review it before applying\n", g.ID())
+	_, err = io.WriteString(p.w, patch)
+	return err
+}
+
+func (p *fixPrinter) PrintFooter(printer.Totals) error {
+	_, err := fmt.Fprintf(p.w, "# dupl: suggested fixes for %d of %d clone groups\n", p.done, p.groups)
+	return err
+}
+
+// fixFrag is a fragment prepared for the extraction.
+type fixFrag struct {
+	filename     string
+	lines        []string // lines of the file, without newlines
+	first, last  int      // 0-based lines of the fragment
+	indent, code string   // code is the fragment, without indent
+}
+
+// suggestFix returns a patch extracting the fragments of the group into
+// a new function, or the reason why it is not obviously safe.
+func suggestFix(g printer.Group) (patch, reason string, err error) {
+	exact, err := isExact(g)
+	if err != nil {
+		return "", "", err
+	}
+	if !exact {
+		return "", "the fragments differ; the helper would need parameters", nil
+	}
+	var frags []fixFrag
+	pkg := ""
+	for _, frag := range g.Frags {
+		for _, n := range frag.Nodes {
+			if !golang.IsStmt(n.Type) {
+				return "", "not a sequence of statements", nil
+			}
+		}
+		if filepath.Dir(frag.Filename) != filepath.Dir(g.Frags[0].Filename) {
+			return "", "fragments in several directories", nil
+		}
+		src, err := readFile(frag.Filename)
+		if err != nil {
+			return "", "", err
+		}
+		name, reason, err := checkExtractable(frag, src)
+		if err != nil || reason != "" {
+			return "", reason, err
+		}
+		if pkg != "" && name != pkg {
+			return "", "fragments in several packages", nil
+		}
+		pkg = name
+		ff, ok := newFixFrag(frag, src)
+		if !ok {
+			return "", "fragments do not span whole lines", nil
+		}
+		frags = append(frags, ff)
+	}
+	if name := overlapping(frags); name != "" {
+		return "", "fragments overlap in " + name + "; their edits would conflict", nil
+	}
+
+	helper := "duplHelper" + g.ID()[:8]
+	edits := make(map[string][]fixEdit)
+	for _, f := range frags {
+		edits[f.filename] = append(edits[f.filename], fixEdit{
+			first: f.first, last: f.last,
+			lines: []string{f.indent + helper + "()"},
+		})
+	}
+	def := []string{
+		"",
+		fmt.Sprintf("// %s was extracted by dupl -suggest-fix from the clone group %s.", helper, g.ID()),
+		"// TODO: give it a descriptive name.",
+		fmt.Sprintf("func %s() {", helper),
+	}
+	for _, line := range strings.Split(frags[0].code, "\n") {
+		if line == "" {
+			def = append(def, "")
+		} else {
+			def = append(def, "\t"+line)
+		}
+	}
+	def = append(def, "}")
+	first := frags[0]
+	edits[first.filename] = append(edits[first.filename], fixEdit{
+		first: len(first.lines), last: len(first.lines) - 1, lines: def,
+	})
+
+	var b strings.Builder
+	files := make([]string, 0, len(edits))
+	for name := range edits {
+		files = append(files, name)
+	}
+	sort.Strings(files)
+	for _, name := range files {
+		var lines []string
+		for _, f := range frags {
+			if f.filename == name {
+				lines = f.lines
+				break
+			}
+		}
+		writePatch(&b, name, lines, edits[name])
+	}
+	return b.String(), "", nil
+}
+
+// overlapping returns the name of a file in which two of the fragments
+// share a line, or "" if there is none.
+func overlapping(frags []fixFrag) string {
+	sorted := append([]fixFrag(nil), frags...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].filename != sorted[j].filename {
+			return sorted[i].filename < sorted[j].filename
+		}
+		return sorted[i].first < sorted[j].first
+	})
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].filename == sorted[i-1].filename && sorted[i].first <= sorted[i-1].last {
+			return sorted[i].filename
+		}
+	}
+	return ""
+}
+
+// checkExtractable reports why the fragment cannot be moved into
+// a function without parameters, if so, and returns its package name.
+func checkExtractable(frag printer.Fragment, src []byte) (pkg, reason string, err error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, frag.Filename, src, 0)
+	if err != nil {
+		return "", "", err
+	}
+	tf := fset.File(file.Pos())
+	from, to := tf.Pos(frag.Pos), tf.Pos(frag.End)
+	var fn *ast.FuncDecl
+	for _, d := range file.Decls {
+		if d, ok := d.(*ast.FuncDecl); ok && d.Pos() <= from && to <= d.End() {
+			fn = d
+		}
+	}
+	if fn == nil {
+		return "", "not in a function", nil
+	}
+	inFrag := func(p token.Pos) bool { return from <= p && p < to }
+	ast.Inspect(fn, func(n ast.Node) bool {
+		if reason != "" || n == nil {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.ReturnStmt, *ast.BranchStmt, *ast.LabeledStmt, *ast.DeferStmt:
+			if inFrag(n.Pos()) {
+				reason = "the fragments change the control flow"
+			}
+		case *ast.Ident:
+			if n.Obj == nil || n.Obj.Decl == nil {
+				return true
+			}
+			decl, ok := n.Obj.Decl.(ast.Node)
+			if !ok || decl.Pos() < fn.Pos() || fn.End() <= decl.Pos() {
+				// declared outside the function
+				return true
+			}
+			if inFrag(n.Pos()) != inFrag(decl.Pos()) {
+				reason = fmt.Sprintf("the fragments share the variable %s with their function", n.Name)
+			}
+		}
+		return true
+	})
+	return file.Name.Name, reason, nil
+}
+
+func newFixFrag(frag printer.Fragment, src []byte) (fixFrag, bool) {
+	f := fixFrag{filename: frag.Filename, lines: strings.Split(string(src), "\n")}
+	if len(f.lines) > 0 && f.lines[len(f.lines)-1] == "" {
+		f.lines = f.lines[:len(f.lines)-1]
+	}
+	start := bytes.LastIndexByte(src[:frag.Pos], '\n') + 1
+	end := frag.End + bytes.IndexByte(src[frag.End:], '\n')
+	if end < frag.End {
+		end = len(src)
+	}
+	if len(bytes.TrimSpace(src[start:frag.Pos])) > 0 || len(bytes.TrimSpace(src[frag.End:end])) > 0 {
+		return f, false
+	}
+	f.first = bytes.Count(src[:start], []byte{'\n'})
+	f.last = f.first + bytes.Count(src[start:end], []byte{'\n'})
+	f.indent = string(src[start:frag.Pos])
+	var code []string
+	for _, line := range f.lines[f.first : f.last+1] {
+		code = append(code, strings.TrimPrefix(line, f.indent))
+	}
+	f.code = strings.Join(code, "\n")
+	return f, true
+}
+
+// fixEdit replaces the lines first to last, 0-based, by lines.
+// An insertion before line first has last == first-1.
+type fixEdit struct {
+	first, last int
+	lines       []string
+}
+
+// writePatch writes a unified diff of applying the edits to the file.
+func writePatch(w io.Writer, name string, lines []string, edits []fixEdit) {
+	sort.Slice(edits, func(i, j int) bool { return edits[i].first < edits[j].first })
+	fmt.Fprintf(w, "--- a/%s\n+++ b/%s\n", filepath.ToSlash(name), filepath.ToSlash(name))
+	delta := 0 // lines added minus removed by the preceding hunks
+	for i := 0; i < len(edits); {
+		// Join the edits whose context overlaps into a single hunk.
+		j := i + 1
+		for j < len(edits) && edits[j].first-edits[j-1].last-1 <= 2*fixContext {
+			j++
+		}
+		start := max(edits[i].first-fixContext, 0)
+		end := min(edits[j-1].last+fixContext, len(lines)-1)
+		var body []string
+		oldLen, newLen := 0, 0
+		line := start
+		for _, e := range edits[i:j] {
+			for ; line < e.first; line++ {
+				body = append(body, " "+lines[line])
+				oldLen++
+				newLen++
+			}
+			for ; line <= e.last; line++ {
+				body = append(body, "-"+lines[line])
+				oldLen++
+			}
+			for _, l := range e.lines {
+				body = append(body, "+"+l)
+				newLen++
+			}
+		}
+		for ; line <= end; line++ {
+			body = append(body, " "+lines[line])
+			oldLen++
+			newLen++
+		}
+		fmt.Fprintf(w, "@@ -%s +%s @@\n", hunkRange(start, oldLen), hunkRange(start+delta, newLen))
+		for _, l := range body {
+			fmt.Fprintln(w, l)
+		}
+		delta += newLen - oldLen
+		i = j
+	}
+}
+
+// hunkRange formats the range of a hunk starting at the 0-based line.
+func hunkRange(start, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
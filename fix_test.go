@@ -0,0 +1,104 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mibk/dupl/printer"
+	"github.com/mibk/dupl/syntax"
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+// fixFragment returns the fragment of the file spanning the lines of
+// the source from the first one containing from to the end of the last
+// one containing to, made of statements.
+func fixFragment(t *testing.T, name, src, from, to string) printer.Fragment {
+	t.Helper()
+	pos, end := strings.Index(src, from), strings.LastIndex(src, to)
+	if pos < 0 || end < 0 {
+		t.Fatalf("%q or %q not in %s", from, to, name)
+	}
+	return printer.Fragment{
+		Filename: name,
+		Pos:      pos,
+		End:      end + len(to),
+		Nodes:    []*syntax.Node{{Type: golang.ExprStmt}},
+	}
+}
+
+func TestSuggestFix(t *testing.T) {
+	a, b := filepath.Join("fix", "a.go"), filepath.Join("fix", "b.go")
+	srcA := "package p\n\nfunc f() {\n\tprintln(1)\n\tprintln(2)\n}\n"
+	srcB := "package p\n\nfunc g() {\n\tx := 0\n\tprintln(1)\n\tprintln(2)\n\t_ = x\n}\n"
+	addMemFile(t, a, srcA)
+	addMemFile(t, b, srcB)
+	g := printer.Group{
+		Hash: "\x01\x02\x03\x04\x05\x06\x07\x08",
+		Frags: []printer.Fragment{
+			fixFragment(t, a, srcA, "println(1)", "println(2)"),
+			fixFragment(t, b, srcB, "println(1)", "println(2)"),
+		},
+	}
+	patch, reason, err := suggestFix(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reason != "" {
+		t.Fatalf("no fix suggested: %s", reason)
+	}
+	want := "--- a/" + filepath.ToSlash(a) + "\n+++ b/" + filepath.ToSlash(a) + "\n" +
+		`@@ -1,6 +1,12 @@
+ package p
+ 
+ func f() {
+-	println(1)
+-	println(2)
++	duplHelper01020304()
+ }
++
++// duplHelper01020304 was extracted by dupl -suggest-fix from the clone group 0102030405060708.
++// TODO: give it a descriptive name.
++func duplHelper01020304() {
++	println(1)
++	println(2)
++}
+` + "--- a/" + filepath.ToSlash(b) + "\n+++ b/" + filepath.ToSlash(b) + "\n" +
+		`@@ -2,7 +2,6 @@
+ 
+ func g() {
+ 	x := 0
+-	println(1)
+-	println(2)
++	duplHelper01020304()
+ 	_ = x
+ }
+`
+	if patch != want {
+		t.Errorf("got the patch\n%s\nwant\n%s", patch, want)
+	}
+}
+
+func TestSuggestFixOverlap(t *testing.T) {
+	a := filepath.Join("fix", "a.go")
+	src := "package p\n\nfunc f() {\n\tprintln(1)\n\tprintln(1)\n\tprintln(1)\n}\n"
+	addMemFile(t, a, src)
+	// The copies at the lines 4-5 and 5-6 share the line 5.
+	code := "println(1)\n\tprintln(1)"
+	first := strings.Index(src, code)
+	second := strings.LastIndex(src, code)
+	g := printer.Group{
+		Hash: "\x01\x02\x03\x04\x05\x06\x07\x08",
+		Frags: []printer.Fragment{
+			{Filename: a, Pos: first, End: first + len(code), Nodes: []*syntax.Node{{Type: golang.ExprStmt}}},
+			{Filename: a, Pos: second, End: second + len(code), Nodes: []*syntax.Node{{Type: golang.ExprStmt}}},
+		},
+	}
+	patch, reason, err := suggestFix(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patch != "" || !strings.Contains(reason, "overlap") {
+		t.Errorf("got the patch %q and the reason %q, want the overlapping fragments rejected", patch, reason)
+	}
+}
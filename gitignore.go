@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// gitignoreRule is a single pattern of a .gitignore file.
+type gitignoreRule struct {
+	base    string // absolute directory of the .gitignore file
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// gitignore holds the rules of the .gitignore files loaded so far, in
+// the order of precedence: a later rule overrides the earlier ones.
+type gitignore struct {
+	rules []gitignoreRule
+	files map[string]int64 // loaded files by their modification time
+}
+
+// newGitignore returns the rules of the .gitignore files of the
+// directories above root, up to the top of the Git repository root
+// is in, if any.
+func newGitignore(root string) (*gitignore, error) {
+	g := &gitignore{files: make(map[string]int64)}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if isRepoTop(abs) {
+		// The .gitignore of root itself is loaded when root is walked.
+		return g, nil
+	}
+	var dirs []string
+	for dir := filepath.Dir(abs); ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if isRepoTop(dir) {
+			break
+		}
+		if filepath.Dir(dir) == dir {
+			// not in a repository
+			return g, nil
+		}
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := g.load(dirs[i]); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// isRepoTop reports whether dir is the top directory of a Git repository.
+func isRepoTop(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// load adds the rules of the .gitignore file in dir, if there is one.
+func (g *gitignore) load(dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	name := filepath.Join(dir, ".gitignore")
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err == nil {
+		g.files[name] = info.ModTime().UnixNano()
+	}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if rule, ok := parseGitignoreRule(dir, s.Text()); ok {
+			g.rules = append(g.rules, rule)
+		}
+	}
+	return s.Err()
+}
+
+// parseGitignoreRule parses a line of the .gitignore file in dir.
+func parseGitignoreRule(dir, line string) (gitignoreRule, bool) {
+	rule := gitignoreRule{base: dir}
+	// Trailing spaces are ignored unless escaped.
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule, false
+	}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	// A pattern with a slash but at its end is relative to dir,
+	// any other one matches a name at any depth.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return rule, false
+	}
+	expr := globRegexp(line)
+	if !anchored {
+		expr = "(.*/)?" + expr
+	}
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return rule, false
+	}
+	rule.re = re
+	return rule, true
+}
+
+// globRegexp translates the gitignore glob to a regular expression.
+func globRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case glob[i:] == "**":
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			j := strings.IndexByte(glob[i:], ']')
+			if j < 0 {
+				b.WriteString(`\[`)
+				break
+			}
+			class := glob[i+1 : i+j]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += j
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// ignored reports whether the file or directory at path is ignored.
+func (g *gitignore) ignored(path string, isDir bool) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	ignored := false
+	for _, rule := range g.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		rel, err := filepath.Rel(rule.base, abs)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if rule.re.MatchString(filepath.ToSlash(rel)) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
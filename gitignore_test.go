@@ -0,0 +1,81 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestGitignore(t *testing.T) {
+	base, err := filepath.Abs("repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		rules []string
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{[]string{"*.pb.go"}, "api/x.pb.go", false, true},
+		{[]string{"*.pb.go"}, "api/x.go", false, false},
+		{[]string{"x?.go"}, "x1.go", false, true},
+		{[]string{"x?.go"}, "x12.go", false, false},
+		{[]string{"x[0-9].go"}, "x1.go", false, true},
+		{[]string{"x[!0-9].go"}, "x1.go", false, false},
+
+		// ** at the start, in the middle, and at the end
+		{[]string{"**/gen"}, "gen", true, true},
+		{[]string{"**/gen"}, "a/b/gen", true, true},
+		{[]string{"a/**/b.go"}, "a/b.go", false, true},
+		{[]string{"a/**/b.go"}, "a/x/y/b.go", false, true},
+		{[]string{"a/**/b.go"}, "c/a/x/b.go", false, false},
+		{[]string{"gen/**"}, "gen/a/b.go", false, true},
+		{[]string{"gen/**"}, "gen", true, false},
+		// * does not match a slash
+		{[]string{"a/*.go"}, "a/b/c.go", false, false},
+
+		// a trailing / matches only directories
+		{[]string{"build/"}, "build", true, true},
+		{[]string{"build/"}, "build", false, false},
+		{[]string{"build/"}, "x/build", true, true},
+
+		// a pattern with a slash is anchored to the .gitignore directory
+		{[]string{"/gen"}, "gen", true, true},
+		{[]string{"/gen"}, "a/gen", true, false},
+		{[]string{"a/gen"}, "x/a/gen", true, false},
+		{[]string{"gen"}, "a/gen", true, true},
+
+		// the last matching rule wins
+		{[]string{"*.go", "!keep.go"}, "keep.go", false, false},
+		{[]string{"*.go", "!keep.go"}, "drop.go", false, true},
+		{[]string{"!keep.go", "*.go"}, "keep.go", false, true},
+		{[]string{"*.go", "!keep.go", "keep.go"}, "keep.go", false, true},
+		{[]string{"!keep.go"}, "keep.go", false, false},
+
+		// comments, escapes, and trailing spaces
+		{[]string{"#x.go"}, "#x.go", false, false},
+		{[]string{`\#x.go`}, "#x.go", false, true},
+		{[]string{`\!x.go`}, "!x.go", false, true},
+		{[]string{"x.go   "}, "x.go", false, true},
+		{[]string{`x\ `}, "x ", false, true},
+	}
+	for _, tc := range testCases {
+		g := &gitignore{}
+		for _, line := range tc.rules {
+			if rule, ok := parseGitignoreRule(base, line); ok {
+				g.rules = append(g.rules, rule)
+			}
+		}
+		path := filepath.Join(base, filepath.FromSlash(tc.path))
+		if got := g.ignored(path, tc.isDir); got != tc.want {
+			t.Errorf("rules %q: %s (dir %v): got ignored %v, want %v", tc.rules, tc.path, tc.isDir, got, tc.want)
+		}
+	}
+
+	// The rules apply only below their directory.
+	rule, _ := parseGitignoreRule(filepath.Join(base, "sub"), "*.go")
+	g := &gitignore{rules: []gitignoreRule{rule}}
+	if g.ignored(filepath.Join(base, "x.go"), false) {
+		t.Error("the rule of sub/.gitignore applies to the files above it")
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// goVersionFlag is the minor version of the Go release whose syntax
+// the files must conform to, or 0 for the latest.
+type goVersionFlag int
+
+func (f *goVersionFlag) String() string {
+	if *f == 0 {
+		return ""
+	}
+	return fmt.Sprintf("1.%d", int(*f))
+}
+
+func (f *goVersionFlag) Set(value string) error {
+	v := strings.TrimPrefix(value, "go")
+	if !strings.HasPrefix(v, "1.") {
+		return fmt.Errorf("invalid Go version %q; want e.g. 1.17", value)
+	}
+	v = v[len("1."):]
+	// A patch release, like 1.17.3, has the syntax of its minor one.
+	if i := strings.IndexByte(v, '.'); i >= 0 {
+		v = v[:i]
+	}
+	minor, err := strconv.Atoi(v)
+	if err != nil || minor < 1 {
+		return fmt.Errorf("invalid Go version %q; want e.g. 1.17", value)
+	}
+	*f = goVersionFlag(minor)
+	return nil
+}
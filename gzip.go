@@ -0,0 +1,50 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"log"
+	"os"
+)
+
+// gzipExt is the extension -gzip adds to the names of the reports.
+const gzipExt = ".gz"
+
+// compressOutput returns a writer compressing the data written to it by
+// gzip into w. The returned function flushes the compressed data, which
+// is incomplete before it is called.
+func compressOutput(w io.Writer) (io.Writer, func()) {
+	gz := gzip.NewWriter(w)
+	return gz, func() {
+		if err := gz.Close(); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// gzipFile is a file written through a gzip writer.
+type gzipFile struct {
+	*gzip.Writer
+	f *os.File
+}
+
+// createReport creates the report file of the name, with gzipExt added
+// and compressed if -gzip is set.
+func createReport(name string) (io.WriteCloser, error) {
+	if !*gzipOut {
+		return os.Create(name)
+	}
+	f, err := os.Create(name + gzipExt)
+	if err != nil {
+		return nil, err
+	}
+	return &gzipFile{gzip.NewWriter(f), f}, nil
+}
+
+func (g *gzipFile) Close() error {
+	if err := g.Writer.Close(); err != nil {
+		g.f.Close()
+		return err
+	}
+	return g.f.Close()
+}
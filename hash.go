@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+// hashFlag is the name of the hasher of the node sequences, which it
+// sets syntax.SeqHasher to.
+type hashFlag string
+
+func (f *hashFlag) String() string {
+	if *f == "" {
+		return "sha1"
+	}
+	return string(*f)
+}
+
+func (f *hashFlag) Set(value string) error {
+	h, ok := syntax.Hashers[value]
+	if !ok {
+		return fmt.Errorf("invalid hash %q; want sha1, sha256, fnv, or xxhash", value)
+	}
+	syntax.SeqHasher = h
+	*f = hashFlag(value)
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// identicalFiles maps the files skipped as byte-identical copies
+// to the files they are copies of.
+var identicalFiles = struct {
+	sync.Mutex
+	m map[string]string
+}{m: make(map[string]string)}
+
+// mergeIdentical returns a feed of the files of feed, leaving out
+// the files byte-identical to a preceding one.
+func mergeIdentical(feed chan string) chan string {
+	fchan := make(chan string)
+	go func() {
+		seen := make(map[[sha1.Size]byte]string)
+		for file := range feed {
+			src, err := readFile(file)
+			if err != nil {
+				// Let the parser report the error.
+				fchan <- file
+				continue
+			}
+			sum := sha1.Sum(src)
+			if orig, ok := seen[sum]; ok {
+				identicalFiles.Lock()
+				identicalFiles.m[file] = orig
+				identicalFiles.Unlock()
+				continue
+			}
+			seen[sum] = file
+			fchan <- file
+		}
+		close(fchan)
+	}()
+	return fchan
+}
+
+// printIdentical writes the skipped identical files to w.
+func printIdentical(w io.Writer) {
+	identicalFiles.Lock()
+	defer identicalFiles.Unlock()
+	copies := make([]string, 0, len(identicalFiles.m))
+	for file := range identicalFiles.m {
+		copies = append(copies, file)
+	}
+	sort.Strings(copies)
+	for _, file := range copies {
+		fmt.Fprintf(w, "%s: identical to %s\n", file, identicalFiles.m[file])
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultIgnoreFile is the ignore file used if present and no other
+// one is given.
+const defaultIgnoreFile = "dupl_ignore.go"
+
+// directivePrefix starts each comment line configuring dupl.
+const directivePrefix = "//dupl:"
+
+// loadIgnoreFile reads the dupl directives from the comments of the Go
+// source file and adds them to the filters of the respective flags.
+func loadIgnoreFile(filename string) error {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+	for _, cg := range f.Comments {
+		for _, c := range cg.List {
+			if !strings.HasPrefix(c.Text, directivePrefix) {
+				continue
+			}
+			if err := applyDirective(strings.TrimPrefix(c.Text, directivePrefix)); err != nil {
+				return fmt.Errorf("%s: %v", fset.Position(c.Pos()), err)
+			}
+		}
+	}
+	return nil
+}
+
+func applyDirective(d string) error {
+	fields := strings.Fields(d)
+	if len(fields) != 2 {
+		return fmt.Errorf("directive must be //dupl:name value, got %q", directivePrefix+d)
+	}
+	name, value := fields[0], fields[1]
+	switch name {
+	case "ignore-func":
+		return excludeFuncs.Set(value)
+	case "ignore-dir":
+		return ignoreDirs.Set(value)
+	case "ignore-lines":
+		return excludeLines.Set(value)
+	case "ignore-pattern":
+		return ignorePatterns.Set(value)
+	case "allow-pair":
+		return allowPairs.Set(value)
+	case "ignore-hash":
+		return ignoreHashes.Set(value)
+	}
+	return fmt.Errorf("unknown directive %q", name)
+}
+
+// loadIgnoreFiles loads the ignore file given by the flag, or the default
+// one if it exists.
+func loadIgnoreFiles() error {
+	if *ignoreFile != "" {
+		return loadIgnoreFile(*ignoreFile)
+	}
+	if _, err := os.Stat(defaultIgnoreFile); err != nil {
+		return nil
+	}
+	return loadIgnoreFile(defaultIgnoreFile)
+}
+
+// dirList is a repeatable flag of directories.
+type dirList []string
+
+func (l *dirList) String() string { return strings.Join(*l, ",") }
+
+func (l *dirList) Set(value string) error {
+	*l = append(*l, filepath.Clean(value))
+	return nil
+}
+
+// contains reports whether file is located in any of the directories.
+func (l dirList) contains(file string) bool {
+	for _, dir := range l {
+		if inDir(file, dir) {
+			return true
+		}
+	}
+	return false
+}
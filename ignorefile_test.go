@@ -0,0 +1,142 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// saveIgnores restores the filters the directives add to when the test
+// ends, and clears them for it.
+func saveIgnores(t *testing.T) {
+	funcs, dirs, lines, patterns, pairs, hashes := excludeFuncs, ignoreDirs, excludeLines, ignorePatterns, allowPairs, ignoreHashes
+	t.Cleanup(func() {
+		excludeFuncs, ignoreDirs, excludeLines = funcs, dirs, lines
+		ignorePatterns, allowPairs, ignoreHashes = patterns, pairs, hashes
+	})
+	excludeFuncs, ignoreDirs, excludeLines = nil, nil, nil
+	ignorePatterns, allowPairs, ignoreHashes = nil, nil, nil
+}
+
+func writeIgnoreFile(t *testing.T, src string) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "dupl_ignore.go")
+	if err := os.WriteFile(name, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestLoadIgnoreFile(t *testing.T) {
+	saveIgnores(t)
+	name := writeIgnoreFile(t, `// Package ignore configures dupl.
+//
+//dupl:ignore-func ^Test
+//dupl:ignore-dir gen/
+//dupl:ignore-lines a.go:3-9
+package ignore
+
+/*
+//dupl:ignore-func ^inBlockComment
+*/
+
+//dupl:ignore-pattern Deprecated
+//dupl:ignore-func Benchmark
+//dupl:allow-pair api/v1:api/v2
+//dupl:ignore-hash 0123ABCD
+
+// dupl:ignore-func ^notADirective
+`)
+	if err := loadIgnoreFile(name); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		flag string
+		got  interface{ String() string }
+		want string
+	}{
+		{"ignore-func", &excludeFuncs, "^Test,Benchmark"},
+		{"ignore-dir", &ignoreDirs, "gen"},
+		{"ignore-lines", &excludeLines, "a.go:3-9"},
+		{"ignore-pattern", &ignorePatterns, "Deprecated"},
+		{"allow-pair", &allowPairs, filepath.Clean("api/v1") + ":" + filepath.Clean("api/v2")},
+		{"ignore-hash", &ignoreHashes, "0123abcd"},
+	}
+	for _, tc := range testCases {
+		if got := tc.got.String(); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.flag, got, tc.want)
+		}
+	}
+
+	// The filters match as the flags do.
+	matches := []struct {
+		what string
+		got  bool
+		want bool
+	}{
+		{"func TestA", excludeFuncs.match("TestA"), true},
+		{"func BenchmarkA", excludeFuncs.match("BenchmarkA"), true},
+		{"func helperTest", excludeFuncs.match("helperTest"), false},
+		{"func inBlockComment", excludeFuncs.match("inBlockComment"), false},
+		{"func notADirective", excludeFuncs.match("notADirective"), false},
+		{"source with Deprecated", ignorePatterns.matchSource([]byte("// Deprecated: use g.\nf()")), true},
+		{"other source", ignorePatterns.matchSource([]byte("f()")), false},
+		{"file in gen", ignoreDirs.contains(filepath.Join("gen", "a.go")), true},
+		{"file out of gen", ignoreDirs.contains(filepath.Join("generated", "a.go")), false},
+	}
+	for _, m := range matches {
+		if m.got != m.want {
+			t.Errorf("%s: matched %v, want %v", m.what, m.got, m.want)
+		}
+	}
+}
+
+func TestLoadIgnoreFileErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"unknown directive", "package p\n\n//dupl:ignore-file x\n", ":3:1: unknown directive \"ignore-file\""},
+		{"no value", "package p\n\n//dupl:ignore-func\n", ":3:1: directive must be //dupl:name value"},
+		{"two values", "package p\n\n\n//dupl:ignore-dir a b\n", ":4:1: directive must be //dupl:name value"},
+		{"invalid value", "package p\n//dupl:ignore-func (\n", ":2:1: error parsing regexp"},
+		{"invalid lines", "package p\n//dupl:ignore-lines a.go:9-3\n", ":2:1: invalid lines"},
+		{"not Go", "//dupl:ignore-func ^Test\n", "expected 'package'"},
+	}
+	for _, tc := range testCases {
+		saveIgnores(t)
+		err := loadIgnoreFile(writeIgnoreFile(t, tc.src))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: got error %v, want one containing %q", tc.name, err, tc.want)
+		}
+	}
+	if err := loadIgnoreFile(filepath.Join(t.TempDir(), "missing.go")); err == nil {
+		t.Error("missing file: no error")
+	}
+}
+
+func TestDirListContains(t *testing.T) {
+	var l dirList
+	for _, dir := range []string{"gen/", "internal/mock"} {
+		l.Set(dir)
+	}
+	testCases := []struct {
+		file string
+		want bool
+	}{
+		{"gen/a.go", true},
+		{"gen/sub/a.go", true},
+		{"internal/mock/a.go", true},
+		{"generated/a.go", false},
+		{"internal/a.go", false},
+		{"a.go", false},
+	}
+	for _, tc := range testCases {
+		if got := l.contains(filepath.FromSlash(tc.file)); got != tc.want {
+			t.Errorf("contains(%q) = %v, want %v", tc.file, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// hashSet is a repeatable flag of the ids of accepted clone groups.
+type hashSet map[string]bool
+
+func (s *hashSet) String() string {
+	var ids []string
+	for id := range *s {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
+
+func (s *hashSet) Set(value string) error {
+	id := strings.ToLower(value)
+	if _, err := hex.DecodeString(id); err != nil || id == "" {
+		return fmt.Errorf("invalid group id %q; want the hexadecimal groupId of the JSON output", value)
+	}
+	if *s == nil {
+		*s = make(hashSet)
+	}
+	(*s)[id] = true
+	return nil
+}
+
+// load adds the group ids listed in the file, one at each line.
+// Empty lines and lines starting with # are skipped.
+func (s *hashSet) load(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if err := s.Set(text); err != nil {
+			return fmt.Errorf("%s:%d: %v", filename, line, err)
+		}
+	}
+	return sc.Err()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mibk/dupl/fingerprint"
+)
+
+// indexedUnit is a syntax unit of a fingerprint file of -index.
+type indexedUnit struct {
+	source int // index of the fingerprint file
+	file   string
+	unit   fingerprint.Unit
+}
+
+// readIndex reads the fingerprint files in the directory dir, all but
+// the hidden ones, and returns their names and their files.
+func readIndex(dir string) ([]string, [][]fingerprint.File, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	var names []string
+	var sources [][]fingerprint.File
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		files, err := fingerprint.ReadFiles(f)
+		f.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", path, err)
+		}
+		names = append(names, e.Name())
+		sources = append(sources, files)
+	}
+	return names, sources, nil
+}
+
+// printIndexMatches writes the syntax units found in more than one of
+// the fingerprint files in the directory dir to w, grouped by their
+// fingerprints. Units nested in a reported unit of the same file are
+// not reported separately.
+func printIndexMatches(w io.Writer, dir string) error {
+	names, sources, err := readIndex(dir)
+	if err != nil {
+		return err
+	}
+	// in are the fingerprint files each fingerprint is found in.
+	in := make(map[string]map[int]bool)
+	size := -1
+	for i, files := range sources {
+		for _, f := range files {
+			for _, u := range f.Units {
+				if size < 0 {
+					size = len(u.Hash)
+				} else if len(u.Hash) != size {
+					return fmt.Errorf("-index %s: %s has fingerprints of %d bytes, others of %d; export them all with the same -hash",
+						dir, names[i], len(u.Hash), size)
+				}
+				if in[u.Hash] == nil {
+					in[u.Hash] = make(map[int]bool)
+				}
+				in[u.Hash][i] = true
+			}
+		}
+	}
+
+	groups := make(map[string][]indexedUnit)
+	var keys []string
+	for i, files := range sources {
+		for _, f := range files {
+			end := 0 // of the last reported unit
+			for _, u := range f.Units {
+				if len(in[u.Hash]) < 2 || u.LineEnd <= end {
+					continue
+				}
+				end = u.LineEnd
+				if groups[u.Hash] == nil {
+					keys = append(keys, u.Hash)
+				}
+				groups[u.Hash] = append(groups[u.Hash], indexedUnit{i, f.Name, u})
+			}
+		}
+	}
+
+	reported := 0
+	used := make(map[int]bool)
+	for _, k := range keys {
+		g := groups[k]
+		srcs := make(map[int]bool)
+		for _, u := range g {
+			srcs[u.source] = true
+		}
+		if len(srcs) < 2 {
+			// The units found elsewhere are nested in reported ones.
+			continue
+		}
+		for s := range srcs {
+			used[s] = true
+		}
+		reported++
+		fmt.Fprintf(w, "found %d clones of %d tokens in %d fingerprint files:\n", len(g), g[0].unit.Tokens, len(srcs))
+		for _, u := range g {
+			fmt.Fprintf(w, "  %s: %s:%d,%d\n", names[u.source], u.file, u.unit.LineStart, u.unit.LineEnd)
+		}
+	}
+	_, err = fmt.Fprintf(w, "\nFound total %d clone groups between %d of %d fingerprint files.\n", reported, len(used), len(names))
+	return err
+}
@@ -1,23 +1,48 @@
 package job
 
 import (
+	"bytes"
 	"log"
 
 	"github.com/mibk/dupl/syntax"
 	"github.com/mibk/dupl/syntax/golang"
 )
 
-func Parse(fchan chan string) chan []*syntax.Node {
+// Stats holds corpus-wide totals gathered while parsing.
+type Stats struct {
+	Files  int
+	Lines  int
+	Tokens int
+
+	Filenames []string // parsed files in the order of parsing
+}
+
+// Parse parses the files received from fchan, reading them by read.
+// Once done is closed, the files still received are neither read nor
+// parsed, so the returned channel is closed as soon as fchan is.
+// A nil done is never closed.
+func Parse(fchan chan string, read func(string) ([]byte, error), opts golang.Options, stats *Stats, done <-chan struct{}) chan []*syntax.Node {
 
 	// parse AST
 	achan := make(chan *syntax.Node)
 	go func() {
 		for file := range fchan {
-			ast, err := golang.Parse(file)
+			if isDone(done) {
+				continue
+			}
+			src, err := read(file)
 			if err != nil {
 				log.Println(err)
 				continue
 			}
+			ast, err := golang.Parse(file, src, opts)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			stats.Files++
+			stats.Filenames = append(stats.Filenames, file)
+			stats.Lines += CountLines(src)
 			achan <- ast
 		}
 		close(achan)
@@ -27,10 +52,33 @@ func Parse(fchan chan string) chan []*syntax.Node {
 	schan := make(chan []*syntax.Node)
 	go func() {
 		for ast := range achan {
+			if isDone(done) {
+				continue
+			}
 			seq := syntax.Serialize(ast)
+			stats.Tokens += len(seq)
 			schan <- seq
 		}
 		close(schan)
 	}()
 	return schan
 }
+
+func isDone(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+// CountLines returns the number of lines of src, the last of which
+// may lack the newline.
+func CountLines(src []byte) int {
+	n := bytes.Count(src, []byte{'\n'})
+	if len(src) > 0 && src[len(src)-1] != '\n' {
+		n++
+	}
+	return n
+}
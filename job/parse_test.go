@@ -0,0 +1,244 @@
+package job
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mibk/dupl/syntax"
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+var sources = []string{`package a
+
+func f(a []int) int {
+	sum := 0
+	for _, x := range a {
+		if x > 0 {
+			sum += x * 2
+		}
+	}
+	return sum
+}
+`, `package b
+
+func g(m map[string]int) {
+	for k, v := range m {
+		if v > 1 {
+			println(k, v*3)
+		}
+	}
+}
+
+func h(b []int) int {
+	total := 0
+	for _, y := range b {
+		if y > 1 {
+			total += y * 3
+		}
+	}
+	return total
+}
+`, `package c
+
+func k(m map[string]int) {
+	for key, val := range m {
+		if val > 10 {
+			println(key, val+7)
+		}
+	}
+}
+`}
+
+func TestHashIndependentOfOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dupl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var files []string
+	for i, src := range sources {
+		file := filepath.Join(dir, fmt.Sprintf("%d.go", i))
+		if err := ioutil.WriteFile(file, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+
+	want := hashGroups(files)
+	if len(want) == 0 {
+		t.Fatal("no clones found")
+	}
+	reversed := []string{files[2], files[1], files[0]}
+	rotated := []string{files[1], files[2], files[0]}
+	for _, order := range [][]string{reversed, rotated} {
+		if got := hashGroups(order); !reflect.DeepEqual(got, want) {
+			t.Errorf("for order %v got groups\n%v\nwant\n%v", order, got, want)
+		}
+	}
+}
+
+// TestLongFunction checks that a huge generated function, which is a
+// single extremely repetitive token sequence, is processed in due time.
+func TestLongFunction(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping stress test in short mode")
+	}
+	dir, err := ioutil.TempDir("", "dupl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const clone = "\tfor i := 0; i < x; i++ {\n\t\tx += g(i, x*2)\n\t}\n"
+	var b strings.Builder
+	b.WriteString("package p\n\nfunc f(x int) int {\n" + clone)
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&b, "\tif x > %d {\n\t\tx -= %d\n\t}\n", i, i)
+	}
+	b.WriteString(clone + "\treturn x\n}\n")
+	file := filepath.Join(dir, "long.go")
+	if err := ioutil.WriteFile(file, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if len(hashGroups([]string{file})) == 0 {
+		t.Error("no clones found")
+	}
+}
+
+// hashGroups finds clones in the files parsed in the given order and
+// returns the sorted positions of the fragments grouped by their hashes.
+func hashGroups(files []string) map[string][]string {
+	fchan := make(chan string)
+	go func() {
+		for _, f := range files {
+			fchan <- f
+		}
+		close(fchan)
+	}()
+	var stats Stats
+	t, data, done := BuildTree(Parse(fchan, ioutil.ReadFile, golang.Options{}, &stats, nil))
+	<-done
+	t.Update(&syntax.Node{Type: -1})
+
+	groups := make(map[string][]string)
+	for m := range t.FindDuplOver(10) {
+		for _, match := range syntax.FindSyntaxUnits(*data, m, 10) {
+			for _, frag := range match.Frags {
+				pos := fmt.Sprintf("%s:%d", filepath.Base(frag[0].Filename), frag[0].Pos)
+				groups[match.Hash] = append(groups[match.Hash], pos)
+			}
+		}
+	}
+	for h, g := range groups {
+		sort.Strings(g)
+		groups[h] = uniq(g)
+	}
+	return groups
+}
+
+func uniq(sorted []string) []string {
+	out := sorted[:0]
+	for i, s := range sorted {
+		if i == 0 || s != sorted[i-1] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func TestBlank(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dupl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var files []string
+	for i, src := range []string{
+		"package p\n\nfunc f() { _, err := g(); h(err) }\n",
+		"package p\n\nfunc f() { x, err := g(); h(err) }\n",
+	} {
+		file := filepath.Join(dir, fmt.Sprintf("%d.go", i))
+		if err := ioutil.WriteFile(file, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+
+	tests := []struct {
+		opts golang.Options
+		same bool
+	}{
+		{golang.Options{}, true},
+		{golang.Options{Blank: golang.BlankSignificant}, false},
+		{golang.Options{Blank: golang.BlankIgnore}, true},
+		{golang.Options{Normalize: golang.NormalizeNone}, false},
+		{golang.Options{Normalize: golang.NormalizeLocals, Blank: golang.BlankSignificant}, false},
+	}
+	for _, tt := range tests {
+		seqs := parseTypes(files, tt.opts)
+		if same := reflect.DeepEqual(seqs[0], seqs[1]); same != tt.same {
+			t.Errorf("with %+v the sequences are the same: %v, want %v", tt.opts, same, tt.same)
+		}
+	}
+}
+
+// parseTypes returns the sequences of the node types of the files.
+func parseTypes(files []string, opts golang.Options) [][]int {
+	fchan := make(chan string)
+	go func() {
+		for _, f := range files {
+			fchan <- f
+		}
+		close(fchan)
+	}()
+	var stats Stats
+	var seqs [][]int
+	for seq := range Parse(fchan, ioutil.ReadFile, opts, &stats, nil) {
+		var types []int
+		for _, n := range seq {
+			types = append(types, n.Type)
+		}
+		seqs = append(seqs, types)
+	}
+	return seqs
+}
+
+func TestParseStopped(t *testing.T) {
+	const n = 10
+	fchan := make(chan string)
+	go func() {
+		for i := 0; i < n; i++ {
+			fchan <- fmt.Sprintf("%d.go", i)
+		}
+		close(fchan)
+	}()
+	var mu sync.Mutex
+	reads := 0
+	read := func(string) ([]byte, error) {
+		mu.Lock()
+		reads++
+		mu.Unlock()
+		return []byte(sources[0]), nil
+	}
+	done := make(chan struct{})
+	var stats Stats
+	schan := Parse(fchan, read, golang.Options{}, &stats, done)
+	<-schan
+	close(done)
+	for range schan {
+		// The files fed are drained, so the channel is closed.
+	}
+	// At most one file is parsed and another serialized meanwhile.
+	if reads > 3 {
+		t.Errorf("read %d of %d files after the parsing was stopped", reads, n)
+	}
+}
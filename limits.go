@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// truncatedStatus is the exit status of a scan whose report was cut
+// short by -max-total-fragments or -max-memory.
+const truncatedStatus = 4
+
+// memCheckInterval is the number of matches collected between the
+// checks of the memory in use, which stop the world for a moment.
+const memCheckInterval = 1024
+
+// limitExceeded returns the limit exceeded, if any, by collecting
+// the given numbers of matches and their fragments.
+func limitExceeded(matches, frags int) string {
+	if *maxTotalFrags > 0 && frags > *maxTotalFrags {
+		return fmt.Sprintf("-max-total-fragments %d", *maxTotalFrags)
+	}
+	if maxMemory > 0 && matches%memCheckInterval == 0 {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		if ms.HeapAlloc > uint64(maxMemory) {
+			return fmt.Sprintf("-max-memory %v", &maxMemory)
+		}
+	}
+	return ""
+}
+
+// byteSize is a flag of a number of bytes, optionally with a unit like
+// 512MB or 2GiB.
+type byteSize int64
+
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"B", 1},
+}
+
+func (s *byteSize) String() string {
+	for _, u := range byteUnits {
+		if *s != 0 && int64(*s)%u.size == 0 {
+			return strconv.FormatInt(int64(*s)/u.size, 10) + u.suffix
+		}
+	}
+	return strconv.FormatInt(int64(*s), 10)
+}
+
+func (s *byteSize) Set(value string) error {
+	num, unit := value, int64(1)
+	for _, u := range byteUnits {
+		if strings.HasSuffix(value, u.suffix) {
+			num, unit = strings.TrimSuffix(value, u.suffix), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid size %q; want a number of bytes like 512MB or 2GiB", value)
+	}
+	if n > math.MaxInt64/unit {
+		return fmt.Errorf("invalid size %q; it does not fit in 64 bits", value)
+	}
+	*s = byteSize(n * unit)
+	return nil
+}
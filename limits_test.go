@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+func TestMaxTotalFragments(t *testing.T) {
+	defer func(nc bool, th, max int) {
+		*noCrawlCache, *fromThreshold, *toThreshold, *maxTotalFrags = nc, th, th, max
+		// The limit stops the scan; let the other tests scan again.
+		scanStop, scanStopOnce = make(chan struct{}), sync.Once{}
+	}(*noCrawlCache, *fromThreshold, *maxTotalFrags)
+	*noCrawlCache = true
+	*fromThreshold, *toThreshold = 10, 10
+
+	for _, max := range []int{5, 10, 20} {
+		*maxTotalFrags = max
+		scanStop, scanStopOnce = make(chan struct{}), sync.Once{}
+		var doc struct {
+			Groups []struct {
+				Fragments []json.RawMessage
+			}
+		}
+		out := scanForTest(t, []string{"printer"})
+		if err := json.Unmarshal(out, &doc); err != nil {
+			t.Fatal(err)
+		}
+		frags := 0
+		for _, g := range doc.Groups {
+			frags += len(g.Fragments)
+		}
+		if frags == 0 || frags > max {
+			t.Errorf("-max-total-fragments %d: got %d fragments:\n%s", max, frags, out)
+		}
+		if !stopped() {
+			t.Errorf("-max-total-fragments %d: the search is not stopped", max)
+		}
+	}
+}
+
+func TestByteSizeSet(t *testing.T) {
+	testCases := []struct {
+		value string
+		want  byteSize
+		ok    bool
+	}{
+		{"512", 512, true},
+		{"512B", 512, true},
+		{"2KB", 2000, true},
+		{"2GiB", 2 << 30, true},
+		{"9223372036854775807", 1<<63 - 1, true},
+		{"9223372036854775807KB", 0, false},
+		{"8589934592GiB", 0, false},
+		{"8589934591GiB", 8589934591 << 30, true},
+		{"-1MB", 0, false},
+		{"MB", 0, false},
+	}
+	for _, tc := range testCases {
+		var s byteSize
+		err := s.Set(tc.value)
+		if ok := err == nil; ok != tc.ok || ok && s != tc.want {
+			t.Errorf("Set(%q): got %d, %v; want %d, ok %v", tc.value, s, err, tc.want, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mibk/dupl/printer"
+)
+
+// lineRange is a range of lines of a file, inclusive.
+type lineRange struct {
+	file       string
+	start, end int
+}
+
+// lineRanges is a repeatable flag of line ranges, each given as
+// file:start-end.
+type lineRanges []lineRange
+
+func (l *lineRanges) String() string {
+	var s []string
+	for _, r := range *l {
+		s = append(s, fmt.Sprintf("%s:%d-%d", r.file, r.start, r.end))
+	}
+	return strings.Join(s, ",")
+}
+
+func (l *lineRanges) Set(value string) error {
+	i := strings.LastIndex(value, ":")
+	if i <= 0 {
+		return fmt.Errorf("line range must be file:start-end, got %q", value)
+	}
+	bounds := strings.SplitN(value[i+1:], "-", 2)
+	if len(bounds) != 2 {
+		return fmt.Errorf("line range must be file:start-end, got %q", value)
+	}
+	start, err1 := strconv.Atoi(bounds[0])
+	end, err2 := strconv.Atoi(bounds[1])
+	if err1 != nil || err2 != nil || start < 1 || end < start {
+		return fmt.Errorf("invalid lines %q in line range %q", value[i+1:], value)
+	}
+	*l = append(*l, lineRange{filepath.Clean(value[:i]), start, end})
+	return nil
+}
+
+// excludes reports whether the fragment intersects any of the ranges.
+func (l lineRanges) excludes(frag printer.Fragment) (bool, error) {
+	var file []byte
+	for _, r := range l {
+		if foldPath(r.file) != foldPath(filepath.Clean(frag.Filename)) {
+			continue
+		}
+		if file == nil {
+			var err error
+			if file, err = readFile(frag.Filename); err != nil {
+				return false, err
+			}
+		}
+		start := bytes.Count(file[:frag.Pos], []byte{'\n'}) + 1
+		end := start + bytes.Count(file[frag.Pos:frag.End], []byte{'\n'})
+		if start <= r.end && r.start <= end {
+			return true, nil
+		}
+	}
+	return false, nil
+}
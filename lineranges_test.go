@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLineRangesSet(t *testing.T) {
+	testCases := []struct {
+		value string
+		want  lineRange
+		ok    bool
+	}{
+		{"a.go:3-5", lineRange{"a.go", 3, 5}, true},
+		{"./dir/a.go:7-7", lineRange{filepath.Join("dir", "a.go"), 7, 7}, true},
+		{`C:\a.go:1-2`, lineRange{`C:\a.go`, 1, 2}, true},
+		{"a.go:5-3", lineRange{}, false},
+		{"a.go:0-3", lineRange{}, false},
+		{"a.go:3", lineRange{}, false},
+		{"a.go:x-3", lineRange{}, false},
+		{":3-5", lineRange{}, false},
+		{"a.go", lineRange{}, false},
+	}
+	for _, tc := range testCases {
+		var l lineRanges
+		err := l.Set(tc.value)
+		if ok := err == nil; ok != tc.ok || ok && l[0] != tc.want {
+			t.Errorf("Set(%q): got %v, %v; want %v, ok %v", tc.value, l, err, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestLineRangesExcludes(t *testing.T) {
+	var src strings.Builder
+	for i := 1; i <= 20; i++ {
+		fmt.Fprintf(&src, "line%d\n", i)
+	}
+	addMemFile(t, "a.go", src.String())
+	addMemFile(t, "b.go", src.String())
+	var l lineRanges
+	if err := l.Set("a.go:5-8"); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		file       string
+		start, end int
+		want       bool
+	}{
+		{"a.go", 1, 4, false},
+		{"a.go", 1, 5, true},
+		{"a.go", 6, 7, true},
+		{"a.go", 8, 12, true},
+		{"a.go", 2, 12, true},
+		{"a.go", 9, 12, false},
+		{"b.go", 5, 8, false},
+	}
+	for _, tc := range testCases {
+		frag := lineFragment(tc.file, src.String(), tc.start, tc.end)
+		got, err := l.excludes(frag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("%s:%d,%d: got excluded %v, want %v", tc.file, tc.start, tc.end, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Package lint exposes clone detection for linter runners, like
+// golangci-lint, reporting an issue for each duplicated fragment.
+package lint
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/mibk/dupl/job"
+	"github.com/mibk/dupl/syntax"
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+// Issue is a single duplicated fragment.
+type Issue struct {
+	File    string
+	Line    int // 1-based
+	Col     int // 1-based, in bytes
+	Message string
+}
+
+type fragment struct {
+	file               string
+	line, col, endLine int
+}
+
+// Run finds clones of at least threshold tokens in the files
+// and returns an issue for each fragment of every clone.
+func Run(files []string, threshold int) ([]Issue, error) {
+	fchan := make(chan string)
+	go func() {
+		for _, f := range files {
+			fchan <- f
+		}
+		close(fchan)
+	}()
+	var stats job.Stats
+	t, data, done := job.BuildTree(job.Parse(fchan, ioutil.ReadFile, golang.Options{}, &stats, nil))
+	<-done
+	t.Update(&syntax.Node{Type: -1})
+
+	groups := make(map[string][][]*syntax.Node)
+	for m := range t.FindDuplOver(threshold) {
+		for _, match := range syntax.FindSyntaxUnits(*data, m, threshold) {
+			groups[match.Hash] = append(groups[match.Hash], match.Frags...)
+		}
+	}
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var issues []Issue
+	for _, k := range keys {
+		uniq := syntax.Unique(groups[k])
+		if len(uniq) < 2 {
+			continue
+		}
+		frags := make([]fragment, len(uniq))
+		for i, seq := range uniq {
+			f, err := newFragment(seq)
+			if err != nil {
+				return nil, err
+			}
+			frags[i] = f
+		}
+		for i, f := range frags {
+			next := frags[(i+1)%len(frags)]
+			issues = append(issues, Issue{
+				File: f.file,
+				Line: f.line,
+				Col:  f.col,
+				Message: fmt.Sprintf("%d-%d lines are duplicate of `%s:%d-%d`",
+					f.line, f.endLine, next.file, next.line, next.endLine),
+			})
+		}
+	}
+	return issues, nil
+}
+
+func newFragment(seq []*syntax.Node) (fragment, error) {
+	first, last := seq[0], seq[len(seq)-1]
+	file, err := ioutil.ReadFile(first.Filename)
+	if err != nil {
+		return fragment{}, err
+	}
+	line := func(offset int) int {
+		return bytes.Count(file[:offset], []byte{'\n'}) + 1
+	}
+	f := fragment{
+		file:    first.Filename,
+		line:    line(first.Pos),
+		col:     first.Pos - bytes.LastIndexByte(file[:first.Pos], '\n'),
+		endLine: line(last.End - 1),
+	}
+	return f, nil
+}
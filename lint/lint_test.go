@@ -0,0 +1,60 @@
+package lint
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dupl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := `package p
+
+func f(a []int) int {
+	sum := 0
+	for _, x := range a {
+		if x > 0 {
+			sum += x * 2
+		}
+	}
+	return sum
+}
+
+	func g(b []int) int {
+	total := 0
+	for _, y := range b {
+		if y > 1 {
+			total += y * 3
+		}
+	}
+	return total
+}
+`
+	file := filepath.Join(dir, "p.go")
+	if err := ioutil.WriteFile(file, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	issues, err := Run([]string{file}, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Issue{
+		{file, 3, 1, "3-11 lines are duplicate of `" + file + ":13-21`"},
+		{file, 13, 2, "13-21 lines are duplicate of `" + file + ":3-11`"},
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("got %d issues, want %d: %v", len(issues), len(want), issues)
+	}
+	for i := range want {
+		if issues[i] != want[i] {
+			t.Errorf("got %+v, want %+v", issues[i], want[i])
+		}
+	}
+}
@@ -4,31 +4,133 @@ import (
 	"bufio"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"runtime/debug"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/mibk/dupl/job"
 	"github.com/mibk/dupl/printer"
 	"github.com/mibk/dupl/suffixtree"
 	"github.com/mibk/dupl/syntax"
+	"github.com/mibk/dupl/syntax/golang"
 )
 
 const defaultThreshold = 15
 
 var (
-	paths         = []string{"."}
-	vendor        = flag.Bool("vendor", false, "")
-	verbose       = flag.Bool("verbose", false, "")
-	fromThreshold = flag.Int("from-threshold", defaultThreshold, "")
-	toThreshold   = flag.Int("to-threshold", defaultThreshold, "")
-	files         = flag.Bool("files", false, "")
-
-	html     = flag.Bool("html", false, "")
-	plumbing = flag.Bool("plumbing", false, "")
+	paths            = []string{"."}
+	vendor           = flag.Bool("vendor", false, "")
+	vendorOnly       = flag.Bool("vendor-only", false, "")
+	skipMissing      = flag.Bool("skip-missing", false, "")
+	vendorPairs      = flag.Bool("vendor-pairs", false, "")
+	verbose          = flag.Bool("verbose", false, "")
+	verboseMatches   = flag.Bool("verbose-matches", false, "")
+	fromThreshold    = flag.Int("from-threshold", defaultThreshold, "")
+	toThreshold      = flag.Int("to-threshold", defaultThreshold, "")
+	maxSpanLines     = flag.Int("max-span-lines", 0, "")
+	minGap           = flag.Int("min-gap", 0, "")
+	nearMissLines    = flag.Int("near-miss-lines", 0, "")
+	exactPrepass     = flag.Bool("exact-prepass", false, "")
+	blameAuthors     = flag.Bool("blame", false, "")
+	minTokenKinds    = flag.Int("min-token-kinds", 1, "")
+	exactOnly        = flag.Bool("exact-only", false, "")
+	files            = flag.Bool("files", false, "")
+	filesFrom        = flag.String("files-from", "", "")
+	strictStructural = flag.Bool("strict-structural", false, "")
+	tables           = flag.Bool("tables", false, "")
+	constBlocks      = flag.Bool("const-blocks", false, "")
+	bodiesOnly       = flag.Bool("bodies-only", false, "")
+	tabWidth         = flag.Int("tab-width", 0, "")
+	normReceivers    = flag.Bool("normalize-receivers", false, "")
+	reorderTolerant  = flag.Bool("reorder-tolerant", false, "")
+	genericsAware    = flag.Bool("generics-aware", false, "")
+	testSetup        = flag.Bool("test-setup", false, "")
+	switchCases      = flag.Bool("switch-cases", false, "")
+	crossModule      = flag.Bool("cross-module", false, "")
+	onlyDirs         = flag.Bool("only-dirs", false, "")
+	selfClones       = flag.Bool("self", false, "")
+	movesSince       = flag.String("moves-since", "", "")
+	noCrawlCache     = flag.Bool("no-crawl-cache", false, "")
+	respectGitignore = flag.Bool("respect-gitignore", false, "")
+	includeTestdata  = flag.Bool("include-testdata", false, "")
+	includeIgnored   = flag.Bool("include-ignored", false, "")
+	noMergeIdentical = flag.Bool("no-merge-identical", false, "")
+	ciPaths          = flag.Bool("ci-paths", caseInsensitiveFS, "")
+	seed             = flag.String("seed", "", "")
+	dirty            = flag.Bool("dirty", false, "")
+	maxTotalFrags    = flag.Int("max-total-fragments", 0, "")
+	timeout          = flag.Duration("timeout", 0, "")
+	sample           sampleSize
+	sampleSeed       = flag.Int64("sample-seed", 0, "")
+	reportUnmatch    = flag.Bool("report-unmatched", false, "")
+	maxGroups        = flag.Int("max-groups", -1, "")
+	maxDuplTokens    = flag.Int("max-duplicated-tokens", -1, "")
+	maxDuplPercent   = flag.Float64("max-duplicated-percent", -1, "")
+	allowPairs       dirPairs
+	excludeFuncs     regexps
+	ignorePatterns   regexps
+	ignoreDirs       dirList
+	excludeLines     lineRanges
+	errorBlocksMode  = errorBlocksFlag("report")
+	maxMemory        byteSize
+	thresholds       langThresholds
+	pathThresholds   pathThresholdList
+	normalize        normalizeFlag
+	blank            blankFlag
+	goVersion        goVersionFlag
+	hashName         hashFlag
+	ignoreHashes     hashSet
+	ignoreHashesFile = flag.String("ignore-hashes", "", "")
+	ignoreFile       = flag.String("ignore-file", "", "")
+
+	exportFingerprints = flag.String("export-fingerprints", "", "")
+	reference          = flag.String("reference", "", "")
+	indexDir           = flag.String("index", "", "")
+
+	html         = flag.Bool("html", false, "")
+	htmlAppend   = flag.String("html-append", "", "")
+	plumbing     = flag.Bool("plumbing", false, "")
+	jsonOut      = flag.Bool("json", false, "")
+	jsonPretty   = flag.Bool("json-pretty", false, "")
+	fragDetail   = flag.Bool("fragment-detail", false, "")
+	csvOut       = flag.Bool("csv", false, "")
+	githubOut    = flag.Bool("github", false, "")
+	dot          = flag.Bool("dot", false, "")
+	suggestFixes = flag.Bool("suggest-fix", false, "")
+	textTemplate = flag.String("text-template", "", "")
+	diffAgainst  = flag.String("diff-against", "", "")
+
+	outputDir  = flag.String("output-dir", "", "")
+	gzipOut    = flag.Bool("gzip", false, "")
+	sqlitePath = flag.String("sqlite", "", "")
+
+	serveAddr = flag.String("serve", "", "")
+	selftest  = flag.Bool("selftest", false, "")
+
+	showPackage    = flag.Bool("show-package", false, "")
+	showScope      = flag.Bool("show-scope", false, "")
+	funcSignatures = flag.Bool("func-signatures", false, "")
+	fragmentIDs    = flag.Bool("fragment-ids", false, "")
+	similarity     = flag.Bool("similarity", false, "")
+	count          = flag.Bool("count", false, "")
+	templateView   = flag.Bool("template-view", false, "")
+	explainGroups  = flag.Bool("explain", false, "")
+	messageTmpl    = flag.String("message-template", printer.DefaultMessage, "")
+	ruleID         = flag.String("rule-id", printer.DefaultRuleID, "")
+	color          = flag.String("color", "auto", "")
+	noColor        = flag.Bool("no-color", false, "")
+	paginate       = flag.Bool("paginate", false, "")
+	withSource     = flag.Bool("with-source", false, "")
+	withoutSource  = flag.Bool("without-source", false, "")
+
+	cpuProfile = flag.String("cpuprofile", "", "")
+	memProfile = flag.String("memprofile", "", "")
 )
 
 const (
@@ -38,25 +140,313 @@ const (
 
 func init() {
 	flag.BoolVar(verbose, "v", false, "alias for -verbose")
-	flag.IntVar(fromThreshold, "t", defaultThreshold, "alias for -threshold")
+	flag.IntVar(fromThreshold, "t", defaultThreshold, "alias for -from-threshold")
+	flag.Var(&allowPairs, "allow-pair", "")
+	flag.Var(&excludeFuncs, "exclude-func", "")
+	flag.Var(&ignorePatterns, "ignore-pattern", "")
+	flag.Var(&ignoreDirs, "ignore-dir", "")
+	flag.Var(&excludeLines, "exclude-lines", "")
+	flag.Var(&ignoreHashes, "ignore-hash", "")
+	flag.Var(&errorBlocksMode, "error-blocks", "")
+	flag.Var(&normalize, "normalize", "")
+	flag.Var(&blank, "blank", "")
+	flag.Var(&goVersion, "go-version", "")
+	flag.Var(&hashName, "hash", "")
+	flag.Var(&sample, "sample", "")
+	flag.Var(&maxMemory, "max-memory", "")
+	flag.Var(&thresholds, "threshold", "")
+	flag.Var(&pathThresholds, "path", "")
 }
 
 func main() {
 	flag.Usage = usage
+	if err := parseEnvFlags(); err != nil {
+		fmt.Fprintf(os.Stderr, "dupl: %s: %v\n", envFlagsVar, err)
+		os.Exit(2)
+	}
+	if len(os.Args) > 1 {
+		if run, ok := commands[os.Args[1]]; ok {
+			warnCommandPath(os.Args[1])
+			run(os.Args[2:])
+			return
+		}
+	}
 	flag.Parse()
-	if *html && *plumbing {
-		log.Fatal("you can have either plumbing or HTML output")
+	scan(flag.Args())
+}
+
+// scan searches for clones in the paths, or in the default paths
+// if there are none, as set up by the flags.
+func scan(args []string) {
+	start := time.Now()
+	if len(args) > 0 {
+		paths = args
+	}
+	if *diffAgainst != "" {
+		var err error
+		if paths, err = diffPaths(args); err != nil {
+			log.Fatal(err)
+		}
+		// Copied files are compared, not merged.
+		*noMergeIdentical = true
 	}
-	if flag.NArg() > 0 {
-		paths = flag.Args()
+	if !*files && *filesFrom == "" {
+		var err error
+		if paths, err = existingPaths(os.Stderr, paths); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *files || *filesFrom == "-" {
+		for _, path := range paths {
+			if path == "-" {
+				log.Fatal("cannot read both file names and source from stdin")
+			}
+		}
+	}
+	if *movesSince != "" && (*files || *filesFrom != "") {
+		log.Fatal("-moves-since: the files are listed by Git; give the paths to scan instead")
+	}
+	if *vendorOnly && *vendorPairs {
+		log.Fatal("you can have only one of vendor-only and vendor-pairs")
+	}
+	if *vendorPairs {
+		// The vendored counterparts have to be scanned.
+		*vendor = true
+	}
+	if *strictStructural {
+		if err := checkStrictStructural(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := loadIgnoreFiles(); err != nil {
+		log.Fatal(err)
+	}
+	if *ignoreHashesFile != "" {
+		if err := ignoreHashes.load(*ignoreHashesFile); err != nil {
+			log.Fatal(err)
+		}
 	}
 
+	newPrinter, ext := printer.NewText, ".txt"
+	formats := 0
+	for _, f := range []struct {
+		set        bool
+		newPrinter func(io.Writer, printer.ReadFile, printer.Options) printer.Printer
+		ext        string
+	}{
+		{*html || *htmlAppend != "", printer.NewHTML, ".html"},
+		{*plumbing, printer.NewPlumbing, ".plumbing"},
+		{*jsonOut || *jsonPretty, printer.NewJSON, ".json"},
+		{*dot, printer.NewDOT, ".dot"},
+		{*csvOut, printer.NewCSV, ".csv"},
+		{*githubOut, printer.NewGitHub, ".txt"},
+		{*suggestFixes, newFixPrinter, ".patch"},
+		{*textTemplate != "", printer.NewTemplate, ".txt"},
+		{*count, newCountPrinter, ".txt"},
+		{*diffAgainst != "", newDiffPrinter, ".txt"},
+		{baselineFile != "", newBaselinePrinter, ".txt"},
+	} {
+		if f.set {
+			newPrinter, ext = f.newPrinter, f.ext
+			formats++
+		}
+	}
+	if formats > 1 {
+		log.Fatal("you can have only one of plumbing, HTML, JSON, DOT, CSV, GitHub, suggest-fix, text-template, count, or diff-against output")
+	}
+	if *indexDir != "" && formats > 0 {
+		log.Fatal("-index writes only the text output")
+	}
+	if *tabWidth < 0 {
+		log.Fatalf("invalid -tab-width %d; want a positive width, or 0 to keep the tabs", *tabWidth)
+	}
+	opts := printer.Options{
+		Color:          printer.ColorAuto,
+		ShowPackage:    *showPackage,
+		ShowScope:      *showScope,
+		FuncSignatures: *funcSignatures,
+		FragmentIDs:    *fragmentIDs,
+		Similarity:     *similarity,
+		PrettyJSON:     *jsonPretty,
+		FragmentDetail: *fragDetail,
+		TabWidth:       *tabWidth,
+		RuleID:         *ruleID,
+		Version:        version(),
+	}
+	tmpl, err := printer.ParseMessage(*messageTmpl)
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts.Message = tmpl
+	if *textTemplate != "" {
+		text, err := ioutil.ReadFile(*textTemplate)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts.Template, err = printer.ParseReport(filepath.Base(*textTemplate), string(text))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	switch {
+	case *noColor || *color == "never":
+		opts.Color = printer.ColorNever
+	case *color == "always":
+		opts.Color = printer.ColorAlways
+	case *color != "auto":
+		log.Fatalf("invalid -color value %q; want always, never, or auto", *color)
+	}
+	switch {
+	case *withSource && *withoutSource:
+		log.Fatal("you can have only one of with-source and without-source")
+	case *withSource:
+		opts.Source = printer.SourceInclude
+	case *withoutSource:
+		opts.Source = printer.SourceOmit
+	}
+	var out io.Writer = os.Stdout
+	closeOutput := func() {}
+	if *gzipOut && *outputDir == "" && *serveAddr == "" {
+		if *htmlAppend != "" {
+			log.Fatal("you can have only one of html-append and gzip")
+		}
+		if stdoutIsTerminal() {
+			log.Fatal("-gzip: refusing to write compressed output to a terminal")
+		}
+		out, closeOutput = compressOutput(out)
+	} else if *paginate && formats == 0 && *outputDir == "" && *serveAddr == "" && stdoutIsTerminal() {
+		if opts.Color == printer.ColorAuto && os.Getenv("NO_COLOR") == "" {
+			opts.Color = printer.ColorAlways
+		}
+		out, closeOutput = startPager()
+	}
+	if baselineFile != "" {
+		f, err := os.Create(baselineFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		out, closeOutput = f, func() {
+			if err := f.Close(); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+	defer closeOutput()
+	p := newPrinter(out, readFile, opts)
+	if *htmlAppend != "" {
+		if *outputDir != "" {
+			log.Fatal("you can have only one of html-append and output-dir")
+		}
+		report, err := ioutil.ReadFile(*htmlAppend)
+		if err != nil && !os.IsNotExist(err) {
+			log.Fatal(err)
+		}
+		f, err := os.OpenFile(*htmlAppend, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		p = printer.ContinueHTML(f, readFile, opts, report)
+	}
+	if *outputDir != "" {
+		p = newDirPrinter(*outputDir, ext, func(w io.Writer) printer.Printer {
+			return newPrinter(w, readFile, opts)
+		})
+	}
+
+	startProfiling()
+	defer stopProfiling()
+
+	if *indexDir != "" {
+		if err := printIndexMatches(out, *indexDir); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if *selftest {
+		ok, err := runSelftest(out)
+		if err != nil {
+			fatal(err)
+		}
+		if !ok {
+			closeOutput()
+			stopProfiling()
+			os.Exit(1)
+		}
+		return
+	}
+	if *blameAuthors && !checkBlame() {
+		*blameAuthors = false
+	}
+	if *serveAddr != "" {
+		if err := serve(*serveAddr, opts); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	if *dirty {
+		if err := loadDirtyFiles(); err != nil {
+			fatal(err)
+		}
+	}
+	startTimeout(*timeout)
 	if *verbose {
 		log.Println("Building suffix tree")
 	}
-	schan := job.Parse(filesFeed())
+	var stats job.Stats
+	feed := filesFeed()
+	if sample.isSet() {
+		feed = sampleFiles(feed, sample, *sampleSeed)
+	}
+	if *seed != "" {
+		feed = seedFirst(*seed, feed)
+	}
+	if !*noMergeIdentical {
+		feed = mergeIdentical(feed)
+	}
+	if *movesSince != "" {
+		names, err := loadRefFiles(*movesSince, paths)
+		if err != nil {
+			fatal(err)
+		}
+		feed = withFiles(feed, names)
+	}
+	schan := job.Parse(feed, readFile, parseOptions(), &stats, scanStop)
 	t, data, done := job.BuildTree(schan)
 	<-done
+	if timedOut() {
+		exitTimeout(closeOutput)
+	}
+	if *seed != "" {
+		seedLen = seedTokens(*data, *seed)
+		if seedLen == 0 {
+			fatal("no tokens found in seed file ", *seed)
+		}
+	}
+
+	totals := func() printer.Totals {
+		return printer.Totals{
+			Files:       stats.Files,
+			Lines:       stats.Lines,
+			Tokens:      stats.Tokens,
+			Duration:    time.Since(start),
+			SampledFrom: sampledFrom,
+		}
+	}
+
+	if *exportFingerprints != "" {
+		if err := exportFingerprintsTo(*exportFingerprints, *data); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if *reference != "" {
+		if err := printReferenceMatches(p, *reference, *data, totals); err != nil {
+			fatal(err)
+		}
+		return
+	}
 
 	// finish stream
 	t.Update(&syntax.Node{Type: -1})
@@ -65,55 +455,112 @@ func main() {
 		log.Println("Searching for clones")
 	}
 
-	newPrinter := printer.NewText
-	if *html {
-		newPrinter = printer.NewHTML
-	} else if *plumbing {
-		newPrinter = printer.NewPlumbing
+	from, to := thresholdRange(stats.Filenames)
+	duplChan := make(chan syntax.Match)
+	if *testSetup {
+		go findTestSetups(data, from, duplChan)
+	} else {
+		var mchan <-chan suffixtree.Match
+		if seedLen > 0 {
+			// Only the matches with an occurrence in the seed file,
+			// whose tokens come first, are searched for.
+			mchan = t.FindDuplOverIn(from, suffixtree.Pos(seedLen), scanStop)
+		} else {
+			mchan = t.FindDuplOverUntil(from, scanStop)
+		}
+		go findDuplicates(data, from, to, mchan, duplChan)
 	}
-	p := newPrinter(os.Stdout, ioutil.ReadFile)
 
-	duplChans := make([]<-chan syntax.Match, 0)
-	for i := *fromThreshold; i <= *toThreshold; i += 1 {
-		mchan := t.FindDuplOver(*fromThreshold)
-		duplChan := make(chan syntax.Match)
-		go findDuplicates(data, i, mchan, duplChan)
-		duplChans = append(duplChans, duplChan)
+	if *exactPrepass {
+		p = &exactPrepassPrinter{p, findExactRegions(stats.Filenames)}
+	}
+	dupl, err := printDupls(p, duplChan, totals)
+	if err == errTimeout {
+		exitTimeout(closeOutput)
+	} else if err != nil {
+		fatal(err)
 	}
+	if *sqlitePath != "" {
+		if err := writeSQLite(*sqlitePath, dupl.list, totals()); err != nil {
+			fatal(err)
+		}
+	}
+	if *reportUnmatch {
+		printUnmatched(os.Stderr, stats.Filenames, dupl.files)
+	}
+	if *verbose {
+		printIdentical(os.Stderr)
+	}
+	printErrorBlocks(os.Stderr)
+	if dupl.truncated {
+		closeOutput()
+		stopProfiling()
+		os.Exit(truncatedStatus)
+	}
+	if !checkBudgets(os.Stderr, dupl, totals()) {
+		closeOutput()
+		stopProfiling()
+		os.Exit(1)
+	}
+}
 
-	if err := printDupls(p, duplChans); err != nil {
-		log.Fatal(err)
+// printUnmatched writes the scanned files that are not part of any
+// reported clone group to w, one per line.
+func printUnmatched(w io.Writer, scanned []string, reported map[string]bool) {
+	sorted := append([]string(nil), scanned...)
+	sort.Strings(sorted)
+	for _, file := range sorted {
+		if !reported[file] {
+			fmt.Fprintln(w, file)
+		}
 	}
 }
 
-func findDuplicates(data *[]*syntax.Node, threshold int, mchan <-chan suffixtree.Match, duplChan chan<- syntax.Match) {
+// findDuplicates sends the clones of at least from tokens found in the
+// suffix tree matches to duplChan. A single suffix tree match yields
+// different syntax units for every threshold up to to; each distinct
+// clone is sent once per match.
+func findDuplicates(data *[]*syntax.Node, from, to int, mchan <-chan suffixtree.Match, duplChan chan<- syntax.Match) {
 	for m := range mchan {
-		match := syntax.FindSyntaxUnits(*data, m, threshold)
-		if len(match.Frags) > 0 {
-			// this match should contain all the filenames to avoid duplicates within the same file
-			// and just print out the same file.
-			matchesFiles := func() bool {
-				// just use a map, it's easy to compare
-				pathMap := make(map[string]struct{})
-				for _, path := range paths {
-					pathMap[path] = struct{}{}
+		if stopped() {
+			// Drain the matches sent before the search stopped.
+			continue
+		}
+		if *verboseMatches {
+			frags := make([][]*syntax.Node, len(m.Ps))
+			for i, pos := range m.Ps {
+				frags[i] = (*data)[pos : pos+1]
+			}
+			tracef("suffix tree match of %d tokens at %s", m.Len, fragPositions(frags))
+		}
+		// Units cannot be longer than the match itself.
+		type key struct {
+			hash  string
+			first *syntax.Node
+		}
+		seen := make(map[key]bool)
+		for threshold := from; threshold <= to && threshold <= int(m.Len); threshold++ {
+			matches := syntax.FindSyntaxUnits(*data, m, threshold)
+			if len(matches) == 0 {
+				tracef("[t=%d]   rejected: no complete syntax units of at least %d tokens", threshold, threshold)
+			}
+			for _, match := range matches {
+				if seedLen > 0 && !hasSeedFrag(match) {
+					continue
 				}
-
-				for i := 0; i < len(match.Frags) && len(pathMap) != 0; i++ {
-					for _, node := range match.Frags[i] {
-						for parentPath, _ := range pathMap {
-							if strings.HasPrefix(node.Filename, parentPath) {
-								delete(pathMap, parentPath)
-								break
-							}
-						}
-					}
+				k := key{match.Hash, match.Frags[0][0]}
+				if seen[k] {
+					continue
 				}
-
-				return len(pathMap) == 0
-			}
-
-			if matchesFiles() {
+				seen[k] = true
+				// this match should contain all the filenames to avoid duplicates within the same file
+				// and just print out the same file.
+				if !matchesFiles(match) {
+					tracef("[t=%d]   units at %s rejected: not found in all of the paths %v",
+						threshold, fragPositions(match.Frags), paths)
+					continue
+				}
+				tracef("[t=%d]   units of %d tokens at %s accepted", threshold, match.Tokens, fragPositions(match.Frags))
 				duplChan <- match
 			}
 		}
@@ -121,125 +568,608 @@ func findDuplicates(data *[]*syntax.Node, threshold int, mchan <-chan suffixtree
 	close(duplChan)
 }
 
-func filesFeed() chan string {
-	if *files {
-		fchan := make(chan string)
-		go func() {
-			s := bufio.NewScanner(os.Stdin)
-			for s.Scan() {
-				f := s.Text()
-				fchan <- strings.TrimPrefix(f, "./")
-			}
-			close(fchan)
-		}()
-		return fchan
-	}
-	return crawlPaths(paths)
+// parseOptions returns the options of building the syntax trees.
+func parseOptions() golang.Options {
+	return golang.Options{
+		Tables:             *tables,
+		NormalizeReceivers: *normReceivers,
+		ReorderTolerant:    *reorderTolerant,
+		GenericsAware:      *genericsAware,
+		Normalize:          golang.Normalization(normalize),
+		ConstBlocks:        *constBlocks,
+		BodiesOnly:         *bodiesOnly,
+		Blank:              golang.BlankMode(blank),
+		GoVersion:          int(goVersion),
+	}
 }
 
-func crawlPaths(paths []string) chan string {
-	fchan := make(chan string)
-	go func() {
-		for _, path := range paths {
-			info, err := os.Lstat(path)
-			if err != nil {
-				log.Fatal(err)
-			}
-			if !info.IsDir() {
-				fchan <- path
-				continue
+// matchesFiles reports whether every path given on the command line
+// contains a fragment of the match. A file as of the -moves-since ref
+// is in the path of its working-tree version.
+func matchesFiles(match syntax.Match) bool {
+	if *files || *filesFrom != "" {
+		// The files are listed, no paths are given.
+		return true
+	}
+	// just use a map, it's easy to compare
+	pathMap := make(map[string]struct{})
+	for _, path := range paths {
+		pathMap[foldPath(filepath.Clean(path))] = struct{}{}
+	}
+	if _, ok := pathMap["."]; ok {
+		// Every relative path is found in the current directory.
+		delete(pathMap, ".")
+	}
+
+	for i := 0; i < len(match.Frags) && len(pathMap) != 0; i++ {
+		for _, node := range match.Frags[i] {
+			name := node.Filename
+			if path, ok := refFiles[name]; ok {
+				name = path
 			}
-			err = filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
-				if !*vendor && (strings.HasPrefix(path, vendorDirPrefix) ||
-					strings.Contains(path, vendorDirInPath)) {
-					return nil
-				}
-				if !info.IsDir() && strings.HasSuffix(info.Name(), ".go") {
-					fchan <- path
+			for parentPath := range pathMap {
+				if strings.HasPrefix(foldPath(name), parentPath) || foldPath(archiveOf(name)) == parentPath {
+					delete(pathMap, parentPath)
+					break
 				}
-				return nil
-			})
-			if err != nil {
-				log.Fatal(err)
 			}
 		}
+	}
+
+	return len(pathMap) == 0
+}
+
+func filesFeed() chan string {
+	if *filesFrom != "" && *filesFrom != "-" {
+		f, err := os.Open(*filesFrom)
+		if err != nil {
+			fatal(err)
+		}
+		return fileList(f)
+	}
+	if *files || *filesFrom == "-" {
+		return fileList(os.Stdin)
+	}
+	fchan, errc := crawlPaths(paths)
+	out := make(chan string)
+	go func() {
+		for file := range fchan {
+			out <- file
+		}
+		if err := <-errc; err != nil {
+			fatal(err)
+		}
+		close(out)
+	}()
+	return out
+}
+
+// fileList sends the file names read from r, one at each line, until
+// the scan is stopped. The reader is closed when read.
+func fileList(r io.ReadCloser) chan string {
+	fchan := make(chan string)
+	go func() {
+		s := bufio.NewScanner(r)
+		for !stopped() && s.Scan() {
+			f := s.Text()
+			fchan <- strings.TrimPrefix(f, "./")
+		}
+		if err := s.Err(); err != nil {
+			fatal(err)
+		}
+		r.Close()
 		close(fchan)
 	}()
 	return fchan
 }
 
-func printDupls(p printer.Printer, duplChans []<-chan syntax.Match) error {
+// crawlPaths sends the Go files of the paths, until the scan is stopped
+// or a path cannot be crawled. When the files channel is closed, the
+// error channel has the error that stopped the crawl, or nil.
+func crawlPaths(paths []string) (chan string, <-chan error) {
+	fchan := make(chan string)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- crawl(paths, fchan)
+		close(fchan)
+	}()
+	return fchan, errc
+}
+
+func crawl(paths []string, fchan chan<- string) error {
+	for _, path := range paths {
+		if stopped() {
+			return nil
+		}
+		if path == "-" {
+			if err := readStdin(); err != nil {
+				return err
+			}
+			fchan <- stdinName
+			continue
+		}
+		info, err := os.Lstat(path)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && isArchive(path) {
+			// The files of a single archive cannot shadow others.
+			err := readArchive(path, len(paths) > 1, func(file string) { fchan <- file })
+			if err == errStopped {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			continue
+		}
+		if !info.IsDir() {
+			fchan <- path
+			continue
+		}
+		err = crawlDir(path, func(file string) {
+			if *includeIgnored || !buildIgnoredFile(file) {
+				fchan <- file
+			}
+		})
+		if err == errStopped {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printDupls prints the clone groups received from duplChan and returns
+// the measures of the reported ones.
+func printDupls(p printer.Printer, duplChan <-chan syntax.Match, totals func() printer.Totals) (*duplication, error) {
 	groups := make(map[string][][]*syntax.Node)
-	for _, duplChan := range duplChans {
-		for dupl := range duplChan {
-			groups[dupl.Hash] = append(groups[dupl.Hash], dupl.Frags...)
+	tokens := make(map[string]int)
+	matches, frags := 0, 0
+	truncated := false
+	for dupl := range duplChan {
+		// The limits are checked before the match is collected, so that
+		// the report never has more fragments than allowed.
+		if limit := limitExceeded(matches+1, frags+len(dupl.Frags)); limit != "" {
+			fmt.Fprintf(os.Stderr, "dupl: %s reached after %d fragments; "+
+				"reporting only the clones collected so far\n", limit, frags)
+			truncated = true
+			stopScan()
+			break
 		}
+		groups[dupl.Hash] = append(groups[dupl.Hash], dupl.Frags...)
+		tokens[dupl.Hash] = dupl.Tokens
+		matches++
+		frags += len(dupl.Frags)
+	}
+	for range duplChan {
+		// The search stops soon; let it end.
 	}
 	keys := make([]string, 0, len(groups))
 	for k := range groups {
 		keys = append(keys, k)
 	}
 	sort.Strings(keys)
+	if timedOut() {
+		return nil, errTimeout
+	}
 
 	if err := p.PrintHeader(); err != nil {
-		return err
+		return nil, err
 	}
+	dupl := newDuplication()
+	dupl.truncated = truncated
+	var candidates []printer.Group
 	for _, k := range keys {
-		uniq := unique(groups[k])
-		if len(uniq) > 1 {
-			if err := p.PrintClones(uniq); err != nil {
-				return err
+		uniq := syntax.Unique(groups[k])
+		if len(uniq) < 2 {
+			tracef("group at %s rejected: all fragments start at the same position", fragPositions(uniq))
+			continue
+		}
+		// The order of the fragments depends on the order the matches
+		// were found in; make the output independent of it.
+		sort.Slice(uniq, func(i, j int) bool {
+			a, b := uniq[i][0], uniq[j][0]
+			if a.Filename != b.Filename {
+				return a.Filename < b.Filename
 			}
+			return a.Pos < b.Pos
+		})
+		g := printer.NewGroup(syntax.Match{Hash: k, Frags: uniq, Tokens: tokens[k]})
+		if ignoreHashes[g.ID()] {
+			reject(&g, "accepted by -ignore-hash")
+			continue
 		}
+		candidates = append(candidates, g)
 	}
-	return p.PrintFooter()
-}
-
-func unique(group [][]*syntax.Node) [][]*syntax.Node {
-	fileMap := make(map[string]map[int]struct{})
-
-	var newGroup [][]*syntax.Node
-	for _, seq := range group {
-		node := seq[0]
-		file, ok := fileMap[node.Filename]
-		if !ok {
-			file = make(map[int]struct{})
-			fileMap[node.Filename] = file
+	if *nearMissLines > 0 {
+		var err error
+		if candidates, err = mergeNearMisses(candidates, *nearMissLines); err != nil {
+			return nil, err
+		}
+	}
+	var byDir, moves []printer.Group
+	for _, g := range candidates {
+		if timedOut() {
+			if err := p.PrintFooter(totals()); err != nil {
+				return nil, err
+			}
+			return dupl, errTimeout
 		}
-		if _, ok := file[node.Pos]; !ok {
-			file[node.Pos] = struct{}{}
-			newGroup = append(newGroup, seq)
+		gs := []printer.Group{g}
+		switch {
+		case *selfClones:
+			var err error
+			if gs, err = splitByFunc(g); err != nil {
+				return nil, err
+			}
+		case *onlyDirs:
+			gs = splitByDir(g)
+		case *movesSince != "":
+			gs = splitMoves(g)
+		}
+		for _, g := range gs {
+			if *minGap > 0 {
+				if err := collapseClose(&g, *minGap); err != nil {
+					return nil, err
+				}
+				if len(g.Frags) < 2 {
+					reject(&g, fmt.Sprintf("fragments less than %d lines apart", *minGap))
+					continue
+				}
+			}
+			ok, err := report(&g)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			if *onlyDirs {
+				// printed by directory below
+				byDir = append(byDir, g)
+				continue
+			}
+			if g.Moved {
+				// printed after the duplications
+				moves = append(moves, g)
+				continue
+			}
+			if err := p.PrintClones(g); err != nil {
+				return nil, err
+			}
+			dupl.add(g)
+		}
+	}
+	sort.SliceStable(byDir, func(i, j int) bool { return groupDir(byDir[i]) < groupDir(byDir[j]) })
+	for _, g := range byDir {
+		if err := p.PrintClones(g); err != nil {
+			return nil, err
+		}
+		dupl.add(g)
+	}
+	for _, g := range moves {
+		if err := p.PrintClones(g); err != nil {
+			return nil, err
 		}
 	}
-	return newGroup
+	return dupl, p.PrintFooter(totals())
+}
+
+// version returns the version of dupl from the build info, if available.
+func version() string {
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		return bi.Main.Version
+	}
+	return ""
 }
 
 func usage() {
-	fmt.Fprintln(os.Stderr, `Usage: dupl [flags] [paths]
+	fmt.Fprintln(os.Stderr, `Usage: dupl [scan] [flags] [paths]
+       dupl baseline [flags] file [paths]
+       dupl serve [flags] addr [paths]
+       dupl version
+
+Commands:
+  scan      search for clones in the paths, the default command
+  baseline  write the ids of the clone groups of the paths into file,
+            to be accepted with -ignore-hashes
+  serve     serve the clones of the paths as JSON over HTTP on addr
+  version   print the version of dupl
 
 Paths:
   If the given path is a file, dupl will use it regardless of
   the file extension. If it is a directory, it will recursively
-  search for *.go files in that directory.
+  search for *.go files in that directory. If it is a .tar,
+  .tar.gz, .tgz, or .zip archive, dupl will use the *.go files
+  in the archive.
+
+  If the path is -, dupl will read a single Go file from stdin
+  and name it <stdin>.
 
   If no path is given, dupl will recursively search for *.go
   files in the current directory.
 
+Environment:
+  DUPL_FLAGS  flags read before those on the command line,
+              which override them
+
 Flags:
   -files
     	read file names from stdin one at each line
+  -files-from file
+    	read file names from file one at each line, or from stdin
+    	if file is -
   -html
     	output the results as HTML, including duplicate code fragments
+  -html-append file
+    	append the clones to the HTML report in file, creating it
+    	if it does not exist, instead of printing them
   -plumbing
     	plumbing (easy-to-parse) output for consumption by scripts or tools
-  -from-threshold size
+  -json
+    	output the results as a JSON document
+  -json-pretty
+    	output the results as a JSON document indented by two spaces
+  -fragment-detail
+    	add the lines of the syntax units of each fragment, relative
+    	to its first line, to the JSON output
+  -github
+    	output a GitHub Actions warning command for each fragment, to be
+    	annotated on the lines of a pull request
+  -dot
+    	output a Graphviz graph of files sharing clones
+  -csv
+    	output a CSV table with a row for each fragment
+  -suggest-fix
+    	output patches extracting the clones into helper functions
+    	where it is obviously safe
+  -text-template file
+    	output the report given by the Go template in file, executed
+    	with all the clone groups at once
+  -count
+    	print only the number of the reported clone groups
+  -diff-against file
+    	compare the single file given with file only, and show the code
+    	they share side by side
+  -output-dir dir
+    	write a separate report for each package into dir
+  -gzip
+    	compress the report written to stdout, or the reports of
+    	-output-dir, which are named with .gz added, by gzip
+  -sqlite file
+    	add the run with its clone groups to the SQLite database file
+  -serve addr
+    	serve the clones as JSON over HTTP on addr, e.g. :8080,
+    	instead of printing them
+  -selftest
+    	search built-in fixtures with known clones using the flags
+    	given, instead of the paths, and exit 1 unless all are found
+  -color when
+    	colorize the text output: always, never, or auto (default),
+    	which colorizes only if stdout is a terminal
+  -no-color
+    	alias for -color=never
+  -paginate
+    	page the text output through $PAGER (default less)
+    	if stdout is a terminal
+  -with-source
+    	include the duplicated code in the text and JSON output
+  -without-source
+    	omit the duplicated code from the HTML output
+  -tab-width n
+    	show the indentation of the duplicated code as spaces, by tab
+    	stops of n columns, instead of as it is in the files
+  -show-package
+    	show the package and the imports referenced by each clone
+    	in the text and HTML output
+  -show-scope
+    	show the scope path of each fragment, like pkg.(*T).method,
+    	in the text and HTML output
+  -blame
+    	show the author of most of the lines of each fragment, by
+    	git blame, in the text and JSON output
+  -func-signatures
+    	add the signature of the function enclosing each fragment,
+    	or - if there is none, to the plumbing output
+  -fragment-ids
+    	add the identifier of each fragment, stable across edits elsewhere
+    	in its file, to the plumbing output, after the signature if any
+  -similarity
+    	show the percentage of tokens of each fragment same as in the
+    	largest fragment of its clone in the text and JSON output
+  -template-view
+    	show in the text output the code shared by each clone, with
+    	placeholders where the fragments differ, and what each fills them with
+  -explain
+    	explain in the text and HTML output why each group is a clone
+  -message-template template
+    	describe each clone group in the JSON and GitHub output by the Go template
+    	(default "{{.Copies}} clones of {{.Tokens}} tokens in {{join .Files ", "}}")
+  -rule-id id
+    	identify the clone groups in the JSON and GitHub output by id (default "dupl")
+  -t, -from-threshold size
     	minimum token sequence size as a clone (default 15)
   -to-threshold size
-        maximum token sequence size as a clone (default 15)
+    	maximum token sequence size as a clone (default 15)
+  -threshold lang=size,...
+    	minimum token sequence size as a clone in the files of each
+    	language, named by their extension, e.g. go=30; the others use
+    	-from-threshold, which a size without a language sets, as in
+    	-threshold 30; can be repeated
+  -path path:size
+    	minimum token sequence size as a clone in the files in path,
+    	e.g. backend:30, overriding -threshold; the longest path
+    	containing a file applies; can be repeated
+  -max-span-lines n
+    	do not report clones with a fragment spanning more than n lines
+  -min-gap n
+    	drop the fragments of a clone less than n lines after another
+    	fragment of it in the same file
+  -near-miss-lines n
+    	join the clone pairs of the same two files separated by at most
+    	n differing lines on both sides into a single near-miss clone
+  -exact-prepass
+    	also report the byte-identical regions of at least 10 lines,
+    	found by hashing the lines, in a section before the other clones
+  -min-token-kinds k
+    	do not report clones made of fewer than k distinct kinds
+    	of syntax nodes (default 1)
+  -error-blocks mode
+    	report (default) the clones consisting of error handling like
+    	any other, only summarize their number on stderr, or ignore them
+  -exact-only
+    	report only clones whose source texts differ at most in whitespace
+  -strict-structural
+    	compare only the structure of the code, ignoring all identifiers,
+    	literals, comments, and formatting, and refuse the options that
+    	change what is compared
+  -go-version 1.n
+    	skip with a warning the files using syntax newer than Go 1.n,
+    	like generics before 1.18; by default the latest syntax is accepted
+  -normalize mode
+    	consider all identifiers the same (all, the default), keep
+    	the package-qualified and selected names significant (locals),
+    	and the type names too (types), or keep all identifiers
+    	significant (none)
+  -blank mode
+    	keep the blank identifier _ significant (significant) or consider
+    	it the same as the normalized identifiers (ignore); by default it
+    	is an identifier like the others
+  -tables
+    	search for clones only among rows of composite literals,
+    	e.g. in test tables, regardless of their literal values
+  -const-blocks
+    	search for clones only among package-level const declarations,
+    	in different packages, by the names of the constants
+  -bodies-only
+    	search for clones only in the bodies of functions, leaving out
+    	the declarations of types, variables, and constants
+  -normalize-receivers
+    	consider all method receivers the same, so that alike methods
+    	of different types are clones, e.g. (t T) and (p *P)
+  -reorder-tolerant
+    	experimental: ignore the order of adjacent independent statements
+  -generics-aware
+    	consider all type parameters the same and drop the type
+    	arguments of calls, so that generic functions match their copies
+  -test-setup
+    	report the statements several test functions start with, to be
+    	extracted into a shared helper
+  -switch-cases
+    	report only clones that are whole bodies of case clauses,
+    	along with their case expressions
+  -cross-module
+    	report only clones with fragments in more than one Go module
+  -only-dirs
+    	report only the clones within each directory, split from the
+    	clones spanning several directories, ordered by directory
+  -self
+    	report only the clones within each function, split from the
+    	others, with the function as the context of the fragments
+  -moves-since ref
+    	also scan the Go files as of the Git ref, and report the blocks
+    	moved to another file since separately, as moved clones
   -vendor
     	check files in vendor directory
+  -vendor-only
+    	check only the files in vendor directories, e.g. to audit the
+    	duplication within the vendored dependencies
+  -vendor-pairs
+    	report only the clones between files out of the vendor directory
+    	and their vendored counterparts of the same directory and file name
+  -allow-pair dirA:dirB
+    	do not report clones found only within dirA and dirB;
+    	can be repeated
+  -exclude-func regexp
+    	ignore clones in functions whose names match regexp;
+    	can be repeated
+  -ignore-pattern regexp
+    	ignore clones whose largest fragment has source text matching
+    	regexp; can be repeated
+  -ignore-dir dir
+    	ignore clones in the directory dir; can be repeated
+  -exclude-lines file:start-end
+    	ignore the clones intersecting the lines start to end of file;
+    	can be repeated
+  -ignore-hash id
+    	ignore the clone group with the groupId id of the JSON output;
+    	can be repeated
+  -ignore-hashes file
+    	ignore the clone groups with the ids listed in file, one at each line
+  -ignore-file file
+    	read more of the above from the //dupl: directives in the Go
+    	source file (default dupl_ignore.go, if it exists)
+  -report-unmatched
+    	list the scanned files without any reported clone on stderr
+  -max-groups n
+    	exit with status 1 if more than n clone groups are reported
+  -max-duplicated-tokens n
+    	exit with status 1 if the reported clones have more than
+    	n tokens in total
+  -max-duplicated-percent p
+    	exit with status 1 if more than p percent of all the scanned
+    	tokens are in the reported clones
+  -max-total-fragments n
+    	stop collecting clones after n fragments, report those collected,
+    	and exit with status 4
+  -max-memory size
+    	stop collecting clones once the heap exceeds size, e.g. 2GiB,
+    	report those collected, and exit with status 4
+  -timeout d
+    	abort the scan after the duration d, e.g. 30s, and exit
+    	with status 3
+  -sample n
+    	scan only n randomly chosen files, or n percent of them if n
+    	ends with %, for a quick estimate
+  -sample-seed n
+    	choose the sample by the seed n instead of a random one
+  -dirty
+    	report only the clones with a fragment in a Go file modified, staged,
+    	or untracked in the Git working tree; all the files are still scanned
+  -seed file
+    	report only clones with a fragment in file
+  -skip-missing
+    	skip the paths that do not exist with a warning instead of failing,
+    	unless none of them exist
+  -ci-paths
+    	compare paths case-insensitively, as the file systems on Windows
+    	and macOS do (default true on these systems)
+  -respect-gitignore
+    	skip the files and directories ignored by the .gitignore files
+    	when walking directories
+  -include-testdata
+    	also walk the directories named testdata, which are skipped
+    	by default, like by the go tool
+  -include-ignored
+    	also scan the files whose build constraints exclude them from
+    	every build, like //go:build ignore, which are skipped by default
+  -no-crawl-cache
+    	always walk directories instead of reusing the cached file list
+  -no-merge-identical
+    	search for clones in byte-identical files too instead of
+    	leaving them out, as listed on stderr with -v
+  -export-fingerprints file
+    	write fingerprints of all syntax units of at least the minimum
+    	size, with their files and lines, into file instead of searching
+    	for clones
+  -reference file
+    	report code matching the fingerprints exported into file
+    	instead of searching for clones
+  -index dir
+    	report the code found in more than one of the fingerprint files
+    	exported into dir instead of searching for clones
+  -hash name
+    	hash the syntax units, grouping the clones and identifying the
+    	fingerprints, by sha1 (default), sha256, fnv, or xxhash
+  -cpuprofile file
+    	write a CPU profile into file
+  -memprofile file
+    	write a memory profile into file after the run
   -v, -verbose
     	explain what is being done
+  -verbose-matches
+    	explain which matches are found and why they are rejected
 
 Examples:
   dupl -t 100
@@ -248,6 +1178,8 @@ Examples:
   dupl $(find app/ -name '*_test.go')
     	Search for clones in tests in the app directory.
   find app/ -name '*_test.go' |dupl -files
-    	The same as above.`)
+    	The same as above.
+  dupl -files-from tests.txt
+    	Search for clones in the files listed in tests.txt.`)
 	os.Exit(2)
 }
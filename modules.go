@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// moduleRoots caches the module root of each directory.
+var moduleRoots = make(map[string]string)
+
+// workspaces caches the module roots used by the go.work file of each
+// module root, nil if it is in no workspace.
+var workspaces = make(map[string]map[string]bool)
+
+// moduleRoot returns the root directory of the Go module the file
+// belongs to, which is the nearest directory containing go.mod, or ""
+// if there is none. A module in a workspace, the nearest directory up
+// from it containing go.work, is one only if the go.work file uses it;
+// the go command does not build the others there, so their files are
+// taken for being in no module too.
+func moduleRoot(file string) string {
+	dir, err := filepath.Abs(filepath.Dir(file))
+	if err != nil {
+		return ""
+	}
+	root := dirModuleRoot(dir)
+	if root == "" {
+		return ""
+	}
+	used, ok := workspaces[root]
+	if !ok {
+		used = workspaceModules(root)
+		workspaces[root] = used
+	}
+	if used != nil && !used[root] {
+		return ""
+	}
+	return root
+}
+
+func dirModuleRoot(dir string) string {
+	if root, ok := moduleRoots[dir]; ok {
+		return root
+	}
+	root := ""
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+		root = dir
+	} else if parent := filepath.Dir(dir); parent != dir {
+		root = dirModuleRoot(parent)
+	}
+	moduleRoots[dir] = root
+	return root
+}
+
+// workspaceModules returns the module roots used by the nearest go.work
+// file in dir or above, or nil if there is none or it cannot be read.
+func workspaceModules(dir string) map[string]bool {
+	for {
+		src, err := ioutil.ReadFile(filepath.Join(dir, "go.work"))
+		if err == nil {
+			return parseGoWork(dir, src)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
+
+// parseGoWork returns the directories of the use directives of the
+// go.work file in dir, whose content is src.
+func parseGoWork(dir string, src []byte) map[string]bool {
+	used := make(map[string]bool)
+	inUse := false // in a use ( ... ) block
+	s := bufio.NewScanner(bytes.NewReader(src))
+	for s.Scan() {
+		line := s.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		f := strings.Fields(line)
+		switch {
+		case len(f) == 0:
+			continue
+		case inUse && f[0] == ")":
+			inUse = false
+			continue
+		case inUse:
+		case f[0] == "use" && len(f) == 2 && f[1] == "(":
+			inUse = true
+			continue
+		case f[0] == "use" && len(f) == 2:
+			f = f[1:]
+		default:
+			continue
+		}
+		path := f[0]
+		if p, err := strconv.Unquote(path); err == nil {
+			path = p
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, filepath.FromSlash(path))
+		}
+		used[filepath.Clean(path)] = true
+	}
+	return used
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestModuleRoot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dupl-modules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"work/go.work":            "go 1.18\n\nuse (\n\t./a\n\t\"./b\" // quoted\n)\n\nuse ./a/nested\n",
+		"work/a/go.mod":           "module a\n",
+		"work/a/x/a.go":           "package x\n",
+		"work/a/nested/go.mod":    "module nested\n",
+		"work/a/nested/n.go":      "package nested\n",
+		"work/b/go.mod":           "module b\n",
+		"work/b/b.go":             "package b\n",
+		"work/unused/go.mod":      "module unused\n",
+		"work/unused/u.go":        "package unused\n",
+		"work/none/n.go":          "package none\n",
+		"plain/go.mod":            "module plain\n",
+		"plain/p.go":              "package plain\n",
+		"plain/inner/go.mod":      "module inner\n",
+		"plain/inner/deep/i.go":   "package deep\n",
+		"nomodule/sub/nothing.go": "package sub\n",
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		file, root string
+	}{
+		{"work/a/x/a.go", "work/a"},
+		{"work/a/nested/n.go", "work/a/nested"},
+		{"work/b/b.go", "work/b"},
+		// not used by the workspace
+		{"work/unused/u.go", ""},
+		{"work/none/n.go", ""},
+		// no workspace
+		{"plain/p.go", "plain"},
+		{"plain/inner/deep/i.go", "plain/inner"},
+		{"nomodule/sub/nothing.go", ""},
+	}
+	for _, tc := range testCases {
+		want := ""
+		if tc.root != "" {
+			want = filepath.Join(dir, filepath.FromSlash(tc.root))
+		}
+		if got := moduleRoot(filepath.Join(dir, filepath.FromSlash(tc.file))); got != want {
+			t.Errorf("%s: got the module root %q, want %q", tc.file, got, want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mibk/dupl/printer"
+)
+
+// refFiles maps the names of the files read as of the -moves-since ref
+// to their paths in the working tree.
+var refFiles = make(map[string]string)
+
+// loadRefFiles reads the Go files of the paths as of the Git ref into
+// memory, like archive members named ref:path, and returns their names.
+// The files unchanged since the ref are left out: the blocks the files
+// still have were not moved away from them.
+func loadRefFiles(ref string, ps []string) ([]string, error) {
+	args := append([]string{"ls-tree", "-r", "-z", "--name-only", ref, "--"}, ps...)
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("-moves-since %s: git ls-tree: %v", ref, gitError(err))
+	}
+	var names []string
+	var batch strings.Builder
+	for _, name := range strings.Split(string(out), "\x00") {
+		path := filepath.FromSlash(name)
+		if !strings.HasSuffix(name, ".go") || !vendorScanned(path) {
+			continue
+		}
+		names = append(names, path)
+		// A path starting with ./ is relative to the current directory,
+		// as those listed are.
+		fmt.Fprintf(&batch, "%s:./%s\n", ref, name)
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	cmd := exec.Command("git", "cat-file", "--batch")
+	cmd.Stdin = strings.NewReader(batch.String())
+	if out, err = cmd.Output(); err != nil {
+		return nil, fmt.Errorf("-moves-since %s: git cat-file: %v", ref, gitError(err))
+	}
+
+	var files []string
+	r := bufio.NewReader(bytes.NewReader(out))
+	for _, path := range names {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("-moves-since %s: git cat-file: %v", ref, err)
+		}
+		f := strings.Fields(header)
+		if len(f) != 3 {
+			return nil, fmt.Errorf("-moves-since %s: git cat-file: unexpected %q", ref, strings.TrimSpace(header))
+		}
+		size, err := strconv.Atoi(f[2])
+		if err != nil {
+			return nil, fmt.Errorf("-moves-since %s: git cat-file: unexpected %q", ref, strings.TrimSpace(header))
+		}
+		src := make([]byte, size+1) // with the newline after the content
+		if _, err := io.ReadFull(r, src); err != nil {
+			return nil, fmt.Errorf("-moves-since %s: git cat-file: %v", ref, err)
+		}
+		src = src[:size]
+		if cur, err := readFile(path); err == nil && bytes.Equal(cur, src) {
+			continue
+		}
+		name := ref + ":" + path
+		archives.mu.Lock()
+		archives.files[name] = src
+		archives.from[name] = ref
+		archives.mu.Unlock()
+		refFiles[name] = path
+		files = append(files, name)
+	}
+	return files, nil
+}
+
+// gitError returns err with the message Git wrote to stderr, if any.
+func gitError(err error) error {
+	if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+		return fmt.Errorf("%s", strings.TrimSpace(string(ee.Stderr)))
+	}
+	return err
+}
+
+// withFiles returns a feed of the files of feed followed by the names.
+func withFiles(feed chan string, names []string) chan string {
+	fchan := make(chan string)
+	go func() {
+		for file := range feed {
+			fchan <- file
+		}
+		for _, name := range names {
+			fchan <- name
+		}
+		close(fchan)
+	}()
+	return fchan
+}
+
+// splitMoves separates the fragments of the group found in the files
+// of the working tree from those found in the files as of the
+// -moves-since ref. Two or more of the former are a duplication, the
+// latter left out. A single one, found in a file the block was not in
+// as of the ref, but in another one, instead makes the group a move.
+// The group is dropped if it is neither,
+// like that of a block deleted since the ref.
+func splitMoves(g printer.Group) []printer.Group {
+	var cur, old []printer.Fragment
+	for _, frag := range g.Frags {
+		if _, ok := refFiles[frag.Filename]; ok {
+			old = append(old, frag)
+		} else {
+			cur = append(cur, frag)
+		}
+	}
+	if len(cur) >= 2 {
+		g.Frags = cur
+		return []printer.Group{g}
+	}
+	if len(cur) == 0 {
+		reject(&g, "only in the files as of -moves-since "+*movesSince)
+		return nil
+	}
+	to := filepath.Clean(cur[0].Filename)
+	var from []string
+	for _, frag := range old {
+		path := refFiles[frag.Filename]
+		if filepath.Clean(path) == to {
+			reject(&g, "not moved away from "+path)
+			return nil
+		}
+		from = append(from, path)
+	}
+	g.Frags = append(old, cur...)
+	g.Moved = true
+	g.Explanation = fmt.Sprintf("moved since %s from %s to %s", *movesSince, strings.Join(uniqueStrings(from), ", "), cur[0].Filename)
+	return []printer.Group{g}
+}
+
+// uniqueStrings returns the strings of ss without the repeated ones,
+// in their order.
+func uniqueStrings(ss []string) []string {
+	seen := make(map[string]bool)
+	var uniq []string
+	for _, s := range ss {
+		if !seen[s] {
+			seen[s] = true
+			uniq = append(uniq, s)
+		}
+	}
+	return uniq
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// movesBlock returns a function, named name, long enough to be a clone.
+func movesBlock(name, op string) string {
+	return fmt.Sprintf(`
+func %s(a, b int) int {
+	x := a %s b
+	y := a * b
+	if x > y {
+		return x - y
+	}
+	return y - x
+}
+`, name, op)
+}
+
+// gitForTest runs git in the current directory.
+func gitForTest(t *testing.T, args ...string) {
+	t.Helper()
+	args = append([]string{"-c", "user.name=dupl", "-c", "user.email=dupl@example.com"}, args...)
+	if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+}
+
+// writeFiles writes the files, named by their slash-separated paths.
+func writeFiles(t *testing.T, files map[string]string) {
+	t.Helper()
+	for name, src := range files {
+		path := filepath.FromSlash(name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMovesSince(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	oldPaths, oldFrom, oldTo, oldSince, oldNoCache := paths, *fromThreshold, *toThreshold, *movesSince, *noCrawlCache
+	defer func() {
+		os.Chdir(wd)
+		paths = oldPaths
+		*fromThreshold = oldFrom
+		*toThreshold = oldTo
+		*movesSince = oldSince
+		*noCrawlCache = oldNoCache
+	}()
+	*fromThreshold, *toThreshold = 15, 15
+	*movesSince = "HEAD"
+	*noCrawlCache = true
+
+	gitForTest(t, "init", "-q")
+	writeFiles(t, map[string]string{
+		"move/a/x.go":  "package a\n" + movesBlock("f", "+"),
+		"move/b/y.go":  "package b\n",
+		"split/c/x.go": "package c\n" + movesBlock("f", "-"),
+		"split/d/y.go": "package d\n",
+		"split/e/z.go": "package e\n",
+		"copy/f/x.go":  "package f\n" + movesBlock("f", "*"),
+	})
+	gitForTest(t, "add", ".")
+	gitForTest(t, "commit", "-q", "-m", "base")
+	writeFiles(t, map[string]string{
+		"move/a/x.go":  "package a\n",
+		"move/b/y.go":  "package b\n" + movesBlock("g", "+"),
+		"split/c/x.go": "package c\n",
+		"split/d/y.go": "package d\n" + movesBlock("g", "-"),
+		"split/e/z.go": "package e\n" + movesBlock("h", "-"),
+		"copy/g/y.go":  "package g\n" + movesBlock("g", "*"),
+	})
+
+	type group struct {
+		Moved     bool
+		Fragments []struct{ File string }
+	}
+	testCases := []struct {
+		name  string
+		paths []string
+		moved bool
+		files []string
+	}{
+		// Both paths are scanned, the block moved from one to the other.
+		{"move", []string{"move/a", "move/b"}, true, []string{"HEAD:move/a/x.go", "move/b/y.go"}},
+		{"move and duplication", []string{"split/c", "split/d", "split/e"}, false, []string{"split/d/y.go", "split/e/z.go"}},
+		{"duplication", []string{"copy/f", "copy/g"}, false, []string{"copy/f/x.go", "copy/g/y.go"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			refFiles = make(map[string]string)
+			forgetSources()
+			paths = tc.paths
+			names, err := loadRefFiles(*movesSince, paths)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				archives.mu.Lock()
+				for _, name := range names {
+					delete(archives.files, name)
+					delete(archives.from, name)
+				}
+				archives.mu.Unlock()
+				refFiles = make(map[string]string)
+			}()
+			fchan, errc := crawlPaths(paths)
+			out := scanFeedForTest(t, withFiles(fchan, names))
+			if err := <-errc; err != nil {
+				t.Fatal(err)
+			}
+
+			var doc struct{ Groups []group }
+			if err := json.Unmarshal(out, &doc); err != nil {
+				t.Fatal(err)
+			}
+			if len(doc.Groups) != 1 {
+				t.Fatalf("got %d groups, want 1:\n%s", len(doc.Groups), out)
+			}
+			g := doc.Groups[0]
+			var files []string
+			for _, frag := range g.Fragments {
+				files = append(files, filepath.ToSlash(frag.File))
+			}
+			sort.Strings(files)
+			if g.Moved != tc.moved || !reflect.DeepEqual(files, tc.files) {
+				t.Errorf("got moved %v, files %v; want %v, %v", g.Moved, files, tc.moved, tc.files)
+			}
+		})
+	}
+}
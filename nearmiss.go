@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/mibk/dupl/printer"
+	"github.com/mibk/dupl/syntax"
+)
+
+// pairSpan is the fragment of a clone pair with its lines, 1-based.
+type pairSpan struct {
+	frag       printer.Fragment
+	start, end int
+}
+
+// clonePair is a group of two fragments, the first of which
+// precedes the second in the order of the files.
+type clonePair struct {
+	index int // in the groups
+	a, b  pairSpan
+}
+
+// mergeNearMisses joins the chains of clone pairs in the same pair of
+// files whose fragments are separated by at most gap lines on both
+// sides into single near-miss groups. A joint fragment spans from the
+// start of the first fragment of the chain to the end of the last one,
+// and the Tokens of the group count only the matching tokens, that is
+// the sum of those of the joined pairs. The joint groups take the place
+// of the first of their pairs; groups of more than two fragments are
+// left alone.
+func mergeNearMisses(groups []printer.Group, gap int) ([]printer.Group, error) {
+	byFiles := make(map[[2]string][]clonePair)
+	for i, g := range groups {
+		if len(g.Frags) != 2 {
+			continue
+		}
+		a, err := newPairSpan(g.Frags[0])
+		if err != nil {
+			return nil, err
+		}
+		b, err := newPairSpan(g.Frags[1])
+		if err != nil {
+			return nil, err
+		}
+		key := [2]string{a.frag.Filename, b.frag.Filename}
+		byFiles[key] = append(byFiles[key], clonePair{i, a, b})
+	}
+
+	merged := make(map[int]printer.Group)
+	dropped := make(map[int]bool)
+	for _, pairs := range byFiles {
+		sort.SliceStable(pairs, func(i, j int) bool {
+			if pairs[i].a.start != pairs[j].a.start {
+				return pairs[i].a.start < pairs[j].a.start
+			}
+			return pairs[i].b.start < pairs[j].b.start
+		})
+		used := make([]bool, len(pairs))
+		for i := range pairs {
+			if used[i] {
+				continue
+			}
+			chain := []clonePair{pairs[i]}
+			for j := i + 1; j < len(pairs); j++ {
+				last := chain[len(chain)-1]
+				if !used[j] && follows(last, pairs[j], gap) {
+					chain = append(chain, pairs[j])
+					used[j] = true
+				}
+			}
+			if len(chain) < 2 {
+				continue
+			}
+			first, last := chain[0], chain[len(chain)-1]
+			if first.a.frag.Filename == first.b.frag.Filename && last.a.end >= first.b.start {
+				// The joint fragments would overlap.
+				continue
+			}
+			merged[first.index] = joinPairs(groups, chain)
+			for _, p := range chain[1:] {
+				dropped[p.index] = true
+			}
+		}
+	}
+
+	out := groups[:0:0]
+	for i, g := range groups {
+		if m, ok := merged[i]; ok {
+			out = append(out, m)
+		} else if !dropped[i] {
+			out = append(out, g)
+		}
+	}
+	return out, nil
+}
+
+func newPairSpan(frag printer.Fragment) (pairSpan, error) {
+	file, err := readFile(frag.Filename)
+	if err != nil {
+		return pairSpan{}, err
+	}
+	start := bytes.Count(file[:frag.Pos], []byte{'\n'}) + 1
+	end := start + bytes.Count(file[frag.Pos:frag.End], []byte{'\n'})
+	return pairSpan{frag, start, end}, nil
+}
+
+// follows reports whether the pair q continues the pair p, on both
+// sides after at most gap lines.
+func follows(p, q clonePair, gap int) bool {
+	near := func(x, y pairSpan) bool {
+		return y.frag.Pos >= x.frag.End && y.start-x.end-1 <= gap
+	}
+	return near(p.a, q.a) && near(p.b, q.b)
+}
+
+// joinPairs returns the near-miss group of the chain of pairs.
+func joinPairs(groups []printer.Group, chain []clonePair) printer.Group {
+	first, last := chain[0], chain[len(chain)-1]
+	a := printer.Fragment{Filename: first.a.frag.Filename, Pos: first.a.frag.Pos, End: last.a.frag.End}
+	b := printer.Fragment{Filename: first.b.frag.Filename, Pos: first.b.frag.Pos, End: last.b.frag.End}
+	var hashes []byte
+	g := printer.Group{Gaps: len(chain) - 1}
+	for _, p := range chain {
+		hashes = append(hashes, groups[p.index].Hash...)
+		g.Tokens += groups[p.index].Tokens
+		a.Nodes = append(a.Nodes, p.a.frag.Nodes...)
+		b.Nodes = append(b.Nodes, p.b.frag.Nodes...)
+	}
+	// Hashed like the syntax units, by -hash.
+	g.Hash = string(syntax.SeqHasher.Sum(hashes))
+	g.Frags = []printer.Fragment{a, b}
+	return g
+}
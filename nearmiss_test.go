@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mibk/dupl/printer"
+	"github.com/mibk/dupl/syntax"
+)
+
+// lineFragment returns the fragment of the lines start to end, 1-based,
+// of the source.
+func lineFragment(name, src string, start, end int) printer.Fragment {
+	lines := strings.SplitAfter(src, "\n")
+	pos := len(strings.Join(lines[:start-1], ""))
+	last := len(strings.Join(lines[:end], "")) - 1 // without the newline
+	return printer.Fragment{Filename: name, Pos: pos, End: last}
+}
+
+func TestMergeNearMisses(t *testing.T) {
+	var src strings.Builder
+	for i := 1; i <= 12; i++ {
+		fmt.Fprintf(&src, "line%d\n", i)
+	}
+	addMemFile(t, "a.go", src.String())
+	addMemFile(t, "b.go", src.String())
+	pair := func(hash string, aStart, aEnd, bStart, bEnd int) printer.Group {
+		return printer.Group{Hash: hash, Tokens: 10, Frags: []printer.Fragment{
+			lineFragment("a.go", src.String(), aStart, aEnd),
+			lineFragment("b.go", src.String(), bStart, bEnd),
+		}}
+	}
+
+	testCases := []struct {
+		name   string
+		groups []printer.Group
+		want   int // groups after merging
+	}{
+		{"one-line gap", []printer.Group{pair("x", 1, 3, 1, 3), pair("y", 5, 7, 5, 7)}, 1},
+		{"adjacent", []printer.Group{pair("x", 1, 3, 1, 3), pair("y", 4, 6, 4, 6)}, 1},
+		{"two-line gap", []printer.Group{pair("x", 1, 3, 1, 3), pair("y", 6, 8, 6, 8)}, 2},
+		{"gap on one side only", []printer.Group{pair("x", 1, 3, 1, 3), pair("y", 5, 7, 6, 8)}, 2},
+		{"chain", []printer.Group{pair("x", 1, 2, 1, 2), pair("y", 4, 5, 4, 5), pair("z", 7, 8, 7, 8)}, 1},
+	}
+	for _, tc := range testCases {
+		got, err := mergeNearMisses(tc.groups, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != tc.want {
+			t.Errorf("%s: got %d groups, want %d", tc.name, len(got), tc.want)
+			continue
+		}
+		if tc.want > 1 {
+			continue
+		}
+		g := got[0]
+		if g.Gaps != len(tc.groups)-1 || g.Tokens != 10*len(tc.groups) {
+			t.Errorf("%s: got %d gaps and %d tokens, want %d and %d", tc.name, g.Gaps, g.Tokens, len(tc.groups)-1, 10*len(tc.groups))
+		}
+		first, last := tc.groups[0].Frags, tc.groups[len(tc.groups)-1].Frags
+		for i, frag := range g.Frags {
+			if frag.Pos != first[i].Pos || frag.End != last[i].End {
+				t.Errorf("%s: fragment %d spans %d-%d, want %d-%d", tc.name, i, frag.Pos, frag.End, first[i].Pos, last[i].End)
+			}
+		}
+	}
+}
+
+func TestJoinPairsHash(t *testing.T) {
+	defer func(h syntax.Hasher) { syntax.SeqHasher = h }(syntax.SeqHasher)
+	chain := []clonePair{{index: 0}, {index: 1}}
+	groups := []printer.Group{{Hash: "x"}, {Hash: "y"}}
+	syntax.SeqHasher = syntax.Hashers["fnv"]
+	want := string(syntax.Hashers["fnv"].Sum([]byte("xy")))
+	if got := joinPairs(groups, chain).Hash; got != want {
+		t.Errorf("got the hash %x, want %x by -hash fnv", got, want)
+	}
+}
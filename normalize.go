@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+// normalizeFlag is the granularity of the normalization of identifiers.
+type normalizeFlag golang.Normalization
+
+func (f *normalizeFlag) String() string {
+	switch golang.Normalization(*f) {
+	case golang.NormalizeLocals:
+		return "locals"
+	case golang.NormalizeTypes:
+		return "types"
+	case golang.NormalizeNone:
+		return "none"
+	}
+	return "all"
+}
+
+func (f *normalizeFlag) Set(value string) error {
+	switch value {
+	case "all":
+		*f = normalizeFlag(golang.NormalizeAll)
+	case "locals":
+		*f = normalizeFlag(golang.NormalizeLocals)
+	case "types":
+		*f = normalizeFlag(golang.NormalizeTypes)
+	case "none":
+		*f = normalizeFlag(golang.NormalizeNone)
+	default:
+		return fmt.Errorf("invalid mode %q; want all, locals, types, or none", value)
+	}
+	return nil
+}
+
+// blankFlag is the treatment of the blank identifier.
+type blankFlag golang.BlankMode
+
+func (f *blankFlag) String() string {
+	switch golang.BlankMode(*f) {
+	case golang.BlankSignificant:
+		return "significant"
+	case golang.BlankIgnore:
+		return "ignore"
+	}
+	return ""
+}
+
+func (f *blankFlag) Set(value string) error {
+	switch value {
+	case "significant":
+		*f = blankFlag(golang.BlankSignificant)
+	case "ignore":
+		*f = blankFlag(golang.BlankIgnore)
+	default:
+		return fmt.Errorf("invalid mode %q; want significant or ignore", value)
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package main
+
+import "testing"
+
+func TestNormalizeFlag(t *testing.T) {
+	for _, mode := range []string{"all", "locals", "types", "none"} {
+		var f normalizeFlag
+		if err := f.Set(mode); err != nil || f.String() != mode {
+			t.Errorf("Set(%q): got %q, %v", mode, f.String(), err)
+		}
+	}
+	var f normalizeFlag
+	if err := f.Set("names"); err == nil {
+		t.Error(`Set("names") accepted`)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mibk/dupl/printer"
+)
+
+// The report names of the clone groups in the current directory and of
+// those spanning packages. No package directory is named like them, as
+// the names of the directories have no % but those escaping.
+const (
+	rootReport   = "%root"
+	crossPackage = "%cross-package"
+)
+
+// reportEscaper escapes the package directories into report names
+// without any two sharing one: / becomes _, and so _ and the % of the
+// escapes are escaped themselves.
+var reportEscaper = strings.NewReplacer("%", "%25", "_", "%5F", "/", "_")
+
+// dirPrinter writes a separate report for each package into a directory.
+// A group belongs to the package of its fragments, or to the cross-package
+// report if its fragments are found in several packages.
+type dirPrinter struct {
+	dir        string
+	ext        string
+	newPrinter func(io.Writer) printer.Printer
+
+	files    map[string]io.WriteCloser
+	printers map[string]printer.Printer
+}
+
+func newDirPrinter(dir, ext string, newPrinter func(io.Writer) printer.Printer) *dirPrinter {
+	return &dirPrinter{
+		dir:        dir,
+		ext:        ext,
+		newPrinter: newPrinter,
+		files:      make(map[string]io.WriteCloser),
+		printers:   make(map[string]printer.Printer),
+	}
+}
+
+// reportName returns the name of the report of the group: the package
+// directory escaped by reportEscaper, rootReport for the current
+// directory, or crossPackage.
+func reportName(g printer.Group) string {
+	dir := filepath.Dir(g.Frags[0].Filename)
+	for _, frag := range g.Frags[1:] {
+		if filepath.Dir(frag.Filename) != dir {
+			return crossPackage
+		}
+	}
+	if dir == "." {
+		return rootReport
+	}
+	return reportEscaper.Replace(filepath.ToSlash(dir))
+}
+
+func (p *dirPrinter) PrintHeader() error {
+	return os.MkdirAll(p.dir, 0755)
+}
+
+func (p *dirPrinter) PrintClones(g printer.Group) error {
+	name := reportName(g)
+	pr, ok := p.printers[name]
+	if !ok {
+		f, err := createReport(filepath.Join(p.dir, name+p.ext))
+		if err != nil {
+			return err
+		}
+		p.files[name] = f
+		pr = p.newPrinter(f)
+		p.printers[name] = pr
+		if err := pr.PrintHeader(); err != nil {
+			return err
+		}
+	}
+	return pr.PrintClones(g)
+}
+
+func (p *dirPrinter) PrintFooter(t printer.Totals) error {
+	names := make([]string, 0, len(p.printers))
+	for name := range p.printers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := p.printers[name].PrintFooter(t); err != nil {
+			return err
+		}
+		if err := p.files[name].Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
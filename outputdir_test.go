@@ -0,0 +1,44 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/mibk/dupl/printer"
+)
+
+func TestReportName(t *testing.T) {
+	group := func(files ...string) printer.Group {
+		var g printer.Group
+		for _, f := range files {
+			g.Frags = append(g.Frags, printer.Fragment{Filename: filepath.FromSlash(f)})
+		}
+		return g
+	}
+	testCases := []struct {
+		g    printer.Group
+		want string
+	}{
+		{group("internal/auth/a.go", "internal/auth/b.go"), "internal_auth"},
+		{group("a/b_c/x.go", "a/b_c/y.go"), "a_b%5Fc"},
+		{group("a_b/c/x.go", "a_b/c/y.go"), "a%5Fb_c"},
+		{group("a%5Fb/x.go", "a%5Fb/y.go"), "a%255Fb"},
+		{group("/src/app/x.go", "/src/app/y.go"), "_src_app"},
+		{group("src/app/x.go", "src/app/y.go"), "src_app"},
+		{group("a.go", "b.go"), rootReport},
+		{group("root/a.go", "root/b.go"), "root"},
+		{group("cross-package/a.go", "cross-package/b.go"), "cross-package"},
+		{group("a/x.go", "b/x.go"), crossPackage},
+	}
+	seen := make(map[string]string)
+	for _, tc := range testCases {
+		got := reportName(tc.g)
+		if got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.g.Frags[0].Filename, got, tc.want)
+		}
+		if dir, ok := seen[got]; ok {
+			t.Errorf("%s and %s share the report %q", dir, tc.g.Frags[0].Filename, got)
+		}
+		seen[got] = tc.g.Frags[0].Filename
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// defaultPager is used if $PAGER is not set.
+const defaultPager = "less"
+
+// stdoutIsTerminal reports whether stdout is an interactive terminal.
+func stdoutIsTerminal() bool {
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// startPager starts the pager given by $PAGER with its input read from
+// the returned writer. The returned function closes the writer and
+// waits for the pager to exit. If the pager cannot be started, stdout
+// is returned instead.
+func startPager() (io.Writer, func()) {
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		pager = defaultPager
+	}
+	args := strings.Fields(pager)
+	if len(args) == 0 || args[0] == "cat" {
+		return os.Stdout, func() {}
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if os.Getenv("LESS") == "" {
+		// Quit if the output fits on the screen, pass the colors
+		// through, and do not clear the screen on exit.
+		cmd.Env = append(os.Environ(), "LESS=FRX")
+	}
+	w, err := cmd.StdinPipe()
+	if err != nil {
+		log.Println("Cannot start pager:", err)
+		return os.Stdout, func() {}
+	}
+	if err := cmd.Start(); err != nil {
+		log.Println("Cannot start pager:", err)
+		return os.Stdout, func() {}
+	}
+	return w, func() {
+		w.Close()
+		cmd.Wait()
+	}
+}
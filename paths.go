@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// caseInsensitiveFS reports whether the file systems of the platform
+// are usually case-insensitive.
+var caseInsensitiveFS = runtime.GOOS == "windows" || runtime.GOOS == "darwin"
+
+// foldPath returns the path in a form in which paths comparing equal
+// refer to the same file, which is its lower case if the paths are
+// case-insensitive.
+func foldPath(path string) string {
+	if *ciPaths {
+		return strings.ToLower(path)
+	}
+	return path
+}
+
+// existingPaths returns the paths that exist. A missing path is an error
+// naming it, unless -skip-missing is set; then it is reported on w and
+// left out, and only the lack of any existing path is an error.
+func existingPaths(w io.Writer, ps []string) ([]string, error) {
+	var existing []string
+	for _, path := range ps {
+		if path != "-" {
+			if _, err := os.Lstat(path); os.IsNotExist(err) {
+				if !*skipMissing {
+					return nil, fmt.Errorf("path %s does not exist", path)
+				}
+				fmt.Fprintf(w, "dupl: skipping the missing path %s\n", path)
+				continue
+			}
+		}
+		existing = append(existing, path)
+	}
+	if len(existing) == 0 {
+		return nil, errors.New("none of the paths exist")
+	}
+	return existing, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+func TestCaseInsensitivePaths(t *testing.T) {
+	defer func(ci bool, ps []string) { *ciPaths, paths = ci, ps }(*ciPaths, paths)
+	paths = []string{"Pkg/Util", "pkg/other"}
+	match := syntax.Match{Frags: [][]*syntax.Node{
+		{{Filename: filepath.Join("pkg", "util", "a.go")}},
+		{{Filename: filepath.Join("PKG", "Other", "b.go")}},
+	}}
+	file := filepath.Join("PKG", "Util", "a.go")
+
+	*ciPaths = false
+	if matchesFiles(match) {
+		t.Error("case-sensitive: mixed-case paths matched")
+	}
+	if inDir(file, filepath.Join("pkg", "util")) {
+		t.Error("case-sensitive: file found in mixed-case directory")
+	}
+
+	*ciPaths = true
+	if !matchesFiles(match) {
+		t.Error("case-insensitive: mixed-case paths not matched")
+	}
+	if !inDir(file, filepath.Join("pkg", "util")) {
+		t.Error("case-insensitive: file not found in mixed-case directory")
+	}
+	if inDir(file, filepath.Join("pkg", "utils")) {
+		t.Error("case-insensitive: file found in another directory")
+	}
+}
+
+func TestExistingPaths(t *testing.T) {
+	defer func(skip bool) { *skipMissing = skip }(*skipMissing)
+	missing := filepath.Join("testdata", "missing")
+	ps := []string{"printer", missing, "-", "paths.go"}
+
+	*skipMissing = false
+	if _, err := existingPaths(new(bytes.Buffer), ps); err == nil || !strings.Contains(err.Error(), missing) {
+		t.Errorf("got error %v, want one naming %s", err, missing)
+	}
+
+	*skipMissing = true
+	var w bytes.Buffer
+	got, err := existingPaths(&w, ps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"printer", "-", "paths.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got paths %q, want %q", got, want)
+	}
+	if !strings.Contains(w.String(), missing) {
+		t.Errorf("warning %q does not name %s", w.String(), missing)
+	}
+	if _, err := existingPaths(&w, []string{missing}); err == nil {
+		t.Error("no error when all the paths are missing")
+	}
+}
+
+func TestMatchesFilesCurrentDir(t *testing.T) {
+	defer func(ps []string, fs bool, from string) {
+		paths, *files, *filesFrom = ps, fs, from
+	}(paths, *files, *filesFrom)
+	match := syntax.Match{Frags: [][]*syntax.Node{
+		{{Filename: "a.go"}},
+		{{Filename: filepath.Join("pkg", "b.go")}},
+	}}
+	for _, tc := range []struct {
+		paths []string
+		files bool
+		from  string
+		want  bool
+	}{
+		// The default path contains every relative path, though none
+		// of them starts with ".".
+		{[]string{"."}, false, "", true},
+		{[]string{"./"}, false, "", true},
+		{[]string{".", "pkg"}, false, "", true},
+		{[]string{".", "other"}, false, "", false},
+		{[]string{"pkg", "other"}, false, "", false},
+		// The listed files are not under the paths, which are ignored.
+		{[]string{"other"}, true, "", true},
+		{[]string{"other"}, false, "list.txt", true},
+	} {
+		paths, *files, *filesFrom = tc.paths, tc.files, tc.from
+		if got := matchesFiles(match); got != tc.want {
+			t.Errorf("paths %q, -files %v, -files-from %q: got %v, want %v", tc.paths, tc.files, tc.from, got, tc.want)
+		}
+	}
+}
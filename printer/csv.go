@@ -0,0 +1,53 @@
+package printer
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+)
+
+type csvprinter struct {
+	w *csv.Writer
+	ReadFile
+}
+
+// NewCSV returns a printer writing a CSV table, as of RFC 4180, with
+// a row for each fragment, for use in spreadsheets.
+func NewCSV(w io.Writer, fread ReadFile, opts Options) Printer {
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = true
+	return &csvprinter{w: cw, ReadFile: fread}
+}
+
+func (p *csvprinter) PrintHeader() error {
+	return p.write([]string{"group_id", "file", "start_line", "end_line", "tokens", "copies_in_group"})
+}
+
+func (p *csvprinter) PrintClones(g Group) error {
+	clones, err := prepareClonesInfo(p.ReadFile, g.Frags, false, 0)
+	if err != nil {
+		return err
+	}
+	sort.Sort(byNameAndLine(clones))
+	for _, cl := range clones {
+		p.w.Write([]string{
+			g.ID(),
+			cl.filename,
+			strconv.Itoa(cl.lineStart),
+			strconv.Itoa(cl.lineEnd),
+			strconv.Itoa(g.Tokens),
+			strconv.Itoa(len(clones)),
+		})
+	}
+	p.w.Flush()
+	return p.w.Error()
+}
+
+func (p *csvprinter) PrintFooter(Totals) error { return nil }
+
+func (p *csvprinter) write(record []string) error {
+	p.w.Write(record)
+	p.w.Flush()
+	return p.w.Error()
+}
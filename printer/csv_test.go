@@ -0,0 +1,42 @@
+package printer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"testing"
+)
+
+func TestCSV(t *testing.T) {
+	src := "package p\n\nfunc f() {}\n\nfunc g() {}\n"
+	fread := func(string) ([]byte, error) { return []byte(src), nil }
+
+	var buf bytes.Buffer
+	p := NewCSV(&buf, fread, Options{})
+	g := Group{Hash: "01234567", Tokens: 5, Frags: []Fragment{
+		{Filename: "b, c.go", Pos: 24, End: 35},
+		{Filename: `a "x".go`, Pos: 11, End: 22},
+	}}
+	if err := p.PrintHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.PrintClones(g); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.PrintFooter(Totals{}); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"group_id", "file", "start_line", "end_line", "tokens", "copies_in_group"},
+		{"3031323334353637", `a "x".go`, "3", "3", "5", "2"},
+		{"3031323334353637", "b, c.go", "5", "5", "5", "2"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("got %q, want %q", records, want)
+	}
+}
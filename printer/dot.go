@@ -0,0 +1,63 @@
+package printer
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+type dot struct {
+	w     io.Writer
+	edges map[[2]string]int
+}
+
+// NewDOT returns a printer that writes a Graphviz graph of the files
+// connected by an edge if they share a clone. The edge weight is the
+// total number of tokens duplicated between the two files.
+func NewDOT(w io.Writer, fread ReadFile, opts Options) Printer {
+	return &dot{w: w, edges: make(map[[2]string]int)}
+}
+
+func (p *dot) PrintHeader() error { return nil }
+
+func (p *dot) PrintClones(g Group) error {
+	for i, f1 := range g.Frags {
+		for _, f2 := range g.Frags[i+1:] {
+			a, b := f1.Filename, f2.Filename
+			if a == b {
+				continue
+			}
+			if a > b {
+				a, b = b, a
+			}
+			p.edges[[2]string{a, b}] += g.Tokens
+		}
+	}
+	return nil
+}
+
+func (p *dot) PrintFooter(Totals) error {
+	edges := make([][2]string, 0, len(p.edges))
+	for e := range p.edges {
+		edges = append(edges, e)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i][0] == edges[j][0] {
+			return edges[i][1] < edges[j][1]
+		}
+		return edges[i][0] < edges[j][0]
+	})
+
+	fmt.Fprintln(p.w, "graph dupl {")
+	for _, e := range edges {
+		w := p.edges[e]
+		fmt.Fprintf(p.w, "\t%s -- %s [weight=%d, label=%d];\n", dotQuote(e[0]), dotQuote(e[1]), w, w)
+	}
+	_, err := fmt.Fprintln(p.w, "}")
+	return err
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.Replace(s, `"`, `\"`, -1) + `"`
+}
@@ -0,0 +1,26 @@
+package printer
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+)
+
+// fragmentID returns the identifier of the fragment of the file, which
+// stays the same as long as the fragment, its enclosing function, and
+// the path of the file do, wherever the fragment is moved in the file.
+// It is derived from the slash-separated path of the file, the
+// signature of the enclosing function, the source of the fragment
+// with every run of white space replaced by a single space, and the
+// number of the copies of the source earlier in the file, which tells
+// the copies in the same function apart.
+func fragmentID(file []byte, frag Fragment) string {
+	src := file[frag.Pos:frag.End]
+	h := sha1.New()
+	fmt.Fprintf(h, "%s\x00%s\x00", filepath.ToSlash(frag.Filename), frag.Func)
+	h.Write(bytes.Join(bytes.Fields(src), []byte{' '}))
+	fmt.Fprintf(h, "\x00%d", bytes.Count(file[:frag.Pos], src))
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
@@ -0,0 +1,33 @@
+package printer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFragmentID(t *testing.T) {
+	const body = "\tx := 1\n\tprintln(x)\n"
+	src := "package p\n\nfunc f() {\n" + body + body + "}\n"
+	frag := func(src string, n int) Fragment {
+		pos := strings.Index(src, body)
+		pos += n * len(body)
+		return Fragment{Filename: "p.go", Func: "func f()", Pos: pos, End: pos + len(body)}
+	}
+	first := fragmentID([]byte(src), frag(src, 0))
+	second := fragmentID([]byte(src), frag(src, 1))
+	if first == second {
+		t.Errorf("copies in the same function have the same id %s", first)
+	}
+
+	edited := "// Package p.\npackage p\n\nimport \"fmt\"\n\nfunc f() {\n" + body + body + "}\n"
+	if id := fragmentID([]byte(edited), frag(edited, 0)); id != first {
+		t.Errorf("after an edit above the fragment got id %s, want %s", id, first)
+	}
+	reindented := strings.Replace(src, "\t", "  ", -1)
+	rbody := strings.Replace(body, "\t", "  ", -1)
+	pos := strings.Index(reindented, rbody)
+	f := Fragment{Filename: "p.go", Func: "func f()", Pos: pos, End: pos + len(rbody)}
+	if id := fragmentID([]byte(reindented), f); id != first {
+		t.Errorf("after reindenting got id %s, want %s", id, first)
+	}
+}
@@ -0,0 +1,57 @@
+package printer
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+type githubprinter struct {
+	w    io.Writer
+	opts Options
+	ReadFile
+}
+
+// NewGitHub returns a printer writing a GitHub Actions workflow command
+// for each fragment, so that the clones are annotated on the lines of
+// the diff of a pull request.
+func NewGitHub(w io.Writer, fread ReadFile, opts Options) Printer {
+	return &githubprinter{w: w, opts: opts, ReadFile: fread}
+}
+
+func (p *githubprinter) PrintHeader() error { return nil }
+
+func (p *githubprinter) PrintClones(g Group) error {
+	clones, err := prepareClonesInfo(p.ReadFile, g.Frags, false, 0)
+	if err != nil {
+		return err
+	}
+	sort.Sort(byNameAndLine(clones))
+	msg, err := message(p.opts, g.Tokens, clones)
+	if err != nil {
+		return err
+	}
+	for i, cl := range clones {
+		next := clones[(i+1)%len(clones)]
+		_, err := fmt.Fprintf(p.w, "::warning file=%s,line=%d,endLine=%d,title=%s::%s\n",
+			githubProperty(cl.filename), cl.lineStart, cl.lineEnd, githubProperty(ruleID(p.opts)),
+			githubData(fmt.Sprintf("%s; duplicate of %s:%d-%d", msg, next.filename, next.lineStart, next.lineEnd)))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *githubprinter) PrintFooter(Totals) error { return nil }
+
+// githubData escapes the message of a workflow command.
+func githubData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// githubProperty escapes the value of a property of a workflow command.
+func githubProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
@@ -0,0 +1,28 @@
+package printer
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+)
+
+func TestGitHub(t *testing.T) {
+	src := "package p\n\nfunc f() {}\n\nfunc g() {}\n"
+	fread := func(string) ([]byte, error) { return []byte(src), nil }
+	tmpl := template.Must(ParseMessage("{{.Copies}} clones,\n100%"))
+
+	var buf bytes.Buffer
+	p := NewGitHub(&buf, fread, Options{Message: tmpl, RuleID: "dup:1"})
+	g := Group{Tokens: 5, Frags: []Fragment{
+		{Filename: "b,c.go", Pos: 24, End: 35},
+		{Filename: "a.go", Pos: 11, End: 22},
+	}}
+	if err := p.PrintClones(g); err != nil {
+		t.Fatal(err)
+	}
+	want := "::warning file=a.go,line=3,endLine=3,title=dup%3A1::2 clones,%0A100%25; duplicate of b,c.go:5-5\n" +
+		"::warning file=b%2Cc.go,line=5,endLine=5,title=dup%3A1::2 clones,%0A100%25; duplicate of a.go:3-3\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
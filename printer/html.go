@@ -7,21 +7,59 @@ import (
 	"io"
 	"regexp"
 	"sort"
-
-	"github.com/mibk/dupl/syntax"
 )
 
 type htmlprinter struct {
-	iota int
-	w    io.Writer
+	iota  int
+	w     io.Writer
+	pkg   bool
+	scope bool
+	src   bool
+	tab   int             // width of the tab stops
+	cont  bool            // continuing an existing report
+	ids   map[string]bool // the ids of the headings so far
 	ReadFile
 }
 
-func NewHTML(w io.Writer, fread ReadFile) Printer {
-	return &htmlprinter{w: w, ReadFile: fread}
+// NewHTML returns a printer writing an HTML report. The report is
+// self-contained: its styles are inlined and it references no external
+// resources, so it can be archived or sent as a single file.
+func NewHTML(w io.Writer, fread ReadFile, opts Options) Printer {
+	return &htmlprinter{w: w, pkg: opts.ShowPackage, scope: opts.ShowScope, src: opts.Source.include(true), tab: opts.TabWidth,
+		ids: make(map[string]bool), ReadFile: fread}
+}
+
+// ContinueHTML returns a printer that appends the clone groups to the
+// HTML report, whose content is given, numbering them after its groups.
+// The header is written only if the report is empty.
+func ContinueHTML(w io.Writer, fread ReadFile, opts Options, report []byte) Printer {
+	p := NewHTML(w, fread, opts).(*htmlprinter)
+	p.iota = bytes.Count(report, []byte("<h1 "))
+	p.cont = len(report) > 0
+	for _, m := range headingID.FindAllSubmatch(report, -1) {
+		p.ids[string(m[1])] = true
+	}
+	return p
+}
+
+var headingID = regexp.MustCompile(`<h1 id="([^"]*)"`)
+
+// uniqueID returns the id of the group, or, if a heading of the document
+// has it already, like that of a group split by directory or appended
+// to the report again, the id followed by the first free -2, -3, and so on.
+func (p *htmlprinter) uniqueID(g Group) string {
+	id := g.ID()
+	for n := 2; p.ids[id]; n++ {
+		id = fmt.Sprintf("%s-%d", g.ID(), n)
+	}
+	p.ids[id] = true
+	return id
 }
 
 func (p *htmlprinter) PrintHeader() error {
+	if p.cont {
+		return nil
+	}
 	_, err := fmt.Fprint(p.w, `<!DOCTYPE html>
 <meta charset="utf-8"/>
 <title>Duplicates</title>
@@ -36,41 +74,59 @@ func (p *htmlprinter) PrintHeader() error {
 	return err
 }
 
-func (p *htmlprinter) PrintClones(dups [][]*syntax.Node) error {
+func (p *htmlprinter) PrintClones(g Group) error {
 	p.iota++
-	fmt.Fprintf(p.w, "<h1>#%d found %d clones</h1>\n", p.iota, len(dups))
-
-	clones := make([]clone, len(dups))
-	for i, dup := range dups {
-		cnt := len(dup)
-		if cnt == 0 {
-			panic("zero length dup")
-		}
-		nstart := dup[0]
-		nend := dup[cnt-1]
+	fmt.Fprintf(p.w, "<h1 id=\"%s\">#%d found %d %sclones</h1>\n", p.uniqueID(g), p.iota, len(g.Frags), exactLabel(g))
+	if g.Explanation != "" {
+		fmt.Fprintf(p.w, "<p>%s</p>\n", html.EscapeString(g.Explanation))
+	}
 
-		file, err := p.ReadFile(nstart.Filename)
+	clones := make([]clone, len(g.Frags))
+	for i, frag := range g.Frags {
+		file, err := p.ReadFile(frag.Filename)
 		if err != nil {
 			return err
 		}
 
-		lineStart, _ := blockLines(file, nstart.Pos, nend.End)
-		cl := clone{filename: nstart.Filename, lineStart: lineStart}
-		start := findLineBeg(file, nstart.Pos)
-		content := append(toWhitespace(file[start:nstart.Pos]), file[nstart.Pos:nend.End]...)
-		cl.fragment = deindent(content)
+		cl := clone{context: frag.Context, scope: frag.Scope}
+		cl.filename, cl.lineStart, _ = blockPosition(frag.Filename, file, frag.Pos, frag.End)
+		if p.pkg {
+			cl.pkg = packageInfo(file, frag.Pos, frag.End)
+		}
+		if p.src {
+			cl.fragment = fragmentSource(file, frag, p.tab)
+		}
 		clones[i] = cl
 	}
 
 	sort.Sort(byNameAndLine(clones))
 	for _, cl := range clones {
-		fmt.Fprintf(p.w, "<h2>%s:%d</h2>\n<pre>%s</pre>\n", cl.filename, cl.lineStart,
-			html.EscapeString(string(cl.fragment)))
+		fmt.Fprintf(p.w, "<h2>%s:%d</h2>\n", html.EscapeString(cl.filename), cl.lineStart)
+		if cl.context != "" {
+			fmt.Fprintf(p.w, "<p>%s</p>\n", html.EscapeString(cl.context))
+		}
+		if p.scope && cl.scope != "" {
+			fmt.Fprintf(p.w, "<p>in <code>%s</code></p>\n", html.EscapeString(cl.scope))
+		}
+		if cl.pkg != "" {
+			fmt.Fprintf(p.w, "<p><code>%s</code></p>\n", html.EscapeString(cl.pkg))
+		}
+		if p.src {
+			fmt.Fprintf(p.w, "<pre>%s</pre>\n", html.EscapeString(string(cl.fragment)))
+		}
 	}
 	return nil
 }
 
-func (*htmlprinter) PrintFooter() error { return nil }
+func (*htmlprinter) PrintFooter(Totals) error { return nil }
+
+// fragmentSource returns the deindented source of the fragment,
+// whose first line is aligned with the following ones.
+func fragmentSource(file []byte, frag Fragment, tabWidth int) []byte {
+	start := findLineBeg(file, frag.Pos)
+	content := append(toWhitespace(file[start:frag.Pos]), file[frag.Pos:frag.End]...)
+	return trimIndent(content, tabWidth)
+}
 
 func findLineBeg(file []byte, index int) int {
 	for i := index; i >= 0; i-- {
@@ -93,6 +149,43 @@ func toWhitespace(str []byte) []byte {
 	return out
 }
 
+// trimIndent removes the common indentation of the lines of the block:
+// the leading tabs if tabWidth is not positive, or else the columns of
+// the indentation expanded to spaces by tab stops of tabWidth.
+func trimIndent(block []byte, tabWidth int) []byte {
+	if tabWidth <= 0 {
+		return deindent(block)
+	}
+	lines := bytes.Split(block, []byte("\n"))
+	cols := make([]int, len(lines))
+	min := -1
+	for i, line := range lines {
+		col, j := 0, 0
+		for ; j < len(line) && (line[j] == ' ' || line[j] == '\t'); j++ {
+			if line[j] == '\t' {
+				col += tabWidth - col%tabWidth
+			} else {
+				col++
+			}
+		}
+		lines[i], cols[i] = line[j:], col
+		if len(bytes.TrimSpace(line)) > 0 && (min < 0 || col < min) {
+			min = col
+		}
+	}
+	var out []byte
+	for i, line := range lines {
+		if i > 0 {
+			out = append(out, '\n')
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			out = append(out, bytes.Repeat([]byte{' '}, cols[i]-min)...)
+		}
+		out = append(out, line...)
+	}
+	return out
+}
+
 func deindent(block []byte) []byte {
 	const maxVal = 99
 	min := maxVal
@@ -1,6 +1,11 @@
 package printer
 
-import "testing"
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
 
 func TestToWhitespace(t *testing.T) {
 	testCases := []struct {
@@ -37,3 +42,94 @@ func TestDeindent(t *testing.T) {
 		}
 	}
 }
+
+func TestTrimIndentTabWidth(t *testing.T) {
+	tabs := "func f() {\n\tif ok {\n\t\treturn\n\t}\n}\n"
+	spaces := "func g() {\n    if ok {\n        return\n    }\n}\n"
+	mixed := "func h() {\n  \tif ok {\n\t    return\n    }\n}\n"
+	// The common indentation of the if statement is removed.
+	want := "if ok {\n    return\n}"
+	for _, src := range []string{tabs, spaces, mixed} {
+		pos := strings.Index(src, "if")
+		end := strings.LastIndex(src, "}\n}") + 1
+		got := fragmentSource([]byte(src), Fragment{Pos: pos, End: end}, 4)
+		if string(got) != want {
+			t.Errorf("source %q: got %q, want %q", src, got, want)
+		}
+	}
+	if got := trimIndent([]byte("\tx\n\t\ty"), 0); string(got) != "x\n\ty" {
+		t.Errorf("without a tab width: got %q, want the tabs kept", got)
+	}
+}
+
+func TestHTMLSelfContained(t *testing.T) {
+	src := "package p\n\nimport \"net/http\"\n\nfunc f() { http.Get(\"https://example.com/\") }\n"
+	fread := func(string) ([]byte, error) { return []byte(src), nil }
+	pos := strings.Index(src, "func")
+	g := Group{
+		Tokens:      5,
+		Explanation: "These fragments link to https://example.com/.",
+		Frags: []Fragment{
+			{Filename: `<img src="http://example.com/a.png">.go`, Pos: pos, End: len(src) - 1, Context: "href=https://example.com/"},
+			{Filename: "b.go", Pos: pos, End: len(src) - 1},
+		},
+	}
+
+	var buf bytes.Buffer
+	p := NewHTML(&buf, fread, Options{ShowPackage: true, Source: SourceInclude})
+	if err := p.PrintHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.PrintClones(g); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.PrintFooter(Totals{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Text looking like a link, e.g. in the code, is escaped and so
+	// inert; no element may load or link to another resource.
+	external := regexp.MustCompile(`(?i)<[^>]*\s(src|href)\s*=|<link|<script|@import|url\(`)
+	if loc := external.FindIndex(buf.Bytes()); loc != nil {
+		t.Errorf("external reference %q in the report:\n%s", buf.Bytes()[loc[0]:loc[1]], buf.Bytes())
+	}
+	for _, scheme := range []string{`"http://`, `"https://`} {
+		if bytes.Contains(buf.Bytes(), []byte(scheme)) {
+			t.Errorf("quoted %s URL in the report:\n%s", scheme, buf.Bytes())
+		}
+	}
+}
+
+func TestHTMLUniqueIDs(t *testing.T) {
+	src := "package p\n\nfunc f() {}\n"
+	fread := func(string) ([]byte, error) { return []byte(src), nil }
+	g := Group{Hash: "\x01\x02\x03\x04\x05\x06\x07\x08", Tokens: 5, Frags: []Fragment{
+		{Filename: "a.go", Pos: 11, End: len(src) - 1},
+		{Filename: "b.go", Pos: 11, End: len(src) - 1},
+	}}
+	other := g
+	other.Hash = "\x01\x02\x03\x04\x05\x06\x07\x09"
+
+	var buf bytes.Buffer
+	p := NewHTML(&buf, fread, Options{})
+	for _, g := range []Group{g, other, g} {
+		if err := p.PrintClones(g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	report := buf.Bytes()
+	var more bytes.Buffer
+	p = ContinueHTML(&more, fread, Options{}, report)
+	if err := p.PrintClones(g); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for _, m := range regexp.MustCompile(`id="([^"]*)"`).FindAllStringSubmatch(string(report)+more.String(), -1) {
+		ids = append(ids, m[1])
+	}
+	want := []string{"0102030405060708", "0102030405060709", "0102030405060708-2", "0102030405060708-3"}
+	if strings.Join(ids, " ") != strings.Join(want, " ") {
+		t.Errorf("got the ids %v, want %v", ids, want)
+	}
+}
@@ -0,0 +1,186 @@
+package printer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// SchemaVersion is the version of the JSON output format. It is
+// increased whenever the format changes incompatibly.
+const SchemaVersion = 1
+
+type jsonprinter struct {
+	w    io.Writer
+	opts Options
+	cnt  int
+	src  bool
+	ReadFile
+}
+
+// NewJSON returns a printer that writes a single JSON document
+// with all the clone groups. The groups are written as they come.
+func NewJSON(w io.Writer, fread ReadFile, opts Options) Printer {
+	return &jsonprinter{w: w, opts: opts, src: opts.Source.include(false), ReadFile: fread}
+}
+
+type jsonTool struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type jsonGroup struct {
+	GroupID   string         `json:"groupId"`
+	RuleID    string         `json:"ruleId"`
+	Message   string         `json:"message"`
+	Tokens    int            `json:"tokens"`
+	Exact     bool           `json:"exact"`
+	Gaps      int            `json:"gaps,omitempty"`
+	Moved     bool           `json:"moved,omitempty"`
+	Fragments []jsonFragment `json:"fragments"`
+}
+
+type jsonFragment struct {
+	ID         string     `json:"fragmentId"`
+	File       string     `json:"file"`
+	LineStart  int        `json:"lineStart"`
+	LineEnd    int        `json:"lineEnd"`
+	Func       string     `json:"func"`
+	Scope      string     `json:"scope"`
+	Author     string     `json:"author,omitempty"`
+	Source     string     `json:"source,omitempty"`
+	Similarity *float64   `json:"similarity,omitempty"`
+	Units      []jsonUnit `json:"units,omitempty"`
+
+	// Location is the fragment in the shape of the LSP Location.
+	Location lspLocation `json:"location"`
+}
+
+// jsonUnit is a syntax unit of a fragment, e.g. a statement, by its
+// lines relative to the first line of the fragment, which is line 1.
+type jsonUnit struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+type jsonTotals struct {
+	Files       int     `json:"files"`
+	Lines       int     `json:"lines"`
+	Tokens      int     `json:"tokens"`
+	Seconds     float64 `json:"seconds"`
+	SampledFrom int     `json:"sampledFrom,omitempty"`
+}
+
+// marshal returns the JSON encoding of v, indented as nested
+// in the document at the depth of prefix if the output is pretty.
+func (p *jsonprinter) marshal(v interface{}, prefix string) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	if p.opts.PrettyJSON {
+		enc.SetIndent(prefix, "  ")
+	}
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func (p *jsonprinter) PrintHeader() error {
+	tool, err := p.marshal(jsonTool{Name: "dupl", Version: p.opts.Version}, "  ")
+	if err != nil {
+		return err
+	}
+	format := `{"schemaVersion":%d,"tool":%s,"groups":[`
+	if p.opts.PrettyJSON {
+		format = "{\n  \"schemaVersion\": %d,\n  \"tool\": %s,\n  \"groups\": ["
+	}
+	_, err = fmt.Fprintf(p.w, format, SchemaVersion, tool)
+	return err
+}
+
+func (p *jsonprinter) PrintClones(g Group) error {
+	clones, err := prepareClonesInfo(p.ReadFile, g.Frags, p.src, p.opts.TabWidth)
+	if err != nil {
+		return err
+	}
+	if p.opts.FragmentDetail {
+		if err := p.addUnits(g.Frags, clones); err != nil {
+			return err
+		}
+	}
+	sort.Sort(byNameAndLine(clones))
+	msg, err := message(p.opts, g.Tokens, clones)
+	if err != nil {
+		return err
+	}
+	jg := jsonGroup{
+		GroupID:   g.ID(),
+		RuleID:    ruleID(p.opts),
+		Message:   msg,
+		Tokens:    g.Tokens,
+		Exact:     g.Exact,
+		Gaps:      g.Gaps,
+		Moved:     g.Moved,
+		Fragments: make([]jsonFragment, len(clones)),
+	}
+	for i, cl := range clones {
+		jg.Fragments[i] = jsonFragment{ID: cl.id, File: cl.filename, LineStart: cl.lineStart, LineEnd: cl.lineEnd, Func: cl.fn, Scope: cl.scope, Author: cl.author, Source: string(cl.fragment), Location: cl.loc, Units: cl.units}
+		if p.opts.Similarity {
+			sim := cl.similarity
+			jg.Fragments[i].Similarity = &sim
+		}
+	}
+	b, err := p.marshal(jg, "    ")
+	if err != nil {
+		return err
+	}
+	if p.cnt > 0 {
+		fmt.Fprint(p.w, ",")
+	}
+	p.cnt++
+	indent := ""
+	if p.opts.PrettyJSON {
+		indent = "    "
+	}
+	_, err = fmt.Fprintf(p.w, "\n%s%s", indent, b)
+	return err
+}
+
+// addUnits adds the syntax units of each fragment to its clone,
+// in the order they are matched in.
+func (p *jsonprinter) addUnits(frags []Fragment, clones []clone) error {
+	for i, frag := range frags {
+		file, err := p.ReadFile(frag.Filename)
+		if err != nil {
+			return err
+		}
+		line := func(offset int) int {
+			return bytes.Count(file[frag.Pos:offset], []byte{'\n'}) + 1
+		}
+		for _, n := range frag.Nodes {
+			clones[i].units = append(clones[i].units, jsonUnit{Start: line(n.Pos), End: line(n.End - 1)})
+		}
+	}
+	return nil
+}
+
+func (p *jsonprinter) PrintFooter(t Totals) error {
+	b, err := p.marshal(jsonTotals{
+		Files:       t.Files,
+		Lines:       t.Lines,
+		Tokens:      t.Tokens,
+		Seconds:     t.Duration.Seconds(),
+		SampledFrom: t.SampledFrom,
+	}, "  ")
+	if err != nil {
+		return err
+	}
+	format := "\n],\"totals\":%s}\n"
+	if p.opts.PrettyJSON {
+		format = "\n  ],\n  \"totals\": %s\n}\n"
+	}
+	_, err = fmt.Fprintf(p.w, format, b)
+	return err
+}
@@ -0,0 +1,149 @@
+package printer
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+func TestJSONDocument(t *testing.T) {
+	src := "package p\n\nfunc f() {}\n\nfunc g() {}\n"
+	fread := func(string) ([]byte, error) { return []byte(src), nil }
+
+	var buf bytes.Buffer
+	p := NewJSON(&buf, fread, Options{Version: "v1.2.3"})
+	groups := []Group{
+		{Tokens: 5, Frags: []Fragment{{Filename: "a.go", Pos: 11, End: 22}, {Filename: "b.go", Pos: 24, End: 35}}},
+		{Tokens: 3, Frags: []Fragment{{Filename: "a.go", Pos: 0, End: 9}, {Filename: "b.go", Pos: 0, End: 9}}},
+	}
+	if err := p.PrintHeader(); err != nil {
+		t.Fatal(err)
+	}
+	for _, g := range groups {
+		if err := p.PrintClones(g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.PrintFooter(Totals{Files: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		SchemaVersion int
+		Tool          jsonTool
+		Groups        []jsonGroup
+		Totals        jsonTotals
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.Bytes())
+	}
+	if doc.SchemaVersion != SchemaVersion {
+		t.Errorf("got schema version %d, want %d", doc.SchemaVersion, SchemaVersion)
+	}
+	if doc.Tool.Name != "dupl" || doc.Tool.Version != "v1.2.3" {
+		t.Errorf("got tool %+v", doc.Tool)
+	}
+	if len(doc.Groups) != 2 || doc.Groups[0].Fragments[1].LineStart != 5 {
+		t.Errorf("got groups %+v", doc.Groups)
+	}
+	if g := doc.Groups[0]; g.RuleID != DefaultRuleID || g.Message != "2 clones of 5 tokens in a.go, b.go" {
+		t.Errorf("got rule %q and message %q", g.RuleID, g.Message)
+	}
+	if doc.Totals.Files != 2 {
+		t.Errorf("got %d files, want 2", doc.Totals.Files)
+	}
+}
+
+func TestJSONSource(t *testing.T) {
+	src := "package p\n\nfunc f() {}\n"
+	fread := func(string) ([]byte, error) { return []byte(src), nil }
+	g := Group{Tokens: 5, Frags: []Fragment{{Filename: "a.go", Pos: 11, End: 22}, {Filename: "b.go", Pos: 11, End: 22}}}
+
+	for _, tt := range []struct {
+		source Source
+		want   string
+	}{
+		{SourceDefault, ""},
+		{SourceOmit, ""},
+		{SourceInclude, "func f() {}"},
+	} {
+		var buf bytes.Buffer
+		p := NewJSON(&buf, fread, Options{Source: tt.source})
+		if err := p.PrintClones(g); err != nil {
+			t.Fatal(err)
+		}
+		var jg jsonGroup
+		if err := json.Unmarshal(buf.Bytes(), &jg); err != nil {
+			t.Fatalf("invalid JSON: %v\n%s", err, buf.Bytes())
+		}
+		if got := jg.Fragments[0].Source; got != tt.want {
+			t.Errorf("source mode %d: got source %q, want %q", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestJSONPretty(t *testing.T) {
+	src := "package p\n\nfunc f() {}\n\nfunc g() {}\n"
+	fread := func(string) ([]byte, error) { return []byte(src), nil }
+	groups := []Group{
+		{Tokens: 5, Frags: []Fragment{{Filename: "a.go", Pos: 11, End: 22}, {Filename: "b.go", Pos: 24, End: 35}}},
+		{Tokens: 3, Frags: []Fragment{{Filename: "a.go", Pos: 0, End: 9}, {Filename: "b.go", Pos: 0, End: 9}}},
+	}
+	print := func(opts Options) []byte {
+		var buf bytes.Buffer
+		p := NewJSON(&buf, fread, opts)
+		if err := p.PrintHeader(); err != nil {
+			t.Fatal(err)
+		}
+		for _, g := range groups {
+			if err := p.PrintClones(g); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := p.PrintFooter(Totals{Files: 2}); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	compact, pretty := print(Options{}), print(Options{PrettyJSON: true})
+	var want bytes.Buffer
+	if err := json.Indent(&want, compact, "", "  "); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, compact)
+	}
+	if got := string(pretty); got != want.String() {
+		t.Errorf("got\n%s\nwant\n%s", got, want.String())
+	}
+}
+
+func TestJSONFragmentDetail(t *testing.T) {
+	src := "package p\n\nfunc f() {\n\tx := 1\n\n\tif x > 0 {\n\t\tx++\n\t}\n}\n"
+	fread := func(string) ([]byte, error) { return []byte(src), nil }
+	stmt1 := bytes.Index([]byte(src), []byte("x := 1"))
+	stmt2 := bytes.Index([]byte(src), []byte("if"))
+	end := bytes.LastIndex([]byte(src), []byte("}\n}")) + 1
+	frag := Fragment{Filename: "a.go", Pos: stmt1, End: end, Nodes: []*syntax.Node{
+		{Pos: stmt1, End: stmt1 + len("x := 1")},
+		{Pos: stmt2, End: end},
+	}}
+
+	var buf bytes.Buffer
+	p := NewJSON(&buf, fread, Options{FragmentDetail: true})
+	if err := p.PrintClones(Group{Tokens: 5, Frags: []Fragment{frag, frag}}); err != nil {
+		t.Fatal(err)
+	}
+	var g struct {
+		Fragments []struct {
+			Units []struct{ Start, End int }
+		}
+	}
+	if err := json.Unmarshal(bytes.TrimPrefix(buf.Bytes(), []byte("\n")), &g); err != nil {
+		t.Fatalf("%v:\n%s", err, buf.Bytes())
+	}
+	want := []struct{ Start, End int }{{1, 1}, {3, 5}}
+	if got := g.Fragments[0].Units; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("got units %+v, want %+v", got, want)
+	}
+}
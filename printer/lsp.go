@@ -0,0 +1,74 @@
+package printer
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// lspLocation is a location in the shape of the Location of the
+// Language Server Protocol.
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+// lspPosition is a 0-based position. Character counts the UTF-16 code
+// units from the start of the line, as in the protocol by default.
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// newLSPLocation returns the location of the fragment of the file
+// between the byte offsets from and to, exclusive.
+func newLSPLocation(filename string, file []byte, from, to int) lspLocation {
+	return lspLocation{
+		URI:   fileURI(filename),
+		Range: lspRange{Start: lspPositionOf(file, from), End: lspPositionOf(file, to)},
+	}
+}
+
+// lspPositionOf returns the position of the byte offset in the file.
+func lspPositionOf(file []byte, offset int) lspPosition {
+	var p lspPosition
+	start := 0
+	for i, b := range file[:offset] {
+		if b == '\n' {
+			p.Line++
+			start = i + 1
+		}
+	}
+	for line := file[start:offset]; len(line) > 0; {
+		r, size := utf8.DecodeRune(line)
+		if r >= 0x10000 {
+			// encoded as a surrogate pair
+			p.Character += 2
+		} else {
+			p.Character++
+		}
+		line = line[size:]
+	}
+	return p
+}
+
+// fileURI returns the file URI of the file, resolved relative to
+// the current directory.
+func fileURI(filename string) string {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		abs = filename
+	}
+	path := filepath.ToSlash(abs)
+	if !strings.HasPrefix(path, "/") {
+		// A Windows path like C:/dir.
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
@@ -0,0 +1,22 @@
+package printer
+
+import "testing"
+
+func TestLSPPosition(t *testing.T) {
+	file := []byte("package p\n\nvar s = \"ä😀\" + x\n")
+	for _, tt := range []struct {
+		offset int
+		want   lspPosition
+	}{
+		{0, lspPosition{0, 0}},
+		{9, lspPosition{0, 9}},
+		{11, lspPosition{2, 0}},
+		// ä of 2 bytes and 😀 of 4 bytes are 1 and 2 UTF-16
+		// code units.
+		{11 + len("var s = \"ä😀\" + "), lspPosition{2, 16}},
+	} {
+		if got := lspPositionOf(file, tt.offset); got != tt.want {
+			t.Errorf("offset %d: got %+v, want %+v", tt.offset, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package printer
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// DefaultRuleID identifies the clone groups in the machine readable
+// formats unless configured otherwise.
+const DefaultRuleID = "dupl"
+
+// DefaultMessage is the template of the message describing a clone group.
+const DefaultMessage = `{{.Copies}} clones of {{.Tokens}} tokens in {{join .Files ", "}}`
+
+// MessageData holds the fields available to a message template.
+type MessageData struct {
+	Tokens int      // size of each fragment in tokens
+	Copies int      // number of fragments
+	Files  []string // distinct files of the fragments, sorted
+	Clones []MessageClone
+}
+
+// MessageClone is a single fragment of a clone group.
+type MessageClone struct {
+	File               string
+	LineStart, LineEnd int
+}
+
+// ParseMessage parses the template of the message describing a clone
+// group. Besides the fields of MessageData, the template may use the
+// join function, which is strings.Join.
+func ParseMessage(text string) (*template.Template, error) {
+	return template.New("message").Funcs(template.FuncMap{"join": strings.Join}).Parse(text)
+}
+
+var defaultMessage = template.Must(ParseMessage(DefaultMessage))
+
+// message executes the message template of the options for the group
+// of the clones.
+func message(opts Options, tokens int, clones []clone) (string, error) {
+	tmpl := opts.Message
+	if tmpl == nil {
+		tmpl = defaultMessage
+	}
+	data := MessageData{Tokens: tokens, Copies: len(clones)}
+	seen := make(map[string]bool)
+	for _, cl := range clones {
+		data.Clones = append(data.Clones, MessageClone{File: cl.filename, LineStart: cl.lineStart, LineEnd: cl.lineEnd})
+		if !seen[cl.filename] {
+			seen[cl.filename] = true
+			data.Files = append(data.Files, cl.filename)
+		}
+	}
+	sort.Strings(data.Files)
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// ruleID returns the rule identifier of the options.
+func ruleID(opts Options) string {
+	if opts.RuleID == "" {
+		return DefaultRuleID
+	}
+	return opts.RuleID
+}
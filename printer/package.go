@@ -0,0 +1,63 @@
+package printer
+
+import (
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// packageInfo returns the package clause of the file followed by the
+// imports referenced in its fragment between the byte offsets from and to.
+func packageInfo(file []byte, from, to int) string {
+	f, err := parser.ParseFile(token.NewFileSet(), "", file, parser.ImportsOnly)
+	if err != nil {
+		return ""
+	}
+	used := qualifiers(file[from:to])
+	var imports []string
+	for _, imp := range f.Imports {
+		p, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := path.Base(p)
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		if used[name] {
+			imports = append(imports, imp.Path.Value)
+		}
+	}
+	info := "package " + f.Name.Name
+	if len(imports) > 0 {
+		info += "; import " + strings.Join(imports, ", ")
+	}
+	return info
+}
+
+// qualifiers returns all identifiers followed by a period in src,
+// which include the names of the referenced packages.
+func qualifiers(src []byte) map[string]bool {
+	fset := token.NewFileSet()
+	var s scanner.Scanner
+	s.Init(fset.AddFile("", -1, len(src)), src, nil, 0)
+	idents := make(map[string]bool)
+	prev := ""
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.PERIOD && prev != "" {
+			idents[prev] = true
+		}
+		prev = ""
+		if tok == token.IDENT {
+			prev = lit
+		}
+	}
+	return idents
+}
@@ -4,33 +4,52 @@ import (
 	"fmt"
 	"io"
 	"sort"
-
-	"github.com/mibk/dupl/syntax"
 )
 
 type plumbing struct {
-	w io.Writer
+	w     io.Writer
+	funcs bool
+	ids   bool
 	ReadFile
 }
 
-func NewPlumbing(w io.Writer, fread ReadFile) Printer {
-	return &plumbing{w, fread}
+func NewPlumbing(w io.Writer, fread ReadFile, opts Options) Printer {
+	return &plumbing{w, opts.FuncSignatures, opts.FragmentIDs, fread}
 }
 
 func (p *plumbing) PrintHeader() error { return nil }
 
-func (p *plumbing) PrintClones(dups [][]*syntax.Node) error {
-	clones, err := prepareClonesInfo(p.ReadFile, dups)
+func (p *plumbing) PrintClones(g Group) error {
+	clones, err := prepareClonesInfo(p.ReadFile, g.Frags, false, 0)
 	if err != nil {
 		return err
 	}
 	sort.Sort(byNameAndLine(clones))
 	for i, cl := range clones {
 		nextCl := clones[(i+1)%len(clones)]
-		fmt.Fprintf(p.w, "%s:%d-%d: duplicate of %s:%d-%d\n", cl.filename, cl.lineStart, cl.lineEnd,
+		fmt.Fprintf(p.w, "%s:%d-%d: duplicate of %s:%d-%d", cl.filename, cl.lineStart, cl.lineEnd,
 			nextCl.filename, nextCl.lineStart, nextCl.lineEnd)
+		if p.funcs {
+			fn := cl.fn
+			if fn == "" {
+				fn = "-"
+			}
+			fmt.Fprintf(p.w, "\t%s", fn)
+		}
+		if p.ids {
+			fmt.Fprintf(p.w, "\t%s", cl.id)
+		}
+		fmt.Fprintln(p.w)
 	}
 	return nil
 }
 
-func (p *plumbing) PrintFooter() error { return nil }
+func (p *plumbing) PrintFooter(t Totals) error {
+	fmt.Fprintf(p.w, "# files=%d lines=%d tokens=%d duration=%.3fs",
+		t.Files, t.Lines, t.Tokens, t.Duration.Seconds())
+	if t.SampledFrom > 0 {
+		fmt.Fprintf(p.w, " sampled-from=%d", t.SampledFrom)
+	}
+	_, err := fmt.Fprintln(p.w)
+	return err
+}
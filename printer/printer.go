@@ -1,11 +1,214 @@
 package printer
 
-import "github.com/mibk/dupl/syntax"
+import (
+	"encoding/hex"
+	"text/template"
+	"time"
+
+	"github.com/mibk/dupl/syntax"
+)
 
 type ReadFile func(filename string) ([]byte, error)
 
+// Options configure a printer. Every printer ignores the options
+// that do not apply to its format.
+type Options struct {
+	// Color controls colorizing of the text output.
+	Color Color
+
+	// ShowPackage shows the package and the imports referenced
+	// by each fragment in the text and HTML output.
+	ShowPackage bool
+
+	// ShowScope shows the scope path of each fragment, which is
+	// always in the JSON output, in the text and HTML output.
+	ShowScope bool
+
+	// FuncSignatures adds the signatures of the enclosing functions
+	// of the fragments to the plumbing output.
+	FuncSignatures bool
+
+	// FragmentIDs adds the identifiers of the fragments, which are
+	// always in the JSON output, to the plumbing output.
+	FragmentIDs bool
+
+	// FragmentDetail adds the lines of the syntax units of each
+	// fragment to the JSON output.
+	FragmentDetail bool
+
+	// PrettyJSON indents the JSON output by two spaces.
+	PrettyJSON bool
+
+	// Similarity shows the similarity of each fragment to the
+	// representative fragment of its group in the text and JSON output.
+	Similarity bool
+
+	// Message is the template of the message describing each clone
+	// group in the machine readable formats. If nil, DefaultMessage
+	// is used.
+	Message *template.Template
+
+	// RuleID identifies the clone groups in the machine readable
+	// formats. If empty, DefaultRuleID is used.
+	RuleID string
+
+	// Version is the version of dupl reported by the machine
+	// readable formats.
+	Version string
+
+	// Source controls whether the text, HTML, JSON, and template
+	// output include the duplicated code.
+	Source Source
+
+	// TabWidth, if positive, is the number of columns of the tab stops
+	// the indentation of the included code is expanded to spaces by,
+	// so that the lines indented by tabs and by spaces line up.
+	// Otherwise the indentation is kept as it is.
+	TabWidth int
+
+	// Template is the template of the whole report written by the
+	// printer returned by NewTemplate.
+	Template *template.Template
+}
+
+// Source is a mode of including the duplicated code in the output.
+type Source int
+
+const (
+	// SourceDefault includes the code only in the HTML output.
+	SourceDefault Source = iota
+	SourceInclude
+	SourceOmit
+)
+
+// include reports whether the code is included in the output of
+// a format doing so by default if def is set.
+func (s Source) include(def bool) bool {
+	return s == SourceInclude || s == SourceDefault && def
+}
+
+// Color is a mode of colorizing the output.
+type Color int
+
+const (
+	// ColorAuto colorizes the output only if it is a terminal.
+	ColorAuto Color = iota
+	ColorAlways
+	ColorNever
+)
+
+// Totals describes the whole scanned corpus.
+type Totals struct {
+	Files    int
+	Lines    int
+	Tokens   int
+	Duration time.Duration
+
+	// SampledFrom is the number of files the scanned ones were
+	// randomly chosen from, or 0 if all of them were scanned.
+	SampledFrom int
+}
+
+// Group is a group of clones of the same structure.
+type Group struct {
+	Hash   string
+	Tokens int // size of each fragment in tokens
+	Frags  []Fragment
+
+	// Exact reports whether the fragments are the same source text,
+	// differing at most in whitespace, unlike clones that merely
+	// consist of the same tokens.
+	Exact bool
+
+	// Explanation optionally describes in prose why the fragments
+	// are clones, for the text and HTML output.
+	Explanation string
+
+	// Gaps is the number of stretches of differing lines the fragments
+	// were joined across, making them near-miss clones. Tokens then
+	// counts only the matching tokens, not those of the gaps.
+	Gaps int
+
+	// Shape is optionally the source of the fragments, from the start
+	// of the first line, with the parts they differ in replaced
+	// by $1, $2, and so on, which the Holes of each fragment fill.
+	Shape []byte
+
+	// Moved reports whether the group is of a block moved to another
+	// file rather than copied: a single fragment is in the current
+	// source, and the others are the old locations of the block.
+	Moved bool
+}
+
+// ID returns the identifier of the group, which is derived from the
+// structure of the fragments. It stays the same across runs as long as
+// the fragments remain of the same structure, even if they are moved,
+// identifiers and literals are changed, or fragments are added or removed.
+func (g Group) ID() string {
+	if len(g.Hash) < 8 {
+		return hex.EncodeToString([]byte(g.Hash))
+	}
+	return hex.EncodeToString([]byte(g.Hash[:8]))
+}
+
+// Fragment is a single clone of a group.
+type Fragment struct {
+	Filename string
+	Pos, End int // byte offsets of the fragment in the file
+	Nodes    []*syntax.Node
+
+	// Context optionally describes where the fragment is found,
+	// e.g. the case clause whose body it is.
+	Context string
+
+	// Holes are the texts the fragment fills the placeholders $1,
+	// $2, and so on of the Shape of its group with.
+	Holes []string
+
+	// Func is the signature of the function the fragment lies in,
+	// if it is known and the fragment lies in a single function.
+	Func string
+
+	// Scope is the dotted path of the innermost function enclosing
+	// the fragment, e.g. pkg.(*Server).handle, or its package.
+	Scope string
+
+	// Author is optionally the author of most of the lines of the
+	// fragment, like "Name <email>".
+	Author string
+
+	// Similarity is the percentage of the tokens of the fragment
+	// that are the same as in the representative fragment of the group.
+	Similarity float64
+}
+
+// NewGroup creates a group from the match, which must contain
+// only non-empty fragments.
+func NewGroup(m syntax.Match) Group {
+	g := Group{Hash: m.Hash, Tokens: m.Tokens, Frags: make([]Fragment, len(m.Frags))}
+	for i, seq := range m.Frags {
+		if len(seq) == 0 {
+			panic("zero length dup")
+		}
+		first, last := seq[0], seq[len(seq)-1]
+		frag := Fragment{Filename: first.Filename, Pos: first.Pos, End: last.End, Nodes: seq}
+		// The nodes may be out of source order if the statements
+		// were reordered.
+		for _, n := range seq {
+			if n.Pos < frag.Pos {
+				frag.Pos = n.Pos
+			}
+			if n.End > frag.End {
+				frag.End = n.End
+			}
+		}
+		g.Frags[i] = frag
+	}
+	return g
+}
+
 type Printer interface {
 	PrintHeader() error
-	PrintClones(dups [][]*syntax.Node) error
-	PrintFooter() error
+	PrintClones(g Group) error
+	PrintFooter(t Totals) error
 }
@@ -0,0 +1,118 @@
+package printer
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ReportData is the data a report template is executed with.
+type ReportData struct {
+	Groups []ReportGroup
+	Totals ReportTotals
+}
+
+// ReportGroup is a clone group in a report template.
+type ReportGroup struct {
+	ID        string
+	Tokens    int // size of each fragment in tokens
+	Exact     bool
+	Fragments []ReportFragment // sorted by file and line
+}
+
+// ReportFragment is a single clone in a report template.
+type ReportFragment struct {
+	ID                 string // identifier of the fragment
+	File               string
+	LineStart, LineEnd int
+	Func               string // signature of the enclosing function, if any
+	Scope              string // scope path of the enclosing function
+	Context            string
+	Source             string // deindented code, unless omitted
+}
+
+// ReportTotals describes the whole scanned corpus in a report template.
+type ReportTotals struct {
+	Files, Lines, Tokens int
+	Duration             time.Duration
+	SampledFrom          int // number of files sampled from, or 0
+}
+
+// ParseReport parses the template of a whole report. Besides the join
+// function, which is strings.Join, the template may use the functions
+// byTokens and byFile returning the given groups sorted by the number
+// of tokens, the largest first, or by the file and line of their first
+// fragment.
+func ParseReport(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(template.FuncMap{
+		"join":     strings.Join,
+		"byTokens": byTokens,
+		"byFile":   byFile,
+	}).Parse(text)
+}
+
+func byTokens(groups []ReportGroup) []ReportGroup {
+	sorted := append([]ReportGroup(nil), groups...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Tokens > sorted[j].Tokens })
+	return sorted
+}
+
+func byFile(groups []ReportGroup) []ReportGroup {
+	sorted := append([]ReportGroup(nil), groups...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i].Fragments[0], sorted[j].Fragments[0]
+		if a.File == b.File {
+			return a.LineStart < b.LineStart
+		}
+		return a.File < b.File
+	})
+	return sorted
+}
+
+type templateprinter struct {
+	w    io.Writer
+	tmpl *template.Template
+	src  bool
+	tab  int // width of the tab stops
+	data ReportData
+	ReadFile
+}
+
+// NewTemplate returns a printer that executes the template
+// of the options with all the clone groups when they are known,
+// in PrintFooter.
+func NewTemplate(w io.Writer, fread ReadFile, opts Options) Printer {
+	return &templateprinter{w: w, tmpl: opts.Template, src: opts.Source.include(true), tab: opts.TabWidth, ReadFile: fread}
+}
+
+func (p *templateprinter) PrintHeader() error { return nil }
+
+func (p *templateprinter) PrintClones(g Group) error {
+	clones, err := prepareClonesInfo(p.ReadFile, g.Frags, p.src, p.tab)
+	if err != nil {
+		return err
+	}
+	sort.Sort(byNameAndLine(clones))
+	rg := ReportGroup{ID: g.ID(), Tokens: g.Tokens, Exact: g.Exact, Fragments: make([]ReportFragment, len(clones))}
+	for i, cl := range clones {
+		rg.Fragments[i] = ReportFragment{
+			ID:        cl.id,
+			File:      cl.filename,
+			LineStart: cl.lineStart,
+			LineEnd:   cl.lineEnd,
+			Func:      cl.fn,
+			Scope:     cl.scope,
+			Context:   cl.context,
+			Source:    string(cl.fragment),
+		}
+	}
+	p.data.Groups = append(p.data.Groups, rg)
+	return nil
+}
+
+func (p *templateprinter) PrintFooter(t Totals) error {
+	p.data.Totals = ReportTotals{Files: t.Files, Lines: t.Lines, Tokens: t.Tokens, Duration: t.Duration, SampledFrom: t.SampledFrom}
+	return p.tmpl.Execute(p.w, p.data)
+}
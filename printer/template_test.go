@@ -0,0 +1,41 @@
+package printer
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTemplateReport(t *testing.T) {
+	src := "package p\n\nfunc f() {}\n\nfunc g() {}\n"
+	fread := func(string) ([]byte, error) { return []byte(src), nil }
+	tmpl, err := ParseReport("report", `{{range byTokens .Groups}}{{.Tokens}}:{{range .Fragments}} {{.File}}:{{.LineStart}} {{.Source}};{{end}}
+{{end}}{{.Totals.Files}} files`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	p := NewTemplate(&buf, fread, Options{Template: tmpl})
+	groups := []Group{
+		{Tokens: 3, Frags: []Fragment{{Filename: "b.go", Pos: 11, End: 22}, {Filename: "a.go", Pos: 24, End: 35}}},
+		{Tokens: 5, Frags: []Fragment{{Filename: "a.go", Pos: 0, End: 9}, {Filename: "b.go", Pos: 0, End: 9}}},
+	}
+	if err := p.PrintHeader(); err != nil {
+		t.Fatal(err)
+	}
+	for _, g := range groups {
+		if err := p.PrintClones(g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if buf.Len() > 0 {
+		t.Errorf("got output before the footer: %q", buf.String())
+	}
+	if err := p.PrintFooter(Totals{Files: 2}); err != nil {
+		t.Fatal(err)
+	}
+	want := "5: a.go:1 package p; b.go:1 package p;\n3: a.go:5 func g() {}; b.go:3 func f() {};\n2 files"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
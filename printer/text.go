@@ -1,61 +1,160 @@
 package printer
 
 import (
+	"bytes"
 	"fmt"
+	"go/scanner"
+	"go/token"
 	"io"
+	"os"
 	"sort"
-
-	"github.com/mibk/dupl/syntax"
+	"strings"
 )
 
 type text struct {
-	cnt int
-	w   io.Writer
+	cnt   int
+	w     io.Writer
+	color bool
+	pkg   bool
+	scope bool
+	src   bool
+	tab   int // width of the tab stops
+	sim   bool
 	ReadFile
 }
 
-func NewText(w io.Writer, fread ReadFile) Printer {
-	return &text{w: w, ReadFile: fread}
+func NewText(w io.Writer, fread ReadFile, opts Options) Printer {
+	color := opts.Color == ColorAlways
+	if opts.Color == ColorAuto {
+		color = isTerminal(w) && os.Getenv("NO_COLOR") == ""
+	}
+	return &text{w: w, color: color, pkg: opts.ShowPackage, scope: opts.ShowScope, src: opts.Source.include(false), tab: opts.TabWidth, sim: opts.Similarity, ReadFile: fread}
+}
+
+// ANSI escape sequences used for colorizing.
+const (
+	bold    = "\x1b[1m"
+	magenta = "\x1b[35m"
+	green   = "\x1b[32m"
+	reset   = "\x1b[0m"
+)
+
+// paint wraps s in the escape sequence esc if colorizing is enabled.
+func (p *text) paint(esc, s string) string {
+	if !p.color {
+		return s
+	}
+	return esc + s + reset
+}
+
+// exactLabel returns the label of a group of exact, near-miss, or moved
+// clones.
+func exactLabel(g Group) string {
+	if g.Moved {
+		return "moved "
+	}
+	if g.Gaps > 0 {
+		return "near-miss "
+	}
+	if g.Exact {
+		return "exact "
+	}
+	return ""
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
 }
 
 func (p *text) PrintHeader() error { return nil }
 
-func (p *text) PrintClones(dups [][]*syntax.Node) error {
+func (p *text) PrintClones(g Group) error {
 	p.cnt++
-	fmt.Fprintf(p.w, "found %d clones:\n", len(dups))
-	clones, err := prepareClonesInfo(p.ReadFile, dups)
+	fmt.Fprintln(p.w, p.paint(bold, fmt.Sprintf("found %d %sclones:", len(g.Frags), exactLabel(g))))
+	if g.Explanation != "" {
+		fmt.Fprintf(p.w, "  %s\n", g.Explanation)
+	}
+	clones, err := prepareClonesInfo(p.ReadFile, g.Frags, p.src, p.tab)
 	if err != nil {
 		return err
 	}
+	if p.pkg {
+		if err := addPackageInfo(p.ReadFile, g.Frags, clones); err != nil {
+			return err
+		}
+	}
 	sort.Sort(byNameAndLine(clones))
 	for _, cl := range clones {
-		fmt.Fprintf(p.w, "  %s:%d,%d\n", cl.filename, cl.lineStart, cl.lineEnd)
+		fmt.Fprintf(p.w, "  %s:%s", p.paint(magenta, cl.filename),
+			p.paint(green, fmt.Sprintf("%d,%d", cl.lineStart, cl.lineEnd)))
+		if cl.context != "" {
+			fmt.Fprintf(p.w, " (%s)", cl.context)
+		}
+		if p.scope && cl.scope != "" {
+			fmt.Fprintf(p.w, " in %s", cl.scope)
+		}
+		if cl.author != "" {
+			fmt.Fprintf(p.w, " by %s", cl.author)
+		}
+		if p.sim {
+			fmt.Fprintf(p.w, " %.0f%% similar", cl.similarity)
+		}
+		fmt.Fprintln(p.w)
+		if cl.pkg != "" {
+			fmt.Fprintf(p.w, "    %s\n", cl.pkg)
+		}
+		if len(cl.holes) > 0 {
+			fills := make([]string, len(cl.holes))
+			for i, h := range cl.holes {
+				fills[i] = fmt.Sprintf("$%d: %s", i+1, h)
+			}
+			fmt.Fprintf(p.w, "    %s\n", strings.Join(fills, ", "))
+		}
+		if p.src {
+			for _, line := range bytes.Split(cl.fragment, []byte("\n")) {
+				fmt.Fprintf(p.w, "    | %s\n", line)
+			}
+		}
+	}
+	if g.Shape != nil {
+		fmt.Fprintln(p.w, "  shared shape:")
+		for _, line := range bytes.Split(trimIndent(g.Shape, p.tab), []byte("\n")) {
+			fmt.Fprintf(p.w, "    | %s\n", line)
+		}
 	}
 	return nil
 }
 
-func (p *text) PrintFooter() error {
+func (p *text) PrintFooter(t Totals) error {
+	if t.SampledFrom > 0 {
+		_, err := fmt.Fprintf(p.w, "\nFound total %d clone groups in a sample of %d of %d files.\n", p.cnt, t.Files, t.SampledFrom)
+		return err
+	}
 	_, err := fmt.Fprintf(p.w, "\nFound total %d clone groups.\n", p.cnt)
 	return err
 }
 
-func prepareClonesInfo(fread ReadFile, dups [][]*syntax.Node) ([]clone, error) {
-	clones := make([]clone, len(dups))
-	for i, dup := range dups {
-		cnt := len(dup)
-		if cnt == 0 {
-			panic("zero length dup")
-		}
-		nstart := dup[0]
-		nend := dup[cnt-1]
-
-		file, err := fread(nstart.Filename)
+// prepareClonesInfo returns the clones of the fragments, with their
+// source if src is set, indented by tab stops of tabWidth if positive.
+func prepareClonesInfo(fread ReadFile, frags []Fragment, src bool, tabWidth int) ([]clone, error) {
+	clones := make([]clone, len(frags))
+	for i, frag := range frags {
+		file, err := fread(frag.Filename)
 		if err != nil {
 			return nil, err
 		}
 
-		cl := clone{filename: nstart.Filename}
-		cl.lineStart, cl.lineEnd = blockLines(file, nstart.Pos, nend.End)
+		cl := clone{context: frag.Context, fn: frag.Func, scope: frag.Scope, author: frag.Author, similarity: frag.Similarity, holes: frag.Holes, id: fragmentID(file, frag)}
+		cl.filename, cl.lineStart, cl.lineEnd = blockPosition(frag.Filename, file, frag.Pos, frag.End)
+		cl.loc = newLSPLocation(frag.Filename, file, frag.Pos, frag.End)
+		if src {
+			cl.fragment = fragmentSource(file, frag, tabWidth)
+		}
 		clones[i] = cl
 	}
 	return clones, nil
@@ -79,11 +178,54 @@ func blockLines(file []byte, from, to int) (int, int) {
 	return lineStart, lineEnd
 }
 
+// addPackageInfo adds the package info of each fragment to its clone.
+func addPackageInfo(fread ReadFile, frags []Fragment, clones []clone) error {
+	for i, frag := range frags {
+		file, err := fread(frag.Filename)
+		if err != nil {
+			return err
+		}
+		clones[i].pkg = packageInfo(file, frag.Pos, frag.End)
+	}
+	return nil
+}
+
+// blockPosition returns the filename and lines of the block of the file
+// between the byte offsets from and to. Line directives are honored
+// the same way as by the Go compiler.
+func blockPosition(filename string, file []byte, from, to int) (string, int, int) {
+	if !bytes.Contains(file, []byte("//line ")) && !bytes.Contains(file, []byte("/*line ")) {
+		lineStart, lineEnd := blockLines(file, from, to)
+		return filename, lineStart, lineEnd
+	}
+	fset := token.NewFileSet()
+	f := fset.AddFile(filename, -1, len(file))
+	var s scanner.Scanner
+	s.Init(f, file, nil, 0)
+	for {
+		if _, tok, _ := s.Scan(); tok == token.EOF {
+			break
+		}
+	}
+	start, end := f.Position(f.Pos(from)), f.Position(f.Pos(to-1))
+	return start.Filename, start.Line, end.Line
+}
+
 type clone struct {
-	filename  string
-	lineStart int
-	lineEnd   int
-	fragment  []byte
+	filename   string
+	lineStart  int
+	lineEnd    int
+	fragment   []byte
+	context    string
+	fn         string // signature of the enclosing function
+	scope      string // scope path of the enclosing function
+	author     string
+	pkg        string // package clause and imports
+	loc        lspLocation
+	similarity float64
+	holes      []string
+	id         string // identifier of the fragment
+	units      []jsonUnit
 }
 
 type byNameAndLine []clone
@@ -0,0 +1,44 @@
+package printer
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLineDirective(t *testing.T) {
+	src := `package p
+
+//line parser.y:100
+func f() {
+	println()
+}
+
+func g() {}
+`
+	fread := func(string) ([]byte, error) { return []byte(src), nil }
+	frag := func(s string) Fragment {
+		pos := strings.Index(src, s)
+		return Fragment{Filename: filepath.Join("gen", "parser.go"), Pos: pos, End: pos + len(s)}
+	}
+	frags := []Fragment{
+		frag("func f() {\n\tprintln()\n}"),
+		frag("package p"),
+	}
+
+	clones, err := prepareClonesInfo(fread, frags, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []clone{
+		{filename: filepath.Join("gen", "parser.y"), lineStart: 100, lineEnd: 102},
+		{filename: filepath.Join("gen", "parser.go"), lineStart: 1, lineEnd: 1},
+	}
+	for i, want := range testCases {
+		got := clones[i]
+		if got.filename != want.filename || got.lineStart != want.lineStart || got.lineEnd != want.lineEnd {
+			t.Errorf("got %s:%d,%d, want %s:%d,%d", got.filename, got.lineStart, got.lineEnd,
+				want.filename, want.lineStart, want.lineEnd)
+		}
+	}
+}
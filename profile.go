@@ -0,0 +1,55 @@
+package main
+
+import (
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+)
+
+var stopOnce sync.Once
+
+// startProfiling starts CPU profiling if requested. The profiles are
+// written by stopProfiling.
+func startProfiling() {
+	if *cpuProfile == "" {
+		return
+	}
+	f, err := os.Create(*cpuProfile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// stopProfiling stops CPU profiling and writes the memory profile
+// if requested. Only the first call has any effect.
+func stopProfiling() {
+	stopOnce.Do(func() {
+		if *cpuProfile != "" {
+			pprof.StopCPUProfile()
+		}
+		if *memProfile == "" {
+			return
+		}
+		f, err := os.Create(*memProfile)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		defer f.Close()
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			log.Println(err)
+		}
+	})
+}
+
+// fatal is like log.Fatal, but it writes the profiles first.
+func fatal(v ...interface{}) {
+	stopProfiling()
+	log.Fatal(v...)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sampleSize is the flag of the size of a random sample of the files,
+// either a number of files or a percentage of them.
+type sampleSize struct {
+	n       int
+	percent float64
+}
+
+func (s *sampleSize) String() string {
+	if s.percent > 0 {
+		return strconv.FormatFloat(s.percent, 'f', -1, 64) + "%"
+	}
+	if s.n > 0 {
+		return strconv.Itoa(s.n)
+	}
+	return ""
+}
+
+func (s *sampleSize) Set(value string) error {
+	if strings.HasSuffix(value, "%") {
+		p, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil || p <= 0 || p > 100 {
+			return fmt.Errorf("sample must be a number of files or a percentage, got %q", value)
+		}
+		*s = sampleSize{percent: p}
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return fmt.Errorf("sample must be a number of files or a percentage, got %q", value)
+	}
+	*s = sampleSize{n: n}
+	return nil
+}
+
+func (s sampleSize) isSet() bool { return s.n > 0 || s.percent > 0 }
+
+// of returns the size of the sample of total files.
+func (s sampleSize) of(total int) int {
+	n := s.n
+	if s.percent > 0 {
+		n = int(float64(total)*s.percent/100 + 0.5)
+		if n == 0 && total > 0 {
+			n = 1
+		}
+	}
+	if n > total {
+		n = total
+	}
+	return n
+}
+
+// sampledFrom is the number of files the sample was chosen from,
+// or 0 if the files are not sampled.
+var sampledFrom int
+
+// sampleFiles returns a feed of a random sample of the files of feed,
+// chosen by the seed. If seed is 0, a random one is used. The seed
+// and the size of the sample are reported on stderr.
+func sampleFiles(feed chan string, size sampleSize, seed int64) chan string {
+	var all []string
+	for file := range feed {
+		all = append(all, file)
+	}
+	// The same seed chooses the same files however they are crawled.
+	sort.Strings(all)
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	n := size.of(len(all))
+	sample := make([]string, 0, n)
+	for _, i := range rand.New(rand.NewSource(seed)).Perm(len(all))[:n] {
+		sample = append(sample, all[i])
+	}
+	sort.Strings(sample)
+	sampledFrom = len(all)
+	fmt.Fprintf(os.Stderr, "dupl: sampled %d of %d files with -sample-seed %d; the results are approximate\n", n, len(all), seed)
+
+	fchan := make(chan string)
+	go func() {
+		for _, file := range sample {
+			fchan <- file
+		}
+		close(fchan)
+	}()
+	return fchan
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"strings"
+
+	"github.com/mibk/dupl/printer"
+	"github.com/mibk/dupl/syntax"
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+// enclosingFunc returns the declaration of the function the node
+// is found in, or nil for package-level nodes.
+func enclosingFunc(n *syntax.Node) *syntax.Node {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.Type == golang.FuncDecl {
+			return p
+		}
+	}
+	return nil
+}
+
+// funcName returns the name of the function declaration decl
+// found in the file source.
+func funcName(decl *syntax.Node, file []byte) string {
+	for _, c := range decl.Children {
+		if golang.IsIdent(c.Type) {
+			return string(file[c.Pos:c.End])
+		}
+	}
+	return ""
+}
+
+// funcSignature returns the signature of the function declaration decl
+// found in the file source, with the whitespace collapsed.
+func funcSignature(decl *syntax.Node, file []byte) string {
+	end := decl.End
+	if body := decl.Children[len(decl.Children)-1]; body.Type == golang.BlockStmt {
+		end = body.Pos
+	}
+	return string(bytes.Join(bytes.Fields(file[decl.Pos:end]), []byte(" ")))
+}
+
+// annotateFuncs sets the signature of the enclosing function of every
+// fragment of the group that lies inside a single function.
+func annotateFuncs(g *printer.Group) error {
+	for i := range g.Frags {
+		frag := &g.Frags[i]
+		decl := enclosingFunc(frag.Nodes[0])
+		if decl == nil {
+			continue
+		}
+		for _, n := range frag.Nodes[1:] {
+			if enclosingFunc(n) != decl {
+				decl = nil
+				break
+			}
+		}
+		if decl == nil {
+			continue
+		}
+		file, err := readFile(frag.Filename)
+		if err != nil {
+			return err
+		}
+		frag.Func = funcSignature(decl, file)
+	}
+	return nil
+}
+
+// splitByFunc splits the group into the groups of its fragments in the
+// same function, dropping the fragments alone in their functions and
+// those at the package level or spanning several functions. The context
+// of each fragment is set to the scope path of its function.
+func splitByFunc(g printer.Group) ([]printer.Group, error) {
+	var decls []*syntax.Node
+	frags := make(map[*syntax.Node][]printer.Fragment)
+	for _, frag := range g.Frags {
+		decl := enclosingFunc(frag.Nodes[0])
+		for _, n := range frag.Nodes[1:] {
+			if decl != nil && enclosingFunc(n) != decl {
+				decl = nil
+			}
+		}
+		if decl == nil {
+			continue
+		}
+		file, err := readFile(frag.Filename)
+		if err != nil {
+			return nil, err
+		}
+		frag.Context = scopePath(file, []*syntax.Node{decl})
+		if _, ok := frags[decl]; !ok {
+			decls = append(decls, decl)
+		}
+		frags[decl] = append(frags[decl], frag)
+	}
+	var gs []printer.Group
+	for _, decl := range decls {
+		sub := g
+		sub.Frags = frags[decl]
+		if len(sub.Frags) < 2 {
+			reject(&sub, "no other fragment in its function")
+			continue
+		}
+		gs = append(gs, sub)
+	}
+	if len(decls) == 0 {
+		reject(&g, "no fragment inside a function")
+	}
+	return gs, nil
+}
+
+// annotateScopes sets the scope path of every fragment of the group.
+func annotateScopes(g *printer.Group) error {
+	for i := range g.Frags {
+		frag := &g.Frags[i]
+		file, err := readFile(frag.Filename)
+		if err != nil {
+			return err
+		}
+		frag.Scope = scopePath(file, frag.Nodes)
+	}
+	return nil
+}
+
+// scopePath returns the dotted path of the innermost function enclosing
+// all the nodes, or being the only one of them, like the Go runtime
+// names the functions: pkg.(*Server).handle for a method, pkg.handle.func1
+// for the first function literal in it, and pkg.handle.func1.2 for the
+// second one in that. Function literals at the package level are named
+// pkg.func1 and so on, and the package-level nodes just pkg.
+func scopePath(file []byte, nodes []*syntax.Node) string {
+	var common []*syntax.Node
+	for i, n := range nodes {
+		var chain []*syntax.Node
+		for p := n; p != nil; p = p.Parent {
+			if p.Type == golang.FuncDecl || p.Type == golang.FuncLit {
+				chain = append([]*syntax.Node{p}, chain...)
+			}
+		}
+		if i == 0 {
+			common = chain
+			continue
+		}
+		k := 0
+		for k < len(common) && k < len(chain) && common[k] == chain[k] {
+			k++
+		}
+		common = common[:k]
+	}
+
+	path := packageName(file)
+	var outer *syntax.Node // the scope of the next function literal
+	if len(nodes) > 0 {
+		for outer = nodes[0]; outer.Parent != nil; outer = outer.Parent {
+		}
+	}
+	for i, fn := range common {
+		if fn.Type == golang.FuncDecl {
+			if recv := receiver(fn, file); recv != "" {
+				path += "." + recv
+			}
+			path += "." + funcName(fn, file)
+		} else if i == 0 || common[i-1].Type == golang.FuncDecl {
+			path += fmt.Sprintf(".func%d", funcLitIndex(outer, fn))
+		} else {
+			path += fmt.Sprintf(".%d", funcLitIndex(outer, fn))
+		}
+		outer = fn
+	}
+	return path
+}
+
+// funcLitIndex returns the 1-based index of the function literal lit
+// among those directly in the scope, in the source order.
+func funcLitIndex(scope, lit *syntax.Node) int {
+	i := 0
+	var find func(n *syntax.Node) bool
+	find = func(n *syntax.Node) bool {
+		for _, c := range n.Children {
+			if c.Type == golang.FuncLit {
+				i++
+				if c == lit {
+					return true
+				}
+			} else if find(c) {
+				return true
+			}
+		}
+		return false
+	}
+	find(scope)
+	return i
+}
+
+// receiver returns the receiver type of the method declaration decl
+// without the type parameters, like (*Server) or Server, or an empty
+// string if decl declares a function.
+func receiver(decl *syntax.Node, file []byte) string {
+	if len(decl.Children) == 0 || decl.Children[0].Type != golang.FieldList {
+		return ""
+	}
+	recv := decl.Children[0]
+	fields := strings.Fields(strings.Trim(string(file[recv.Pos:recv.End]), "()"))
+	if len(fields) > 1 && !strings.HasPrefix(fields[0], "*") {
+		fields = fields[1:] // the name
+	}
+	typ := strings.Join(fields, "")
+	if i := strings.IndexByte(typ, '['); i >= 0 {
+		typ = typ[:i]
+	}
+	if strings.HasPrefix(typ, "*") {
+		return "(" + typ + ")"
+	}
+	return typ
+}
+
+// packageName returns the name of the package of the file.
+func packageName(file []byte) string {
+	f, err := parser.ParseFile(token.NewFileSet(), "", file, parser.PackageClauseOnly)
+	if err != nil {
+		return ""
+	}
+	return f.Name.Name
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mibk/dupl/syntax"
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+const scopeSrc = `package api
+
+var handler = func() { alpha() }
+
+type Server[T any] struct{}
+
+func (s *Server[T]) handle() {
+	beta()
+	go func() {
+		gamma()
+		defer func() {}()
+		defer func() { delta() }()
+	}()
+}
+
+func (Server[T]) get() { epsilon() }
+`
+
+func TestScopePath(t *testing.T) {
+	file := []byte(scopeSrc)
+	root, err := golang.Parse("api.go", file, golang.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// nodeAt returns the deepest node starting with the text.
+	nodeAt := func(text string) *syntax.Node {
+		pos := strings.Index(scopeSrc, text)
+		var found *syntax.Node
+		var walk func(n *syntax.Node)
+		walk = func(n *syntax.Node) {
+			if n.Pos == pos {
+				found = n
+			}
+			for _, c := range n.Children {
+				walk(c)
+			}
+		}
+		walk(root)
+		if found == nil {
+			t.Fatalf("no node at %q", text)
+		}
+		return found
+	}
+
+	testCases := []struct {
+		nodes []string
+		want  string
+	}{
+		{[]string{"alpha()"}, "api.func1"},
+		{[]string{"type Server"}, "api"},
+		{[]string{"beta()"}, "api.(*Server).handle"},
+		{[]string{"gamma()"}, "api.(*Server).handle.func1"},
+		{[]string{"delta()"}, "api.(*Server).handle.func1.2"},
+		{[]string{"beta()", "gamma()"}, "api.(*Server).handle"},
+		{[]string{"epsilon()"}, "api.Server.get"},
+		{[]string{"func (s *Server"}, "api.(*Server).handle"},
+		{[]string{"func (s *Server", "func (Server"}, "api"},
+	}
+	for _, tc := range testCases {
+		var nodes []*syntax.Node
+		for _, text := range tc.nodes {
+			nodes = append(nodes, nodeAt(text))
+		}
+		if got := scopePath(file, nodes); got != tc.want {
+			t.Errorf("scope of %q is %s, want %s", tc.nodes, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+// seedLen is the number of tokens of the seed file, which occupy
+// the start of the suffix tree, or 0 if there is no seed file.
+var seedLen int
+
+// seedFirst returns a feed of the seed file followed by the files
+// of feed other than the seed file.
+func seedFirst(seed string, feed chan string) chan string {
+	fchan := make(chan string)
+	go func() {
+		fchan <- seed
+		for file := range feed {
+			if foldPath(filepath.Clean(file)) != foldPath(filepath.Clean(seed)) {
+				fchan <- file
+			}
+		}
+		close(fchan)
+	}()
+	return fchan
+}
+
+// seedTokens returns the number of leading tokens of data
+// coming from the seed file.
+func seedTokens(data []*syntax.Node, seed string) int {
+	n := 0
+	for n < len(data) && data[n].Filename == seed {
+		n++
+	}
+	return n
+}
+
+// hasSeedFrag reports whether any fragment of the match is found
+// in the seed file.
+func hasSeedFrag(m syntax.Match) bool {
+	for _, frag := range m.Frags {
+		if foldPath(filepath.Clean(frag[0].Filename)) == foldPath(filepath.Clean(*seed)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSelfClones(t *testing.T) {
+	defer func(nc bool, th, to int) {
+		*noCrawlCache, *fromThreshold, *toThreshold, *selfClones = nc, th, to, false
+	}(*noCrawlCache, *fromThreshold, *toThreshold)
+	*noCrawlCache, *selfClones = true, true
+	*fromThreshold, *toThreshold = 20, 20
+
+	var doc struct {
+		Groups []struct {
+			Fragments []struct {
+				LineStart int
+			}
+		}
+	}
+	out := scanForTest(t, []string{"testdata/self"})
+	if err := json.Unmarshal(out, &doc); err != nil {
+		t.Fatal(err)
+	}
+	// The loop of quadruple is a third copy of those of the case
+	// clauses of convert, but in another function.
+	loops := 0
+	for _, g := range doc.Groups {
+		for _, frag := range g.Fragments {
+			if frag.LineStart > 20 {
+				t.Errorf("fragment outside convert reported:\n%s", out)
+			}
+		}
+		if len(g.Fragments) == 2 && g.Fragments[0].LineStart == 7 && g.Fragments[1].LineStart == 13 {
+			loops++
+		}
+	}
+	if loops != 1 {
+		t.Errorf("the loops at lines 7 and 13 not reported as a group:\n%s", out)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"time"
+
+	"github.com/mibk/dupl/job"
+	"github.com/mibk/dupl/printer"
+	"github.com/mibk/dupl/syntax"
+)
+
+// selftestFixture is a corpus with a known number of clone groups,
+// the files of the directory of its name in selftestFiles.
+type selftestFixture struct {
+	name   string
+	groups int
+}
+
+// selftestFixtures are the fixtures of -selftest. Their clones are well
+// over the default threshold, so that they are found with the usual
+// flags too.
+var selftestFixtures = []selftestFixture{
+	{"copied", 1},
+	{"renamed", 1},
+	{"three-copies", 1},
+	{"unrelated", 0},
+}
+
+//go:embed testdata/selftest
+var selftestFiles embed.FS
+
+// runSelftest searches the fixtures for clones with the flags given,
+// writes whether the number of clone groups found in each of them is
+// the expected one, and reports whether they all are.
+func runSelftest(w io.Writer) (bool, error) {
+	paths = nil
+	ok := true
+	for _, fx := range selftestFixtures {
+		n, err := selftestGroups(fx)
+		if err != nil {
+			return false, err
+		}
+		if n == fx.groups {
+			fmt.Fprintf(w, "ok    %s: %d clone groups\n", fx.name, n)
+		} else {
+			fmt.Fprintf(w, "FAIL  %s: found %d clone groups, want %d\n", fx.name, n, fx.groups)
+			ok = false
+		}
+	}
+	return ok, nil
+}
+
+// selftestGroups returns the number of clone groups found in the files
+// of the fixture, which are read from memory like archive members.
+func selftestGroups(fx selftestFixture) (int, error) {
+	dir := path.Join("testdata/selftest", fx.name)
+	entries, err := fs.ReadDir(selftestFiles, dir)
+	if err != nil {
+		return 0, err
+	}
+	var names []string
+	for _, e := range entries {
+		src, err := selftestFiles.ReadFile(path.Join(dir, e.Name()))
+		if err != nil {
+			return 0, err
+		}
+		name := path.Join("selftest", fx.name, e.Name())
+		archives.mu.Lock()
+		archives.files[name] = src
+		archives.from[name] = "selftest"
+		archives.mu.Unlock()
+		names = append(names, name)
+	}
+	fchan := make(chan string)
+	go func() {
+		for _, name := range names {
+			fchan <- name
+		}
+		close(fchan)
+	}()
+
+	start := time.Now()
+	var stats job.Stats
+	schan := job.Parse(fchan, readFile, parseOptions(), &stats, nil)
+	t, data, done := job.BuildTree(schan)
+	<-done
+	t.Update(&syntax.Node{Type: -1})
+
+	from, to := thresholdRange(stats.Filenames)
+	mchan := t.FindDuplOver(from)
+	duplChan := make(chan syntax.Match)
+	go findDuplicates(data, from, to, mchan, duplChan)
+
+	c := new(collector)
+	_, err = printDupls(c, duplChan, func() printer.Totals {
+		return printer.Totals{
+			Files:    stats.Files,
+			Lines:    stats.Lines,
+			Tokens:   stats.Tokens,
+			Duration: time.Since(start),
+		}
+	})
+	return len(c.groups), err
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mibk/dupl/job"
+	"github.com/mibk/dupl/printer"
+	"github.com/mibk/dupl/suffixtree"
+	"github.com/mibk/dupl/syntax"
+)
+
+// server answers clone queries about the most recently scanned corpus.
+type server struct {
+	opts printer.Options
+
+	// scanMu serializes the scans and the updates, which share the
+	// global paths and the corpus.
+	scanMu sync.Mutex
+	corpus *corpus
+
+	mu     sync.RWMutex
+	groups []printer.Group
+	totals printer.Totals
+}
+
+// corpus is the suffix tree of the scanned files, kept to add the
+// changed files to it instead of building it anew.
+type corpus struct {
+	tree  *suffixtree.STree
+	data  *[]*syntax.Node // in step with the positions of the tree
+	stats job.Stats
+
+	files       map[string]fileVersion // the current versions
+	stale       []fileVersion          // the replaced ones
+	staleTokens int
+}
+
+// fileVersion is a version of a file in the data of the corpus.
+type fileVersion struct {
+	from, to suffixtree.Pos
+	lines    int
+}
+
+// serve starts an HTTP server on addr. The corpus given by paths
+// is scanned before the server starts accepting requests.
+func serve(addr string, opts printer.Options) error {
+	s := &server{opts: opts}
+	if err := s.scan(paths); err != nil {
+		return err
+	}
+	http.HandleFunc("/scan", s.handleScan)
+	http.HandleFunc("/clones", s.handleClones)
+	if *verbose {
+		log.Println("Serving on", addr)
+	}
+	return http.ListenAndServe(addr, nil)
+}
+
+// scan searches for clones in ps and replaces the stored results.
+func (s *server) scan(ps []string) error {
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+	return s.rebuild(ps)
+}
+
+// errNotScanned is the error of an update with a file out of the
+// scanned paths.
+var errNotScanned = errors.New("not in the scanned paths")
+
+// rebuild builds the corpus of ps anew and searches it for clones.
+// If the paths cannot be crawled, the previous corpus is kept.
+func (s *server) rebuild(ps []string) error {
+	for _, path := range ps {
+		if _, err := os.Lstat(path); err != nil {
+			return err
+		}
+	}
+	prev := paths
+	paths = ps
+	forgetSources()
+
+	start := time.Now()
+	c := &corpus{files: make(map[string]fileVersion)}
+	fchan, errc := crawlPaths(ps)
+	schan := job.Parse(fchan, readFile, parseOptions(), &c.stats, nil)
+	var done chan bool
+	c.tree, c.data, done = job.BuildTree(schan)
+	<-done
+	if err := <-errc; err != nil {
+		paths = prev
+		return err
+	}
+	c.tree.Update(&syntax.Node{Type: -1})
+	data := *c.data
+	for i := 0; i < len(data); {
+		j := i + 1
+		for j < len(data) && data[j].Filename == data[i].Filename {
+			j++
+		}
+		src, err := readFile(data[i].Filename)
+		if err != nil {
+			return err
+		}
+		c.files[filepath.Clean(data[i].Filename)] = fileVersion{suffixtree.Pos(i), suffixtree.Pos(j), job.CountLines(src)}
+		i = j
+	}
+	// The position of the terminator.
+	*c.data = append(*c.data, &syntax.Node{Type: -1})
+	s.corpus = c
+	return s.find(start)
+}
+
+// update adds the current versions of the changed files to the corpus
+// and searches it for clones. The suffix tree cannot drop the tokens of
+// a file, so the replaced versions stay in it, masked out of the
+// matches, until they outweigh the current ones, when the corpus is
+// rebuilt; it is rebuilt at once if one of the files is deleted.
+// The files that fail to parse keep their previous versions.
+func (s *server) update(files []string) error {
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+	for i, file := range files {
+		if _, err := os.Lstat(file); os.IsNotExist(err) {
+			return s.rebuild(paths)
+		} else if err != nil {
+			return err
+		}
+		files[i] = filepath.Clean(file)
+		if !inPaths(files[i]) {
+			return fmt.Errorf("%s: %w", file, errNotScanned)
+		}
+	}
+	forgetSources(files...)
+
+	start := time.Now()
+	c := s.corpus
+	fchan := make(chan string)
+	go func() {
+		for _, file := range files {
+			fchan <- file
+		}
+		close(fchan)
+	}()
+	for seq := range job.Parse(fchan, readFile, parseOptions(), &c.stats, nil) {
+		if len(seq) == 0 {
+			continue
+		}
+		name := seq[0].Filename
+		src, err := readFile(name)
+		if err != nil {
+			return err
+		}
+		if old, ok := c.files[name]; ok {
+			c.stale = append(c.stale, old)
+			c.staleTokens += int(old.to - old.from)
+			c.stats.Files--
+			c.stats.Lines -= old.lines
+			c.stats.Tokens -= int(old.to - old.from)
+		}
+		from := suffixtree.Pos(len(*c.data))
+		*c.data = append(*c.data, seq...)
+		c.files[name] = fileVersion{from, suffixtree.Pos(len(*c.data)), job.CountLines(src)}
+		// The position of the terminator.
+		*c.data = append(*c.data, &syntax.Node{Type: -1})
+		toks := make([]suffixtree.Token, len(seq))
+		for i, node := range seq {
+			toks[i] = node
+		}
+		c.tree.AddFile(toks...)
+	}
+	if c.staleTokens > c.stats.Tokens {
+		return s.rebuild(paths)
+	}
+	return s.find(start)
+}
+
+// inPaths reports whether the file is in one of the scanned paths.
+func inPaths(file string) bool {
+	for _, p := range paths {
+		if inDir(file, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// find searches the corpus for clones and replaces the stored results.
+func (s *server) find(start time.Time) error {
+	c := s.corpus
+	mchan := c.live(c.tree.FindDuplOver(*fromThreshold))
+	duplChan := make(chan syntax.Match)
+	go findDuplicates(c.data, *fromThreshold, *toThreshold, mchan, duplChan)
+
+	col := new(collector)
+	_, err := printDupls(col, duplChan, func() printer.Totals {
+		return printer.Totals{
+			Files:    c.stats.Files,
+			Lines:    c.stats.Lines,
+			Tokens:   c.stats.Tokens,
+			Duration: time.Since(start),
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.groups, s.totals = col.groups, col.totals
+	s.mu.Unlock()
+	return nil
+}
+
+// live drops the copies in the replaced versions of the files from
+// the matches, and the matches left with a single copy.
+func (c *corpus) live(mchan <-chan suffixtree.Match) <-chan suffixtree.Match {
+	if len(c.stale) == 0 {
+		return mchan
+	}
+	out := make(chan suffixtree.Match)
+	go func() {
+		for m := range mchan {
+			var ps []suffixtree.Pos
+			for _, p := range m.Ps {
+				if !c.isStale(p, p+m.Len) {
+					ps = append(ps, p)
+				}
+			}
+			if len(ps) > 1 {
+				m.Ps = ps
+				out <- m
+			}
+		}
+		close(out)
+	}()
+	return out
+}
+
+// isStale reports whether the data between from and to overlaps
+// a replaced version of a file.
+func (c *corpus) isStale(from, to suffixtree.Pos) bool {
+	for _, v := range c.stale {
+		if from < v.to && v.from < to {
+			return true
+		}
+	}
+	return false
+}
+
+// handleScan rescans the corpus. The request body is a JSON object
+// with either the paths to scan, or the files changed since the last
+// scan, to update the corpus with; an empty body rescans the same paths.
+func (s *server) handleScan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req := struct {
+		Paths   []string `json:"paths"`
+		Changed []string `json:"changed"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && r.ContentLength != 0 {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	var err error
+	switch {
+	case len(req.Paths) > 0 && len(req.Changed) > 0:
+		http.Error(w, "invalid request: both paths and changed files given", http.StatusBadRequest)
+		return
+	case len(req.Changed) > 0:
+		err = s.update(req.Changed)
+	case len(req.Paths) > 0:
+		err = s.scan(req.Paths)
+	default:
+		s.scanMu.Lock()
+		ps := paths
+		s.scanMu.Unlock()
+		err = s.scan(ps)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), scanStatus(err))
+		return
+	}
+	s.write(w, func(printer.Group) bool { return true })
+}
+
+// scanStatus returns the HTTP status of the error of a scan: the paths
+// that cannot be read, or are not scanned, are the fault of the request,
+// the other errors the fault of the server.
+func scanStatus(err error) int {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) || errors.Is(err, errNotScanned) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// handleClones returns the clone groups with a fragment in the file
+// given by the file query parameter.
+func (s *server) handleClones(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	file := filepath.Clean(r.URL.Query().Get("file"))
+	if file == "." {
+		http.Error(w, "missing file parameter", http.StatusBadRequest)
+		return
+	}
+	s.write(w, func(g printer.Group) bool {
+		for _, frag := range g.Frags {
+			if filepath.Clean(frag.Filename) == file {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// write writes the stored groups selected by keep as a JSON document.
+func (s *server) write(w http.ResponseWriter, keep func(printer.Group) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	w.Header().Set("Content-Type", "application/json")
+	p := printer.NewJSON(w, readFile, s.opts)
+	if err := p.PrintHeader(); err != nil {
+		log.Println(err)
+		return
+	}
+	for _, g := range s.groups {
+		if !keep(g) {
+			continue
+		}
+		if err := p.PrintClones(g); err != nil {
+			log.Println(err)
+			return
+		}
+	}
+	if err := p.PrintFooter(s.totals); err != nil {
+		log.Println(err)
+	}
+}
+
+// collector is a printer keeping the clone groups in memory.
+type collector struct {
+	groups []printer.Group
+	totals printer.Totals
+}
+
+func (c *collector) PrintHeader() error { return nil }
+
+func (c *collector) PrintClones(g printer.Group) error {
+	c.groups = append(c.groups, g)
+	return nil
+}
+
+func (c *collector) PrintFooter(t printer.Totals) error {
+	c.totals = t
+	return nil
+}
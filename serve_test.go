@@ -0,0 +1,295 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mibk/dupl/suffixtree"
+)
+
+const (
+	serveSrc = `package p
+
+func f(a, b int) int {
+	x := a + b
+	y := a * b
+	if x > y {
+		return x - y
+	}
+	return y - x
+}
+`
+	// serveOther is as long as serveSrc, but has another structure.
+	serveOther = `package p
+
+func g(a, b int) int {
+	for a > b {
+		a = a - b
+		b = b + 1
+	}
+	return a + b
+}
+`
+)
+
+// serveResponse is the part of the JSON output the tests check.
+type serveResponse struct {
+	Groups []struct {
+		Fragments []struct {
+			File string `json:"file"`
+		} `json:"fragments"`
+	} `json:"groups"`
+}
+
+// newTestServer returns a server of a directory with a.go and b.go of
+// the given sources, scanned.
+func newTestServer(t *testing.T, a, b string) (*server, string) {
+	t.Helper()
+	oldPaths, oldFrom, oldTo, oldNoCache := paths, *fromThreshold, *toThreshold, *noCrawlCache
+	t.Cleanup(func() {
+		paths = oldPaths
+		*fromThreshold = oldFrom
+		*toThreshold = oldTo
+		*noCrawlCache = oldNoCache
+		forgetSources()
+	})
+	*fromThreshold, *toThreshold = 15, 15
+	*noCrawlCache = true
+
+	dir := t.TempDir()
+	writeServeFile(t, filepath.Join(dir, "a.go"), a)
+	writeServeFile(t, filepath.Join(dir, "b.go"), b)
+	s := &server{}
+	if err := s.scan([]string{dir}); err != nil {
+		t.Fatal(err)
+	}
+	return s, dir
+}
+
+func writeServeFile(t *testing.T, name, src string) {
+	t.Helper()
+	if err := os.WriteFile(name, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serveRequest sends the request to the handler and returns the status
+// and the body of the response.
+func serveRequest(h http.HandlerFunc, method, target, body string) (int, string) {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h(w, r)
+	return w.Code, w.Body.String()
+}
+
+// groupFiles returns the base names of the files of the fragments of
+// each group in the JSON response.
+func groupFiles(t *testing.T, body string) [][]string {
+	t.Helper()
+	var resp serveResponse
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatalf("invalid response %q: %v", body, err)
+	}
+	groups := [][]string{}
+	for _, g := range resp.Groups {
+		var files []string
+		for _, frag := range g.Fragments {
+			files = append(files, filepath.Base(frag.File))
+		}
+		groups = append(groups, files)
+	}
+	return groups
+}
+
+func TestHandleScan(t *testing.T) {
+	s, dir := newTestServer(t, serveSrc, serveSrc)
+	archive := filepath.Join(dir, "broken.zip")
+	writeServeFile(t, archive, "not a zip file")
+	outside := filepath.Join(t.TempDir(), "c.go")
+	writeServeFile(t, outside, serveSrc)
+
+	testCases := []struct {
+		name   string
+		method string
+		body   string
+		status int
+		groups int
+	}{
+		{"same paths", http.MethodPost, "", http.StatusOK, 1},
+		{"empty object", http.MethodPost, "{}", http.StatusOK, 1},
+		{"paths", http.MethodPost, fmt.Sprintf(`{"paths": [%q]}`, filepath.Join(dir, "a.go")), http.StatusOK, 0},
+		{"changed", http.MethodPost, fmt.Sprintf(`{"changed": [%q]}`, filepath.Join(dir, "a.go")), http.StatusOK, 0},
+		{"missing path", http.MethodPost, fmt.Sprintf(`{"paths": [%q]}`, filepath.Join(dir, "x.go")), http.StatusBadRequest, -1},
+		{"not scanned", http.MethodPost, fmt.Sprintf(`{"changed": [%q]}`, outside), http.StatusBadRequest, -1},
+		{"broken archive", http.MethodPost, fmt.Sprintf(`{"paths": [%q]}`, archive), http.StatusInternalServerError, -1},
+		{"both", http.MethodPost, `{"paths": ["a"], "changed": ["b"]}`, http.StatusBadRequest, -1},
+		{"invalid JSON", http.MethodPost, "{", http.StatusBadRequest, -1},
+		{"wrong method", http.MethodGet, "", http.StatusMethodNotAllowed, -1},
+		// The failed scans keep the previous results and paths.
+		{"after the errors", http.MethodPost, "", http.StatusOK, 0},
+		{"back to the directory", http.MethodPost, fmt.Sprintf(`{"paths": [%q]}`, dir), http.StatusOK, 1},
+	}
+	for _, tc := range testCases {
+		status, body := serveRequest(s.handleScan, tc.method, "/scan", tc.body)
+		if status != tc.status {
+			t.Errorf("%s: status %d, want %d: %s", tc.name, status, tc.status, body)
+			continue
+		}
+		if tc.groups < 0 {
+			continue
+		}
+		if n := len(groupFiles(t, body)); n != tc.groups {
+			t.Errorf("%s: got %d groups, want %d", tc.name, n, tc.groups)
+		}
+	}
+}
+
+func TestHandleClones(t *testing.T) {
+	s, dir := newTestServer(t, serveSrc, serveSrc)
+	writeServeFile(t, filepath.Join(dir, "c.go"), serveOther)
+	if err := s.scan([]string{dir}); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name   string
+		method string
+		target string
+		status int
+		groups int
+	}{
+		{"clone", http.MethodGet, "/clones?file=" + filepath.Join(dir, "a.go"), http.StatusOK, 1},
+		{"unclean name", http.MethodGet, "/clones?file=" + dir + "/./b.go", http.StatusOK, 1},
+		{"no clones", http.MethodGet, "/clones?file=" + filepath.Join(dir, "c.go"), http.StatusOK, 0},
+		{"unknown file", http.MethodGet, "/clones?file=x.go", http.StatusOK, 0},
+		{"missing file", http.MethodGet, "/clones", http.StatusBadRequest, -1},
+		{"wrong method", http.MethodPost, "/clones?file=a.go", http.StatusMethodNotAllowed, -1},
+	}
+	for _, tc := range testCases {
+		status, body := serveRequest(s.handleClones, tc.method, tc.target, "")
+		if status != tc.status {
+			t.Errorf("%s: status %d, want %d: %s", tc.name, status, tc.status, body)
+			continue
+		}
+		if tc.groups < 0 {
+			continue
+		}
+		if n := len(groupFiles(t, body)); n != tc.groups {
+			t.Errorf("%s: got %d groups, want %d", tc.name, n, tc.groups)
+		}
+	}
+}
+
+// TestUpdateMasksStale checks that the clones of the replaced versions
+// of the files, which stay in the suffix tree, are not reported.
+func TestUpdateMasksStale(t *testing.T) {
+	s, dir := newTestServer(t, serveSrc, serveSrc)
+	a, b := filepath.Join(dir, "a.go"), filepath.Join(dir, "b.go")
+	update := func(file, src string) [][]string {
+		t.Helper()
+		writeServeFile(t, file, src)
+		status, body := serveRequest(s.handleScan, http.MethodPost, "/scan", fmt.Sprintf(`{"changed": [%q]}`, file))
+		if status != http.StatusOK {
+			t.Fatalf("status %d: %s", status, body)
+		}
+		return groupFiles(t, body)
+	}
+
+	if got := update(b, serveOther); len(got) != 0 {
+		t.Errorf("after b.go changed: got groups %v, want none", got)
+	}
+	if len(s.corpus.stale) != 1 {
+		t.Fatalf("got %d stale versions, want 1; the corpus was rebuilt", len(s.corpus.stale))
+	}
+	// The first version of b.go is still in the tree, and a clone of a.go.
+	if got, want := update(b, serveSrc), [][]string{{"a.go", "b.go"}}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("after b.go changed back: got groups %v, want %v", got, want)
+	}
+	if got := update(a, serveOther); len(got) != 0 {
+		t.Errorf("after a.go changed: got groups %v, want none", got)
+	}
+}
+
+func TestIsStale(t *testing.T) {
+	c := &corpus{stale: []fileVersion{{from: 10, to: 20}, {from: 30, to: 40}}}
+	testCases := []struct {
+		from, to int
+		want     bool
+	}{
+		{0, 10, false},
+		{0, 11, true},
+		{12, 18, true},
+		{19, 25, true},
+		{20, 30, false},
+		{5, 45, true},
+		{40, 50, false},
+	}
+	for _, tc := range testCases {
+		if got := c.isStale(suffixtree.Pos(tc.from), suffixtree.Pos(tc.to)); got != tc.want {
+			t.Errorf("isStale(%d, %d) = %v, want %v", tc.from, tc.to, got, tc.want)
+		}
+	}
+}
+
+// TestServeParallel checks, run with -race, that the scans and the
+// queries can run at the same time.
+func TestServeParallel(t *testing.T) {
+	s, dir := newTestServer(t, serveSrc, serveSrc)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/scan", s.handleScan)
+	mux.HandleFunc("/clones", s.handleClones)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	check := func(resp *http.Response, err error) {
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK {
+			errs <- fmt.Errorf("status %d: %s", resp.StatusCode, body)
+		}
+	}
+	changed := fmt.Sprintf(`{"changed": [%q]}`, filepath.Join(dir, "b.go"))
+	requests := []func() (*http.Response, error){
+		func() (*http.Response, error) {
+			return http.Post(ts.URL+"/scan", "application/json", strings.NewReader("{}"))
+		},
+		func() (*http.Response, error) {
+			return http.Post(ts.URL+"/scan", "application/json", strings.NewReader(changed))
+		},
+		func() (*http.Response, error) { return http.Get(ts.URL + "/clones?file=" + filepath.Join(dir, "a.go")) },
+		func() (*http.Response, error) { return http.Get(ts.URL + "/clones?file=" + filepath.Join(dir, "b.go")) },
+	}
+	for i := 0; i < 10; i++ {
+		for _, req := range requests {
+			wg.Add(1)
+			go func(req func() (*http.Response, error)) {
+				defer wg.Done()
+				check(req())
+			}(req)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	status, body := serveRequest(s.handleClones, http.MethodGet, "/clones?file="+filepath.Join(dir, "a.go"), "")
+	if status != http.StatusOK || len(groupFiles(t, body)) != 1 {
+		t.Errorf("after the requests: status %d, body %s; want 1 group", status, body)
+	}
+}
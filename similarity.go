@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+
+	"github.com/mibk/dupl/printer"
+	"github.com/mibk/dupl/syntax"
+)
+
+// representative returns the index of the representative fragment
+// of the group, the largest one, or the first of the largest ones.
+func representative(g *printer.Group) int {
+	rep := 0
+	for i, frag := range g.Frags {
+		if frag.End-frag.Pos > g.Frags[rep].End-g.Frags[rep].Pos {
+			rep = i
+		}
+	}
+	return rep
+}
+
+// leaves appends the nodes without children of the trees rooted
+// at nodes to dst, in the source order.
+func leaves(dst []*syntax.Node, nodes []*syntax.Node) []*syntax.Node {
+	for _, n := range nodes {
+		if len(n.Children) == 0 {
+			dst = append(dst, n)
+		} else {
+			dst = leaves(dst, n.Children)
+		}
+	}
+	return dst
+}
+
+// scoreSimilarity sets the similarity of every fragment of the group
+// to its representative, the largest fragment, as the percentage of
+// the tokens, i.e. identifiers, literals, and other nodes without
+// children, having the same source text as in the representative.
+// The fragments of a group have the same structure, so their tokens
+// correspond one to one.
+func scoreSimilarity(g *printer.Group) error {
+	rep := representative(g)
+	repFile, err := readFile(g.Frags[rep].Filename)
+	if err != nil {
+		return err
+	}
+	repLeaves := leaves(nil, g.Frags[rep].Nodes)
+	for i := range g.Frags {
+		frag := &g.Frags[i]
+		file, err := readFile(frag.Filename)
+		if err != nil {
+			return err
+		}
+		ls := leaves(nil, frag.Nodes)
+		if len(ls) != len(repLeaves) || len(ls) == 0 {
+			frag.Similarity = 100
+			continue
+		}
+		same := 0
+		for j, l := range ls {
+			r := repLeaves[j]
+			if bytes.Equal(file[l.Pos:l.End], repFile[r.Pos:r.End]) {
+				same++
+			}
+		}
+		frag.Similarity = 100 * float64(same) / float64(len(ls))
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/mibk/dupl/printer"
+
+	_ "modernc.org/sqlite" // pure-Go driver, not needing cgo
+)
+
+// sqliteSchema creates the tables of the -sqlite database, unless they
+// exist from the previous runs.
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS runs (
+	id INTEGER PRIMARY KEY,
+	time TEXT NOT NULL,
+	commit_id TEXT,
+	files INTEGER NOT NULL,
+	lines INTEGER NOT NULL,
+	tokens INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS groups (
+	run_id INTEGER NOT NULL REFERENCES runs(id),
+	group_id TEXT NOT NULL,
+	tokens INTEGER NOT NULL,
+	exact INTEGER NOT NULL,
+	fragments INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS fragments (
+	run_id INTEGER NOT NULL REFERENCES runs(id),
+	group_id TEXT NOT NULL,
+	file TEXT NOT NULL,
+	start_line INTEGER NOT NULL,
+	end_line INTEGER NOT NULL
+);
+`
+
+// writeSQLite adds the run with the reported groups to the SQLite
+// database at path, creating it if missing.
+func writeSQLite(path string, groups []printer.Group, t printer.Totals) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	if err := addRun(db, groups, t, time.Now(), runCommit()); err != nil {
+		db.Close()
+		return err
+	}
+	return db.Close()
+}
+
+// runCommit returns the commit the run is of: the value of DUPL_COMMIT,
+// or else the HEAD of the Git repository of the current directory,
+// or "" if it is unknown.
+func runCommit() string {
+	if c := os.Getenv("DUPL_COMMIT"); c != "" {
+		return c
+	}
+	out, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// addRun adds the run with the groups to the database in a single
+// transaction, so that a failed run leaves no trace.
+func addRun(db *sql.DB, groups []printer.Group, t printer.Totals, now time.Time, commit string) error {
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // no-op after the commit
+
+	var commitID interface{}
+	if commit != "" {
+		commitID = commit
+	}
+	res, err := tx.Exec("INSERT INTO runs (time, commit_id, files, lines, tokens) VALUES (?, ?, ?, ?, ?)",
+		now.UTC().Format(time.RFC3339), commitID, t.Files, t.Lines, t.Tokens)
+	if err != nil {
+		return err
+	}
+	run, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	insertGroup, err := tx.Prepare("INSERT INTO groups VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insertGroup.Close()
+	insertFrag, err := tx.Prepare("INSERT INTO fragments VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insertFrag.Close()
+
+	for _, g := range groups {
+		exact, err := isExact(g)
+		if err != nil {
+			return err
+		}
+		if _, err := insertGroup.Exec(run, g.ID(), g.Tokens, exact, len(g.Frags)); err != nil {
+			return err
+		}
+		for _, frag := range g.Frags {
+			file, err := readFile(frag.Filename)
+			if err != nil {
+				return err
+			}
+			start := bytes.Count(file[:frag.Pos], []byte{'\n'}) + 1
+			end := start + bytes.Count(file[frag.Pos:frag.End], []byte{'\n'})
+			if _, err := insertFrag.Exec(run, g.ID(), frag.Filename, start, end); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
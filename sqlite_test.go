@@ -0,0 +1,114 @@
+package main
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mibk/dupl/printer"
+)
+
+func TestWriteSQLite(t *testing.T) {
+	t.Setenv("DUPL_COMMIT", "c0ffee")
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.go")
+	if err := os.WriteFile(src, []byte("package a\n\nfunc f() {\n\tg()\n}\n\nfunc h() {\n\tg()\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	g := printer.Group{
+		Hash:   "\x01\x02\x03\x04\x05\x06\x07\x08",
+		Tokens: 3,
+		Frags: []printer.Fragment{
+			{Filename: src, Pos: 11, End: 28},
+			{Filename: src, Pos: 30, End: 47},
+		},
+	}
+	path := filepath.Join(dir, "dupl.db")
+	for i := 0; i < 2; i++ {
+		if err := writeSQLite(path, []printer.Group{g}, printer.Totals{Files: 1, Lines: 9, Tokens: 20}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, tc := range []struct {
+		query, want string
+	}{
+		{"SELECT id, commit_id, files, lines, tokens FROM runs", "1|c0ffee|1|9|20\n2|c0ffee|1|9|20"},
+		{"SELECT run_id, group_id, tokens, exact, fragments FROM groups", "1|0102030405060708|3|0|2\n2|0102030405060708|3|0|2"},
+		{"SELECT group_id, start_line, end_line FROM fragments WHERE run_id = 2", "0102030405060708|3|5\n0102030405060708|7|9"},
+	} {
+		if got := queryRows(t, db, tc.query); got != tc.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestWriteSQLiteQuoting(t *testing.T) {
+	t.Setenv("DUPL_COMMIT", "it's'); DROP TABLE runs; --")
+	src := "package a\n\nfunc f() {\n\tg()\n}\n\nfunc f() {\n\tg()\n}\n"
+	name := "it's.go"
+	addMemFile(t, name, src)
+	g := printer.Group{
+		Hash:   "\x01\x02\x03\x04\x05\x06\x07\x08",
+		Tokens: 3,
+		Frags:  []printer.Fragment{{Filename: name, Pos: 11, End: 28}, {Filename: name, Pos: 30, End: 47}},
+	}
+	path := filepath.Join(t.TempDir(), "dupl.db")
+	if err := writeSQLite(path, []printer.Group{g}, printer.Totals{}); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if got, want := queryRows(t, db, "SELECT commit_id FROM runs"), "it's'); DROP TABLE runs; --"; got != want {
+		t.Errorf("got the commit %q, want %q", got, want)
+	}
+	// Both fragments are the same text, so it is an exact clone.
+	if got, want := queryRows(t, db, "SELECT DISTINCT file, exact FROM fragments JOIN groups USING (run_id, group_id)"), "it's.go|1"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// queryRows returns the rows of the query, one at each line, with the
+// columns separated by |.
+func queryRows(t *testing.T, db *sql.DB, query string) string {
+	t.Helper()
+	rows, err := db.Query(query)
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for rows.Next() {
+		vals := make([]sql.NullString, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			t.Fatal(err)
+		}
+		var fields []string
+		for _, v := range vals {
+			fields = append(fields, v.String)
+		}
+		lines = append(lines, strings.Join(fields, "|"))
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+// checkStrictStructural makes sure that no option changes what the
+// -strict-structural preset compares: the structure of the syntax
+// trees alone, with all identifiers and literals considered the same.
+func checkStrictStructural() error {
+	for _, opt := range []struct {
+		set  bool
+		name string
+	}{
+		{golang.Normalization(normalize) != golang.NormalizeAll, "normalize"},
+		{*tables, "tables"},
+		{*constBlocks, "const-blocks"},
+		{*bodiesOnly, "bodies-only"},
+		{*normReceivers, "normalize-receivers"},
+		{*reorderTolerant, "reorder-tolerant"},
+		{*genericsAware, "generics-aware"},
+		{*testSetup, "test-setup"},
+	} {
+		if opt.set {
+			return fmt.Errorf("-strict-structural cannot be combined with -%s", opt.name)
+		}
+	}
+	return nil
+}
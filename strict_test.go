@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestStrictStructural(t *testing.T) {
+	defer func(nc bool) { *noCrawlCache, *strictStructural = nc, false }(*noCrawlCache)
+	*noCrawlCache, *strictStructural = true, true
+	if err := checkStrictStructural(); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Groups []struct {
+			Fragments []struct {
+				File               string
+				LineStart, LineEnd int
+			}
+		}
+	}
+	out := scanForTest(t, []string{"testdata/strict"})
+	if err := json.Unmarshal(out, &doc); err != nil {
+		t.Fatal(err)
+	}
+	// The functions differ in names, literals, comments, and formatting.
+	if len(doc.Groups) != 1 {
+		t.Fatalf("got %d clone groups, want 1:\n%s", len(doc.Groups), out)
+	}
+	frags := doc.Groups[0].Fragments
+	if len(frags) != 2 || frags[0].LineStart != 6 || frags[1].LineStart != 17 {
+		t.Errorf("got fragments %+v, want the functions at lines 6 and 17", frags)
+	}
+
+	*tables = true
+	defer func() { *tables = false }()
+	if err := checkStrictStructural(); err == nil {
+		t.Error("-strict-structural combined with -tables accepted")
+	}
+}
@@ -25,10 +25,11 @@ func (p *posList) add(pos Pos) {
 
 type contextList struct {
 	lists map[int]*posList
+	min   Pos // the least of the positions
 }
 
 func newContextList() *contextList {
-	return &contextList{make(map[int]*posList)}
+	return &contextList{lists: make(map[int]*posList), min: infinity}
 }
 
 func (c *contextList) getAll() []Pos {
@@ -45,6 +46,9 @@ func (c *contextList) getAll() []Pos {
 }
 
 func (c *contextList) append(c2 *contextList) {
+	if c2.min < c.min {
+		c.min = c2.min
+	}
 	for lc, pl := range c2.lists {
 		if _, ok := c.lists[lc]; ok {
 			c.lists[lc].append(pl)
@@ -57,43 +61,85 @@ func (c *contextList) append(c2 *contextList) {
 // FindDuplOver find pairs of maximal duplicities over a threshold
 // length.
 func (t *STree) FindDuplOver(threshold int) <-chan Match {
-	auxTran := newTran(0, 0, t.root)
+	return t.FindDuplOverIn(threshold, infinity, nil)
+}
+
+// FindDuplOverUntil finds the maximal duplicities over a threshold
+// length like FindDuplOver, until done is closed, when the search stops
+// and the channel is closed; a nil done is never closed.
+func (t *STree) FindDuplOverUntil(threshold int, done <-chan struct{}) <-chan Match {
+	return t.FindDuplOverIn(threshold, infinity, done)
+}
+
+// FindDuplOverIn finds the maximal duplicities over a threshold length
+// like FindDuplOverUntil, but only those with an occurrence starting
+// before the position below. The others are neither collected nor sent.
+func (t *STree) FindDuplOverIn(threshold int, below Pos, done <-chan struct{}) <-chan Match {
 	ch := make(chan Match)
 	go func() {
-		walkTrans(auxTran, 0, threshold, ch)
+		s := duplSearch{t, threshold, below, ch, done}
+		s.walkTrans(tran{start: 0, end: 0, state: t.root}, 0)
 		close(ch)
 	}()
 	return ch
 }
 
-func walkTrans(parent *tran, length, threshold int, ch chan<- Match) *contextList {
-	s := parent.state
+// duplSearch holds the parameters of a search for the duplicities.
+type duplSearch struct {
+	t         *STree
+	threshold int
+	below     Pos
+	ch        chan<- Match
+	done      <-chan struct{}
+}
+
+// stopped reports whether the search is to stop.
+func (d *duplSearch) stopped() bool {
+	select {
+	case <-d.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (d *duplSearch) walkTrans(parent tran, length int) *contextList {
+	t, threshold, below := d.t, d.threshold, d.below
+	s := t.states[parent.state]
 
 	cl := newContextList()
 
-	if len(s.trans) == 0 {
+	if s.first == noTran {
 		pl := newPosList()
 		start := parent.end + 1 - Pos(length)
 		pl.add(start)
 		ch := 0
 		if start > 0 {
-			ch = s.tree.data[start-1].Val()
+			ch = t.data[start-1]
 		}
 		cl.lists[ch] = pl
+		cl.min = start
 		return cl
 	}
 
-	for _, t := range s.trans {
-		ln := length + t.len()
-		cl2 := walkTrans(t, ln, threshold, ch)
+	for i := s.first; i != noTran; i = t.trans[i].next {
+		if d.stopped() {
+			return cl
+		}
+		tr := t.trans[i]
+		ln := length + tr.len()
+		cl2 := d.walkTrans(tr, ln)
 		if ln >= threshold {
 			cl.append(cl2)
 		}
 	}
-	if length >= threshold && len(cl.lists) > 1 {
+	if length >= threshold && len(cl.lists) > 1 && cl.min < below {
 		allPos := cl.getAll()
 		m := Match{allPos, Pos(length)}
-		ch <- m
+		select {
+		case d.ch <- m:
+		case <-d.done:
+		}
 	}
 	return cl
 }
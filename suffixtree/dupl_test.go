@@ -73,3 +73,80 @@ All work and no play makes Jack a dull boy$`, 4, []Match{{[]Pos{0, 43}, 42}}},
 		}
 	}
 }
+
+func TestFindingDuplStopped(t *testing.T) {
+	tree := New()
+	tree.Update(str2tok("abcabcabcabc$")...)
+	done := make(chan struct{})
+	ch := tree.FindDuplOverUntil(1, done)
+	if _, ok := <-ch; !ok {
+		t.Fatal("no matches found")
+	}
+	close(done)
+	// The channel is closed, at most after the match being sent.
+	n := 0
+	for range ch {
+		n++
+	}
+	if n > 1 {
+		t.Errorf("got %d matches after the search was stopped", n)
+	}
+
+	for range tree.FindDuplOverUntil(1, done) {
+		t.Error("got a match of a search stopped before it started")
+	}
+}
+
+func TestAddFile(t *testing.T) {
+	tree := New()
+	tree.Update(str2tok("abcab$")...)
+	tree.AddFile(str2tok("xabcy")...)
+	tree.AddFile(str2tok("zab")...)
+	matches := []Match{{[]Pos{0, 7}, 3}, {[]Pos{0, 3, 7, 13}, 2}}
+	var got []Match
+	for m := range tree.FindDuplOver(2) {
+		got = append(got, m)
+	}
+	if len(got) != len(matches) {
+		t.Fatalf("got %v, want %v", got, matches)
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i].Len > got[j].Len })
+	for i, exp := range matches {
+		if act := got[i]; exp.Len != act.Len || !sliceCmp(exp.Ps, act.Ps) {
+			t.Errorf("got %v, want %v", act, exp)
+		}
+	}
+	if v := tree.At(11).Val(); v != -2 {
+		t.Errorf("terminator of the first file is %d, want -2", v)
+	}
+}
+
+func TestFindingDuplIn(t *testing.T) {
+	testCases := []struct {
+		below   Pos
+		matches []Match
+	}{
+		{0, nil},
+		{1, []Match{{[]Pos{0, 5}, 3}}},
+		{2, []Match{{[]Pos{0, 5}, 3}, {[]Pos{1, 3, 6}, 2}}},
+	}
+
+	for _, tc := range testCases {
+		tree := New()
+		tree.Update(str2tok("abcbcabc$")...)
+		var got []Match
+		for m := range tree.FindDuplOverIn(2, tc.below, nil) {
+			got = append(got, m)
+		}
+		sort.Slice(got, func(i, j int) bool { return got[i].Len > got[j].Len })
+		if len(got) != len(tc.matches) {
+			t.Errorf("below %d: got %v, want %v", tc.below, got, tc.matches)
+			continue
+		}
+		for i, exp := range tc.matches {
+			if act := got[i]; exp.Len != act.Len || !sliceCmp(exp.Ps, act.Ps) {
+				t.Errorf("below %d: got %v, want %v", tc.below, act, exp)
+			}
+		}
+	}
+}
@@ -16,31 +16,41 @@ type Token interface {
 	Val() int
 }
 
-// STree is a struct representing a suffix tree.
+// STree is a struct representing a suffix tree. The states and the
+// transitions are kept in two flat slices, referring to each other by
+// their indices, and the tokens by their values only, so that the tree
+// takes a few large allocations without pointers for the garbage
+// collector to scan, instead of several small ones per state.
 type STree struct {
-	data     []Token
-	root     *state
-	auxState *state // auxiliary state
+	data     []int // values of the tokens
+	states   []state
+	trans    []tran
+	root     stateID
+	auxState stateID // auxiliary state
 
 	// active point
-	s          *state
+	s          stateID
 	start, end Pos
+
+	files int // added by AddFile
 }
 
 // New creates new suffix tree.
 func New() *STree {
 	t := new(STree)
-	t.data = make([]Token, 0, 50)
-	t.root = newState(t)
-	t.auxState = newState(t)
-	t.root.linkState = t.auxState
+	t.data = make([]int, 0, 50)
+	t.root = t.newState()
+	t.auxState = t.newState()
+	t.states[t.root].linkState = t.auxState
 	t.s = t.root
 	return t
 }
 
 // Update refreshes the suffix tree to by new data.
 func (t *STree) Update(data ...Token) {
-	t.data = append(t.data, data...)
+	for _, tok := range data {
+		t.data = append(t.data, tok.Val())
+	}
 	for range data {
 		t.update()
 		t.s, t.start = t.canonize(t.s, t.start, t.end)
@@ -48,6 +58,26 @@ func (t *STree) Update(data ...Token) {
 	}
 }
 
+// AddFile appends the tokens of a file to the tree, followed by
+// a terminator found nowhere else in the tree, so that no match spans
+// the end of the file. The terminators are negative, from -2 down;
+// -1 is left to the callers terminating the tree by Update. As the
+// tree is built online, the file may be added after such a terminator
+// too, but the tree can never drop a file again. The terminator takes
+// a position in the tree after the tokens of the file, which the
+// callers have to keep in step with their data.
+func (t *STree) AddFile(seq ...Token) {
+	t.Update(seq...)
+	t.files++
+	t.Update(value(-1 - t.files))
+}
+
+// value is a token of the value only, like the terminators ending the
+// files added by AddFile.
+type value int
+
+func (v value) Val() int { return int(v) }
+
 // update transforms suffix tree T(n) to T(n+1).
 func (t *STree) update() {
 	oldr := t.root
@@ -55,22 +85,22 @@ func (t *STree) update() {
 	// (s, (start, end)) is the canonical reference pair for the active point
 	s := t.s
 	start, end := t.start, t.end
-	var r *state
+	var r stateID
 	for {
 		var endPoint bool
 		r, endPoint = t.testAndSplit(s, start, end-1)
 		if endPoint {
 			break
 		}
-		r.fork(end)
+		t.fork(r, end)
 		if oldr != t.root {
-			oldr.linkState = r
+			t.states[oldr].linkState = r
 		}
 		oldr = r
-		s, start = t.canonize(s.linkState, start, end-1)
+		s, start = t.canonize(t.states[s].linkState, start, end-1)
 	}
 	if oldr != t.root {
-		oldr.linkState = r
+		t.states[oldr].linkState = r
 	}
 
 	// update active point
@@ -82,22 +112,22 @@ func (t *STree) update() {
 // (s, (start, end)) is the end point, that is, a state that have
 // a c-transition. If not, then state (exs, (start, end)) is made
 // explicit (if not already so).
-func (t *STree) testAndSplit(s *state, start, end Pos) (exs *state, endPoint bool) {
+func (t *STree) testAndSplit(s stateID, start, end Pos) (exs stateID, endPoint bool) {
 	c := t.data[t.end]
 	if start <= end {
-		tr := s.findTran(t.data[start])
-		splitPoint := tr.start + end - start + 1
-		if t.data[splitPoint].Val() == c.Val() {
+		tr := t.findTran(s, t.data[start])
+		splitPoint := t.trans[tr].start + end - start + 1
+		if t.data[splitPoint] == c {
 			return s, true
 		}
 		// make the (s, (start, end)) state explicit
-		newSt := newState(s.tree)
-		newSt.addTran(splitPoint, tr.end, tr.state)
-		tr.end = splitPoint - 1
-		tr.state = newSt
+		newSt := t.newState()
+		t.addTran(newSt, splitPoint, t.trans[tr].end, t.trans[tr].state)
+		t.trans[tr].end = splitPoint - 1
+		t.trans[tr].state = newSt
 		return newSt, false
 	}
-	if s == t.auxState || s.findTran(c) != nil {
+	if s == t.auxState || t.findTran(s, c) != noTran {
 		return s, true
 	}
 	return s, false
@@ -106,7 +136,7 @@ func (t *STree) testAndSplit(s *state, start, end Pos) (exs *state, endPoint boo
 // canonize returns updated state and start position for ref. pair
 // (s, (start, end)) of state r so the new ref. pair is canonical,
 // that is, referenced from the closest explicit ancestor of r.
-func (t *STree) canonize(s *state, start, end Pos) (*state, Pos) {
+func (t *STree) canonize(s stateID, start, end Pos) (stateID, Pos) {
 	if s == t.auxState {
 		s, start = t.root, start+1
 	}
@@ -117,11 +147,12 @@ func (t *STree) canonize(s *state, start, end Pos) (*state, Pos) {
 	var tr *tran
 	for {
 		if start <= end {
-			tr = s.findTran(t.data[start])
-			if tr == nil {
+			i := t.findTran(s, t.data[start])
+			if i == noTran {
 				panic(fmt.Sprintf("there should be some transition for '%d' at %d",
-					t.data[start].Val(), start))
+					t.data[start], start))
 			}
+			tr = &t.trans[i]
 		}
 		if tr.end-tr.start > end-start {
 			break
@@ -129,88 +160,107 @@ func (t *STree) canonize(s *state, start, end Pos) (*state, Pos) {
 		start += tr.end - tr.start + 1
 		s = tr.state
 	}
-	if s == nil {
+	if s == noState {
 		panic("there should always be some suffix link resolution")
 	}
 	return s, start
 }
 
+// At returns the token at the position p. The tree keeps only the
+// values of the tokens, so the token has the value of the one given to
+// Update, but not its type; the callers needing the tokens themselves
+// keep them in a slice in step with the positions.
 func (t *STree) At(p Pos) Token {
 	if p < 0 || p >= Pos(len(t.data)) {
 		panic("position out of bounds")
 	}
-	return t.data[p]
+	return value(t.data[p])
 }
 
 func (t *STree) String() string {
 	buf := new(bytes.Buffer)
-	printState(buf, t.root, 0)
+	t.printState(buf, t.root, 0)
 	return buf.String()
 }
 
-func printState(buf *bytes.Buffer, s *state, ident int) {
-	for _, tr := range s.trans {
+func (t *STree) printState(buf *bytes.Buffer, s stateID, ident int) {
+	for i := t.states[s].first; i != noTran; i = t.trans[i].next {
+		tr := t.trans[i]
 		fmt.Fprint(buf, strings.Repeat("  ", ident))
-		fmt.Fprintf(buf, "* (%d, %d)\n", tr.start, tr.ActEnd())
-		printState(buf, tr.state, ident+1)
+		fmt.Fprintf(buf, "* (%d, %d)\n", tr.start, t.actEnd(tr))
+		t.printState(buf, tr.state, ident+1)
 	}
 }
 
-// state is an explicit state of the suffix tree.
+// stateID is the index of a state in the states of the tree.
+type stateID int32
+
+// noState is the suffix link of the states without one.
+const noState stateID = -1
+
+// tranID is the index of a transition in the transitions of the tree.
+type tranID int32
+
+// noTran ends the list of the transitions of a state.
+const noTran tranID = -1
+
+// state is an explicit state of the suffix tree. Its transitions
+// form a list, in the order they were added in.
 type state struct {
-	tree      *STree
-	trans     []*tran
-	linkState *state
+	first, last tranID
+	linkState   stateID
 }
 
-func newState(t *STree) *state {
-	return &state{
-		tree:      t,
-		trans:     make([]*tran, 0),
-		linkState: nil,
-	}
+func (t *STree) newState() stateID {
+	t.states = append(t.states, state{first: noTran, last: noTran, linkState: noState})
+	return stateID(len(t.states) - 1)
 }
 
-func (s *state) addTran(start, end Pos, r *state) {
-	s.trans = append(s.trans, newTran(start, end, r))
+func (t *STree) addTran(s stateID, start, end Pos, r stateID) {
+	i := tranID(len(t.trans))
+	t.trans = append(t.trans, tran{start: start, end: end, state: r, next: noTran})
+	st := &t.states[s]
+	if st.last == noTran {
+		st.first = i
+	} else {
+		t.trans[st.last].next = i
+	}
+	st.last = i
 }
 
 // fork creates a new branch from the state s.
-func (s *state) fork(i Pos) *state {
-	r := newState(s.tree)
-	s.addTran(i, infinity, r)
+func (t *STree) fork(s stateID, i Pos) stateID {
+	r := t.newState()
+	t.addTran(s, i, infinity, r)
 	return r
 }
 
-// findTran finds c-transition.
-func (s *state) findTran(c Token) *tran {
-	for _, tran := range s.trans {
-		if s.tree.data[tran.start].Val() == c.Val() {
-			return tran
+// findTran finds the c-transition of the state s.
+func (t *STree) findTran(s stateID, c int) tranID {
+	for i := t.states[s].first; i != noTran; i = t.trans[i].next {
+		if t.data[t.trans[i].start] == c {
+			return i
 		}
 	}
-	return nil
+	return noTran
 }
 
 // tran represents a state's transition.
 type tran struct {
 	start, end Pos
-	state      *state
-}
-
-func newTran(start, end Pos, s *state) *tran {
-	return &tran{start, end, s}
+	state      stateID
+	next       tranID // of the same state
 }
 
 func (t *tran) len() int {
 	return int(t.end - t.start + 1)
 }
 
-// ActEnd returns actual end position as consistent with
-// the actual length of the data in the STree.
-func (t *tran) ActEnd() Pos {
-	if t.end == infinity {
-		return Pos(len(t.state.tree.data)) - 1
+// actEnd returns actual end position of the transition as consistent
+// with the actual length of the data in the STree.
+func (t *STree) actEnd(tr tran) Pos {
+	if tr.end == infinity {
+		return Pos(len(t.data)) - 1
 	}
-	return t.end
+	return tr.end
 }
@@ -1,6 +1,10 @@
 package suffixtree
 
-import "testing"
+import (
+	"math/rand"
+	"runtime"
+	"testing"
+)
 
 type char byte
 
@@ -16,59 +20,67 @@ func str2tok(str string) []Token {
 	return toks
 }
 
+func str2vals(str string) []int {
+	vals := make([]int, len(str))
+	for i, c := range str {
+		vals[i] = int(c)
+	}
+	return vals
+}
+
 func TestConstruction(t *testing.T) {
 	str := "cacao"
-	_, s := genStates(8, str)
+	e, s := genStates(8, str)
 	// s[0] is root
-	s[0].addTran(0, 1, s[1]) // ca
-	s[0].addTran(1, 1, s[2]) // a
-	s[0].addTran(4, 4, s[3]) // o
+	e.addTran(s[0], 0, 1, s[1]) // ca
+	e.addTran(s[0], 1, 1, s[2]) // a
+	e.addTran(s[0], 4, 4, s[3]) // o
 
-	s[1].addTran(2, 4, s[4]) // cao
-	s[1].addTran(4, 4, s[5]) // o
+	e.addTran(s[1], 2, 4, s[4]) // cao
+	e.addTran(s[1], 4, 4, s[5]) // o
 
-	s[2].addTran(2, 4, s[4]) // cao
-	s[2].addTran(4, 4, s[5]) // o
+	e.addTran(s[2], 2, 4, s[4]) // cao
+	e.addTran(s[2], 4, 4, s[5]) // o
 
 	cacao := New()
 	cacao.Update(str2tok(str)...)
-	compareTrees(t, s[0], cacao.root)
+	compareTrees(t, e, s[0], cacao, cacao.root)
 
 	str2 := "banana"
-	_, r := genStates(4, str2)
-	r[0].addTran(0, 5, r[1]) // banana
-	r[0].addTran(1, 5, r[2]) // anana
-	r[0].addTran(2, 5, r[3]) // nana
+	e, r := genStates(4, str2)
+	e.addTran(r[0], 0, 5, r[1]) // banana
+	e.addTran(r[0], 1, 5, r[2]) // anana
+	e.addTran(r[0], 2, 5, r[3]) // nana
 
 	banana := New()
 	banana.Update(str2tok(str2)...)
-	compareTrees(t, r[0], banana.root)
+	compareTrees(t, e, r[0], banana, banana.root)
 
-	_, q := genStates(11, str2+"$")
+	e, q := genStates(11, str2+"$")
 	// r[0] is root
-	q[0].addTran(0, 6, q[1]) // banana$
-	q[0].addTran(1, 1, q[2]) // a
-	q[0].addTran(2, 3, q[3]) // na
-	q[0].addTran(6, 6, q[4]) // $
+	e.addTran(q[0], 0, 6, q[1]) // banana$
+	e.addTran(q[0], 1, 1, q[2]) // a
+	e.addTran(q[0], 2, 3, q[3]) // na
+	e.addTran(q[0], 6, 6, q[4]) // $
 
-	q[2].addTran(2, 3, q[5]) // na
-	q[2].addTran(6, 6, q[6]) // $
+	e.addTran(q[2], 2, 3, q[5]) // na
+	e.addTran(q[2], 6, 6, q[6]) // $
 
-	q[3].addTran(4, 6, q[7]) // na$
-	q[3].addTran(6, 6, q[8]) // $
+	e.addTran(q[3], 4, 6, q[7]) // na$
+	e.addTran(q[3], 6, 6, q[8]) // $
 
-	q[5].addTran(4, 6, q[9])  // na$
-	q[5].addTran(6, 6, q[10]) // $
+	e.addTran(q[5], 4, 6, q[9])  // na$
+	e.addTran(q[5], 6, 6, q[10]) // $
 
 	banana.Update(char('$'))
-	compareTrees(t, q[0], banana.root)
+	compareTrees(t, e, q[0], banana, banana.root)
 
 	foo := New()
 	foo.Update(str2tok("a b ac c ")...)
 }
 
-func compareTrees(t *testing.T, expected, actual *state) {
-	ch1, ch2 := walker(expected), walker(actual)
+func compareTrees(t *testing.T, et *STree, expected stateID, at *STree, actual stateID) {
+	ch1, ch2 := walker(et, expected), walker(at, actual)
 	for {
 		etran, ok1 := <-ch1
 		atran, ok2 := <-ch2
@@ -80,61 +92,60 @@ func compareTrees(t *testing.T, expected, actual *state) {
 			}
 			break
 		}
-		if etran.start != atran.start || etran.ActEnd() != atran.ActEnd() {
+		if etran.start != atran.start || et.actEnd(etran) != at.actEnd(atran) {
 			t.Errorf("got transition (%d, %d) '%s', want (%d, %d) '%s'",
-				atran.start, atran.ActEnd(), actual.tree.data[atran.start:atran.ActEnd()+1],
-				etran.start, etran.ActEnd(), expected.tree.data[etran.start:etran.ActEnd()+1],
+				atran.start, at.actEnd(atran), vals2str(at.data[atran.start:at.actEnd(atran)+1]),
+				etran.start, et.actEnd(etran), vals2str(et.data[etran.start:et.actEnd(etran)+1]),
 			)
 		}
 	}
 }
 
-func walker(s *state) <-chan *tran {
-	ch := make(chan *tran)
+func vals2str(vals []int) string {
+	b := make([]byte, len(vals))
+	for i, v := range vals {
+		b[i] = byte(v)
+	}
+	return string(b)
+}
+
+func walker(t *STree, s stateID) <-chan tran {
+	ch := make(chan tran)
 	go func() {
-		walk(s, ch)
+		walk(t, s, ch)
 		close(ch)
 	}()
 	return ch
 }
 
-func walk(s *state, ch chan<- *tran) {
-	for _, tr := range s.trans {
-		ch <- tr
-		walk(tr.state, ch)
+func walk(t *STree, s stateID, ch chan<- tran) {
+	for i := t.states[s].first; i != noTran; i = t.trans[i].next {
+		ch <- t.trans[i]
+		walk(t, t.trans[i].state, ch)
 	}
 }
 
-func genStates(count int, data string) (*STree, []*state) {
+func genStates(count int, data string) (*STree, []stateID) {
 	t := new(STree)
-	t.data = str2tok(data)
-	states := make([]*state, count)
+	t.data = str2vals(data)
+	states := make([]stateID, count)
 	for i := range states {
-		states[i] = newState(t)
+		states[i] = t.newState()
 	}
 	return t, states
 }
 
 type refPair struct {
-	s          *state
+	s          stateID
 	start, end Pos
 }
 
 func TestCanonize(t *testing.T) {
 	tree, s := genStates(5, "somebanana")
 	tree.auxState, tree.root = s[4], s[0]
-	s[0].addTran(0, 3, s[1])
-	s[1].addTran(4, 6, s[2])
-	s[2].addTran(7, infinity, s[3])
-
-	find := func(needle *state) int {
-		for i, state := range s {
-			if state == needle {
-				return i
-			}
-		}
-		return -1
-	}
+	tree.addTran(s[0], 0, 3, s[1])
+	tree.addTran(s[1], 4, 6, s[2])
+	tree.addTran(s[2], 7, infinity, s[3])
 
 	var testCases = []struct {
 		origin, expected refPair
@@ -152,9 +163,9 @@ func TestCanonize(t *testing.T) {
 		s, start := tree.canonize(tc.origin.s, tc.origin.start, tc.origin.end)
 		if s != tc.expected.s || start != tc.expected.start {
 			t.Errorf("for origin ref. pair (%d, (%d, %d)) got (%d, %d), want (%d, %d)",
-				find(tc.origin.s), tc.origin.start, tc.origin.end,
-				find(s), start,
-				find(tc.expected.s), tc.expected.start,
+				tc.origin.s, tc.origin.start, tc.origin.end,
+				s, start,
+				tc.expected.s, tc.expected.start,
 			)
 		}
 	}
@@ -162,16 +173,17 @@ func TestCanonize(t *testing.T) {
 
 func TestSplitting(t *testing.T) {
 	tree := new(STree)
-	tree.data = str2tok("banana|cbao")
-	s1 := newState(tree)
-	s2 := newState(tree)
-	s1.addTran(0, 3, s2)
+	tree.data = str2vals("banana|cbao")
+	tree.auxState = tree.newState() // not to be taken for s1
+	s1 := tree.newState()
+	s2 := tree.newState()
+	tree.addTran(s1, 0, 3, s2)
 
 	// active point is (s1, 0, -1), an explicit state
 	tree.end = 7 // c
 	rets, end := tree.testAndSplit(s1, 0, -1)
 	if rets != s1 {
-		t.Errorf("got state %p, want %p", rets, s1)
+		t.Errorf("got state %d, want %d", rets, s1)
 	}
 	if end {
 		t.Error("should not be an end-point")
@@ -195,17 +207,17 @@ func TestSplitting(t *testing.T) {
 	// [s1]-banana->[s2] => [s1]-ban->[rets]-ana->[s2]
 	tree.end = 10 // o
 	rets, end = tree.testAndSplit(s1, 0, 2)
-	tr := s1.findTran(char('b'))
-	if tr == nil {
+	tr := tree.findTran(s1, 'b')
+	if tr == noTran {
 		t.Error("should have a b-transition")
-	} else if tr.state != rets {
-		t.Errorf("got state %p, want %p", tr.state, rets)
+	} else if tree.trans[tr].state != rets {
+		t.Errorf("got state %d, want %d", tree.trans[tr].state, rets)
 	}
-	tr2 := rets.findTran(char('a'))
-	if tr2 == nil {
+	tr2 := tree.findTran(rets, 'a')
+	if tr2 == noTran {
 		t.Error("should have an a-transition")
-	} else if tr2.state != s2 {
-		t.Errorf("got state %p, want %p", tr2.state, s2)
+	} else if tree.trans[tr2].state != s2 {
+		t.Errorf("got state %d, want %d", tree.trans[tr2].state, s2)
 	}
 	if end {
 		t.Error("should not be an end-point")
@@ -229,3 +241,52 @@ all work and no play makes jack a dull boy`)
 		t.Update(stream...)
 	}
 }
+
+// largeStream returns a stream of n tokens of a small alphabet with
+// long repeated runs, like the serialized syntax trees of a big corpus.
+func largeStream(n int) []Token {
+	rnd := rand.New(rand.NewSource(1))
+	stream := make([]Token, 0, n)
+	var runs [][]Token
+	for len(stream) < n {
+		if len(runs) > 0 && rnd.Intn(3) == 0 {
+			stream = append(stream, runs[rnd.Intn(len(runs))]...)
+			continue
+		}
+		run := make([]Token, 20+rnd.Intn(200))
+		for i := range run {
+			run[i] = char('a' + rnd.Intn(40))
+		}
+		runs = append(runs, run)
+		stream = append(stream, run...)
+	}
+	return append(stream[:n-1], char('$'))
+}
+
+func BenchmarkConstructionLarge(b *testing.B) {
+	stream := largeStream(1 << 20)
+	b.ReportAllocs()
+	b.ResetTimer()
+	var heap uint64
+	for i := 0; i < b.N; i++ {
+		t := New()
+		t.Update(stream...)
+		runtime.GC()
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		heap = ms.HeapAlloc
+		runtime.KeepAlive(t)
+	}
+	b.ReportMetric(float64(heap), "heap-B")
+}
+
+func BenchmarkFindDuplLarge(b *testing.B) {
+	t := New()
+	t.Update(largeStream(1 << 20)...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for range t.FindDuplOver(50) {
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+
+	"github.com/mibk/dupl/printer"
+	"github.com/mibk/dupl/syntax"
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+// caseBodies reports whether every fragment of the group is the whole
+// body of a case clause. If so, each fragment is annotated with the case
+// expressions of its clause.
+func caseBodies(g *printer.Group) (bool, error) {
+	for i := range g.Frags {
+		frag := &g.Frags[i]
+		clause := caseClauseOf(frag.Nodes)
+		if clause == nil {
+			return false, nil
+		}
+		file, err := readFile(frag.Filename)
+		if err != nil {
+			return false, err
+		}
+		head := bytes.TrimSpace(file[clause.Pos:frag.Pos])
+		frag.Context = string(bytes.Join(bytes.Fields(head), []byte(" ")))
+	}
+	return true, nil
+}
+
+// caseClauseOf returns the case clause whose body consists exactly
+// of the nodes, or nil if there is no such clause.
+func caseClauseOf(nodes []*syntax.Node) *syntax.Node {
+	clause := nodes[0].Parent
+	if clause == nil || clause.Type != golang.CaseClause {
+		return nil
+	}
+	children := clause.Children
+	if children[len(children)-1] != nodes[len(nodes)-1] {
+		return nil
+	}
+	for i, c := range children {
+		if c == nodes[0] {
+			if i > 0 && golang.IsStmt(children[i-1].Type) {
+				// not the first statement of the body
+				return nil
+			}
+			return clause
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package golang
+
+import (
+	"go/ast"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+// funcBodies transforms the declarations of the file, but returns only
+// the bodies of the functions and methods, and of the function literals
+// out of them, like those assigned to package-level variables. The
+// bodies keep their parents in the tree of the whole file, which is not
+// otherwise used, so that the functions enclosing them are known.
+func (t *transformer) funcBodies(file *ast.File) []*syntax.Node {
+	whole := t.leaf(file, File)
+	for _, decl := range file.Decls {
+		whole.AddChildren(t.trans(decl))
+	}
+	var bodies []*syntax.Node
+	var find func(n *syntax.Node)
+	find = func(n *syntax.Node) {
+		for _, c := range n.Children {
+			if c.Type != FuncDecl && c.Type != FuncLit {
+				find(c)
+				continue
+			}
+			// The function literals in the body are in it.
+			if body := c.Children[len(c.Children)-1]; body.Type == BlockStmt {
+				bodies = append(bodies, body)
+			}
+		}
+	}
+	find(whole)
+	return bodies
+}
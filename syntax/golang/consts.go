@@ -0,0 +1,32 @@
+package golang
+
+import (
+	"go/ast"
+	"go/token"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+// constBlocks transforms the package-level const declarations of the
+// file. Only the names of the constants are kept, all significant, so
+// that the same set of constants matches whatever the values.
+func (t *transformer) constBlocks(file *ast.File) []*syntax.Node {
+	var blocks []*syntax.Node
+	for _, decl := range file.Decls {
+		decl, ok := decl.(*ast.GenDecl)
+		if !ok || decl.Tok != token.CONST {
+			continue
+		}
+		o := t.leaf(decl, GenDecl)
+		for _, spec := range decl.Specs {
+			spec := spec.(*ast.ValueSpec)
+			s := t.leaf(spec, ValueSpec)
+			for _, name := range spec.Names {
+				s.AddChildren(t.named(name))
+			}
+			o.AddChildren(s)
+		}
+		blocks = append(blocks, o)
+	}
+	return blocks
+}
@@ -0,0 +1,49 @@
+package golang
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConstBlocks(t *testing.T) {
+	opts := Options{ConstBlocks: true}
+	testCases := []struct {
+		a, b string
+		same bool
+	}{
+		{"const (\n\tA = 1\n\tB = 2\n)", "const (\n\tA = \"a\"\n\tB = iota\n)", true},
+		{"const (\n\tA int = 1\n\tB\n)", "const (\n\tA = 1\n\tB = 2\n)", true},
+		{"const (\n\tA = 1\n\tB = 2\n)", "const (\n\tA = 1\n\tC = 2\n)", false},
+		{"const (\n\tA = 1\n\tB = 2\n)", "const (\n\tB = 1\n\tA = 2\n)", false},
+		{"const A, B = 1, 2", "const (\n\tA = 1\n\tB = 2\n)", false},
+	}
+	for _, tc := range testCases {
+		a, b := shape(t, tc.a, opts), shape(t, tc.b, opts)
+		if same := reflect.DeepEqual(a, b); same != tc.same {
+			t.Errorf("%q and %q: got the same %v, want %v", tc.a, tc.b, same, tc.same)
+		}
+	}
+}
+
+func TestConstBlocksOnly(t *testing.T) {
+	src := "package p\n\nimport \"fmt\"\n\nconst A = 1\n\nvar v = 2\n\ntype T int\n\n" +
+		"func f() {\n\tconst local = 3\n\tfmt.Println(local)\n}\n\nconst (\n\tB = 4\n\tC = 5\n)\n"
+	root, err := Parse("a.go", []byte(src), Options{ConstBlocks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, decl := range root.Children {
+		if decl.Type != GenDecl {
+			t.Errorf("got a declaration of type %d, want only const blocks", decl.Type)
+		}
+		for _, spec := range decl.Children {
+			for _, name := range spec.Children {
+				names = append(names, src[name.Pos:name.End])
+			}
+		}
+	}
+	if want := []string{"A", "B", "C"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got the constants %q, want %q", names, want)
+	}
+}
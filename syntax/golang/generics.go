@@ -0,0 +1,101 @@
+package golang
+
+import (
+	"go/ast"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+// predeclaredTypes are the names of the predeclared types, which are
+// recognized as type arguments without any type information.
+var predeclaredTypes = map[string]bool{
+	"any": true, "bool": true, "byte": true, "comparable": true,
+	"complex64": true, "complex128": true, "error": true,
+	"float32": true, "float64": true, "int": true, "int8": true,
+	"int16": true, "int32": true, "int64": true, "rune": true,
+	"string": true, "uint": true, "uint8": true, "uint16": true,
+	"uint32": true, "uint64": true, "uintptr": true,
+}
+
+// leaf returns a node of the type typ without children spanning node.
+func (t *transformer) leaf(node ast.Node, typ int) *syntax.Node {
+	o := syntax.NewNode()
+	o.Type = typ
+	o.Filename = t.filename
+	st, end := node.Pos(), node.End()
+	o.Pos, o.End = t.fileset.File(st).Offset(st), t.fileset.File(end).Offset(end)
+	return o
+}
+
+// callee transforms the function called. If generics are taken into
+// account, the type arguments of an instantiated generic function
+// are dropped, so that Max[int](a, b) matches MaxInt(a, b).
+func (t *transformer) callee(fun ast.Expr) *syntax.Node {
+	if t.opts.GenericsAware {
+		switch f := fun.(type) {
+		case *ast.IndexListExpr:
+			return t.trans(f.X)
+		case *ast.IndexExpr:
+			if t.isType(f.Index) {
+				return t.trans(f.X)
+			}
+		}
+	}
+	return t.trans(fun)
+}
+
+// isType reports whether the expression is a type for sure: a type
+// literal, a predeclared type, or a type parameter in scope. Other
+// identifiers may be values, e.g. the key of s[key](x).
+func (t *transformer) isType(e ast.Expr) bool {
+	switch e := e.(type) {
+	case *ast.Ident:
+		return predeclaredTypes[e.Name] || t.typeParams[e.Name]
+	case *ast.ArrayType, *ast.ChanType, *ast.FuncType, *ast.InterfaceType,
+		*ast.MapType, *ast.StructType:
+		return true
+	}
+	return false
+}
+
+// declTypeParams returns the names of the type parameters of the
+// function or method declaration.
+func declTypeParams(decl *ast.FuncDecl) map[string]bool {
+	names := fieldNames(decl.Type.TypeParams)
+	if decl.Recv == nil || len(decl.Recv.List) == 0 {
+		return names
+	}
+	// The receiver of a method of a generic type, like *Stack[T] or
+	// Map[K, V], names the type parameters.
+	recv := decl.Recv.List[0].Type
+	if star, ok := recv.(*ast.StarExpr); ok {
+		recv = star.X
+	}
+	var params []ast.Expr
+	switch r := recv.(type) {
+	case *ast.IndexExpr:
+		params = []ast.Expr{r.Index}
+	case *ast.IndexListExpr:
+		params = r.Indices
+	}
+	for _, p := range params {
+		if id, ok := p.(*ast.Ident); ok {
+			names[id.Name] = true
+		}
+	}
+	return names
+}
+
+// fieldNames returns the names of the fields of the list, like those of
+// the type parameters of a generic type.
+func fieldNames(list *ast.FieldList) map[string]bool {
+	names := make(map[string]bool)
+	if list != nil {
+		for _, field := range list.List {
+			for _, name := range field.Names {
+				names[name.Name] = true
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,91 @@
+package golang
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+// shape returns the types of the nodes of the first declaration of the
+// source, in the order they are hashed in.
+func shape(t *testing.T, src string, opts Options) []int {
+	t.Helper()
+	root, err := Parse("a.go", []byte("package p\n\n"+src), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(root.Children) == 0 {
+		t.Fatalf("no declarations in %q", src)
+	}
+	var types []int
+	for _, n := range syntax.Serialize(root.Children[0]) {
+		types = append(types, n.Type)
+	}
+	return types
+}
+
+func TestGenericsAware(t *testing.T) {
+	testCases := []struct {
+		a, b string
+		opts Options
+		same bool
+	}{
+		{
+			"func F[T any](x T) T { return x }",
+			"func F[U any](x U) U { return x }",
+			Options{Normalize: NormalizeNone, GenericsAware: true},
+			true,
+		},
+		{
+			"func F[T any](x T) T { return x }",
+			"func F[U any](x U) U { return x }",
+			Options{Normalize: NormalizeNone},
+			false,
+		},
+		{
+			"func f() { Max[int](a, b) }",
+			"func f() { Max(a, b) }",
+			Options{GenericsAware: true},
+			true,
+		},
+		{
+			"func f() { Max[int](a, b) }",
+			"func f() { Max(a, b) }",
+			Options{},
+			false,
+		},
+		{
+			// s[key] may be a function value of a slice or a map.
+			"func f() { s[key](a) }",
+			"func f() { s(a) }",
+			Options{GenericsAware: true},
+			false,
+		},
+		{
+			// Only the type parameters are normalized, not other types.
+			"func F[T any](x T) T { return x }",
+			"func F(x []byte) []byte { return x }",
+			Options{GenericsAware: true},
+			false,
+		},
+		{
+			"func f(x []int) {}",
+			"func f(x map[string]int) {}",
+			Options{GenericsAware: true},
+			false,
+		},
+		{
+			"type Stack[T any] struct{ items []T }",
+			"type Stack[E any] struct{ items []E }",
+			Options{Normalize: NormalizeNone, GenericsAware: true},
+			true,
+		},
+	}
+	for _, tc := range testCases {
+		a, b := shape(t, tc.a, tc.opts), shape(t, tc.b, tc.opts)
+		if same := reflect.DeepEqual(a, b); same != tc.same {
+			t.Errorf("%q and %q with %+v: got same %v, want %v", tc.a, tc.b, tc.opts, same, tc.same)
+		}
+	}
+}
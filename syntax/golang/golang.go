@@ -60,16 +60,78 @@ const (
 	ValueSpec
 )
 
-// Parse the given file and return uniform syntax tree.
-func Parse(filename string) (*syntax.Node, error) {
+// IsStmt reports whether the node type is a statement.
+func IsStmt(typ int) bool {
+	switch typ {
+	case AssignStmt, BlockStmt, BranchStmt, DeclStmt, DeferStmt, EmptyStmt,
+		ExprStmt, ForStmt, GoStmt, IfStmt, IncDecStmt, LabeledStmt, RangeStmt,
+		ReturnStmt, SelectStmt, SendStmt, SwitchStmt, TypeSwitchStmt:
+		return true
+	}
+	return false
+}
+
+// Options alter the uniform syntax tree built from a file.
+type Options struct {
+	// Tables restricts the tree to composite literals that are elements
+	// of other composite literals, like the rows of a table. Identifiers
+	// and basic literals in them are all considered the same.
+	Tables bool
+
+	// NormalizeReceivers considers all method receivers the same,
+	// whatever their names and types, e.g. (s *Set) and (l List).
+	NormalizeReceivers bool
+
+	// ReorderTolerant ignores the order of adjacent statements that
+	// are independent of each other.
+	ReorderTolerant bool
+
+	// GenericsAware considers all type parameters the same as the
+	// identifiers normalized by the Normalization, whatever their
+	// names, and drops the type arguments of instantiations.
+	GenericsAware bool
+
+	// Normalize is the granularity of the normalization of identifiers.
+	Normalize Normalization
+
+	// ConstBlocks restricts the tree to the package-level const
+	// declarations, keeping only the names of the constants, which
+	// are significant.
+	ConstBlocks bool
+
+	// BodiesOnly restricts the tree to the bodies of the functions,
+	// leaving out the declarations, like those of types and variables,
+	// and the signatures of the functions.
+	BodiesOnly bool
+
+	// Blank is the treatment of the blank identifier.
+	Blank BlankMode
+
+	// GoVersion is the minor version of the Go release whose syntax
+	// the files must conform to, e.g. 17 for Go 1.17, or 0 for the
+	// latest. Parse fails on the files using newer syntax.
+	GoVersion int
+}
+
+// Parse the source of the given file and return uniform syntax tree.
+func Parse(filename string, src []byte, opts Options) (*syntax.Node, error) {
 	fset := token.NewFileSet()
-	file, err := parser.ParseFile(fset, filename, nil, 0)
+	file, err := parser.ParseFile(fset, filename, src, 0)
 	if err != nil {
 		return nil, err
 	}
+	if opts.GoVersion > 0 {
+		if err := checkVersion(fset, file, opts.GoVersion); err != nil {
+			return nil, err
+		}
+	}
 	t := &transformer{
 		fileset:  fset,
 		filename: filename,
+		opts:     opts,
+	}
+	if opts.Normalize == NormalizeTypes {
+		t.typeNames = typeNames(file)
 	}
 	return t.trans(file), nil
 }
@@ -77,6 +139,37 @@ func Parse(filename string) (*syntax.Node, error) {
 type transformer struct {
 	fileset  *token.FileSet
 	filename string
+	opts     Options
+
+	// typeParams are the names of the type parameters in scope.
+	typeParams map[string]bool
+
+	// typeNames are the identifiers naming types, under NormalizeTypes.
+	typeNames map[*ast.Ident]bool
+}
+
+// tableRows transforms all composite literals that are elements
+// of other composite literals found in node.
+func (t *transformer) tableRows(node ast.Node) []*syntax.Node {
+	var rows []*syntax.Node
+	ast.Inspect(node, func(n ast.Node) bool {
+		lit, ok := n.(*ast.CompositeLit)
+		if !ok {
+			return true
+		}
+		found := false
+		for _, e := range lit.Elts {
+			if kv, ok := e.(*ast.KeyValueExpr); ok {
+				e = kv.Value
+			}
+			if _, ok := e.(*ast.CompositeLit); ok {
+				rows = append(rows, t.trans(e))
+				found = true
+			}
+		}
+		return !found
+	})
+	return rows
 }
 
 // trans transforms given golang AST to uniform tree structure.
@@ -113,9 +206,7 @@ func (t *transformer) trans(node ast.Node) (o *syntax.Node) {
 
 	case *ast.BlockStmt:
 		o.Type = BlockStmt
-		for _, stmt := range n.List {
-			o.AddChildren(t.trans(stmt))
-		}
+		o.AddChildren(t.stmts(n.List)...)
 
 	case *ast.BranchStmt:
 		o.Type = BranchStmt
@@ -125,7 +216,7 @@ func (t *transformer) trans(node ast.Node) (o *syntax.Node) {
 
 	case *ast.CallExpr:
 		o.Type = CallExpr
-		o.AddChildren(t.trans(n.Fun))
+		o.AddChildren(t.callee(n.Fun))
 		for _, arg := range n.Args {
 			o.AddChildren(t.trans(arg))
 		}
@@ -135,9 +226,7 @@ func (t *transformer) trans(node ast.Node) (o *syntax.Node) {
 		for _, e := range n.List {
 			o.AddChildren(t.trans(e))
 		}
-		for _, stmt := range n.Body {
-			o.AddChildren(t.trans(stmt))
-		}
+		o.AddChildren(t.stmts(n.Body)...)
 
 	case *ast.ChanType:
 		o.Type = ChanType
@@ -148,9 +237,7 @@ func (t *transformer) trans(node ast.Node) (o *syntax.Node) {
 		if n.Comm != nil {
 			o.AddChildren(t.trans(n.Comm))
 		}
-		for _, stmt := range n.Body {
-			o.AddChildren(t.trans(stmt))
-		}
+		o.AddChildren(t.stmts(n.Body)...)
 
 	case *ast.CompositeLit:
 		o.Type = CompositeLit
@@ -197,6 +284,19 @@ func (t *transformer) trans(node ast.Node) (o *syntax.Node) {
 
 	case *ast.File:
 		o.Type = File
+		if t.opts.Tables {
+			o.AddChildren(t.tableRows(n)...)
+			break
+		}
+		if t.opts.ConstBlocks {
+			o.AddChildren(t.constBlocks(n)...)
+			break
+		}
+		if t.opts.BodiesOnly {
+			// The bodies stay the children of their functions.
+			o.Children = t.funcBodies(n)
+			break
+		}
 		for _, decl := range n.Decls {
 			if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
 				// skip import declarations
@@ -220,8 +320,20 @@ func (t *transformer) trans(node ast.Node) (o *syntax.Node) {
 
 	case *ast.FuncDecl:
 		o.Type = FuncDecl
+		if t.opts.GenericsAware {
+			t.typeParams = declTypeParams(n)
+			defer func() { t.typeParams = nil }()
+		}
 		if n.Recv != nil {
-			o.AddChildren(t.trans(n.Recv))
+			if t.opts.NormalizeReceivers {
+				recv := syntax.NewNode()
+				recv.Type = FieldList
+				recv.Filename = t.filename
+				recv.Pos, recv.End = t.fileset.File(n.Recv.Pos()).Offset(n.Recv.Pos()), t.fileset.File(n.Recv.End()).Offset(n.Recv.End())
+				o.AddChildren(recv)
+			} else {
+				o.AddChildren(t.trans(n.Recv))
+			}
 		}
 		o.AddChildren(t.trans(n.Name), t.trans(n.Type))
 		if n.Body != nil {
@@ -251,6 +363,14 @@ func (t *transformer) trans(node ast.Node) (o *syntax.Node) {
 
 	case *ast.Ident:
 		o.Type = Ident
+		if t.opts.Tables {
+			o.Type = BasicLit
+		} else if (t.opts.Normalize == NormalizeNone || t.typeNames[n]) && !t.typeParams[n.Name] {
+			o.Type = identType(n.Name)
+		}
+		if n.Name == "_" && !t.opts.Tables {
+			t.blank(o)
+		}
 
 	case *ast.IfStmt:
 		o.Type = IfStmt
@@ -312,7 +432,7 @@ func (t *transformer) trans(node ast.Node) (o *syntax.Node) {
 
 	case *ast.SelectorExpr:
 		o.Type = SelectorExpr
-		o.AddChildren(t.trans(n.X), t.trans(n.Sel))
+		o.AddChildren(t.selector(n))
 
 	case *ast.SendStmt:
 		o.Type = SendStmt
@@ -358,6 +478,10 @@ func (t *transformer) trans(node ast.Node) (o *syntax.Node) {
 
 	case *ast.TypeSpec:
 		o.Type = TypeSpec
+		if t.opts.GenericsAware {
+			t.typeParams = fieldNames(n.TypeParams)
+			defer func() { t.typeParams = nil }()
+		}
 		o.AddChildren(t.trans(n.Name), t.trans(n.Type))
 
 	case *ast.TypeSwitchStmt:
@@ -0,0 +1,162 @@
+package golang
+
+import (
+	"go/ast"
+	"hash/fnv"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+// Normalization is the granularity of the normalization of identifiers.
+type Normalization int
+
+const (
+	// NormalizeAll considers all identifiers the same.
+	NormalizeAll Normalization = iota
+
+	// NormalizeLocals keeps the selected names, like Println in
+	// fmt.Println or name in s.name, significant, and the package
+	// names qualifying them, while it considers the names declared
+	// in the file, like local variables, the same.
+	NormalizeLocals
+
+	// NormalizeTypes keeps the names of types significant too, like
+	// Config in var c Config or &Config{}, in addition to the names
+	// NormalizeLocals keeps, while it considers the other names
+	// declared in the file, like local variables and functions,
+	// the same.
+	NormalizeTypes
+
+	// NormalizeNone keeps all identifiers significant.
+	NormalizeNone
+)
+
+// BlankMode is the treatment of the blank identifier _.
+type BlankMode int
+
+const (
+	// BlankDefault treats _ like the other identifiers: it is the same
+	// as any other identifier normalized by the Normalization.
+	BlankDefault BlankMode = iota
+
+	// BlankSignificant keeps _ significant, so that _, err := f()
+	// does not match x, err := f().
+	BlankSignificant
+
+	// BlankIgnore considers _ the same as the normalized identifiers
+	// even if the Normalization keeps all identifiers significant.
+	BlankIgnore
+)
+
+// blank sets the type of the node of the blank identifier.
+func (t *transformer) blank(o *syntax.Node) {
+	switch t.opts.Blank {
+	case BlankSignificant:
+		o.Type = identType("_")
+	case BlankIgnore:
+		o.Type = Ident
+	}
+}
+
+// identTypes is the first of the node types of significant identifiers,
+// from a range well above the other node types.
+const identTypes = 1 << 16
+
+// identType returns the node type of the significant identifier name.
+func identType(name string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return identTypes + int(h.Sum32()>>8)
+}
+
+// IsIdent reports whether the node type is an identifier,
+// significant or not.
+func IsIdent(typ int) bool {
+	return typ == Ident || typ >= identTypes
+}
+
+// named transforms the identifier so that it only matches
+// identifiers of the same name, unless all identifiers and literals
+// are considered the same in tables.
+func (t *transformer) named(id *ast.Ident) *syntax.Node {
+	o := t.trans(id)
+	if !t.opts.Tables {
+		o.Type = identType(id.Name)
+	}
+	return o
+}
+
+// selector transforms the selector expression, keeping the selected
+// name and a package name qualifying it significant if so requested.
+func (t *transformer) selector(n *ast.SelectorExpr) (x, sel *syntax.Node) {
+	if t.opts.Normalize != NormalizeLocals && t.opts.Normalize != NormalizeTypes {
+		return t.trans(n.X), t.trans(n.Sel)
+	}
+	if id, ok := n.X.(*ast.Ident); ok && id.Obj == nil {
+		// not declared in the file, most likely a package name
+		return t.named(id), t.named(n.Sel)
+	}
+	return t.trans(n.X), t.named(n.Sel)
+}
+
+// typeNames returns the identifiers naming types in the file: the names
+// of the declared types, and those in the types of the declarations,
+// fields, parameters, results, composite literals, type assertions and
+// type switch cases. The types converted to, like T in T(x), cannot be
+// told from functions without type checking and are left out.
+func typeNames(file *ast.File) map[*ast.Ident]bool {
+	names := make(map[*ast.Ident]bool)
+	var mark func(e ast.Expr)
+	mark = func(e ast.Expr) {
+		switch e := e.(type) {
+		case *ast.Ident:
+			names[e] = true
+		case *ast.StarExpr:
+			mark(e.X)
+		case *ast.ParenExpr:
+			mark(e.X)
+		case *ast.ArrayType:
+			mark(e.Elt)
+		case *ast.Ellipsis:
+			mark(e.Elt)
+		case *ast.MapType:
+			mark(e.Key)
+			mark(e.Value)
+		case *ast.ChanType:
+			mark(e.Value)
+		case *ast.IndexExpr:
+			mark(e.X)
+			mark(e.Index)
+		case *ast.IndexListExpr:
+			mark(e.X)
+			for _, index := range e.Indices {
+				mark(index)
+			}
+		}
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Field:
+			mark(n.Type)
+		case *ast.ValueSpec:
+			mark(n.Type)
+		case *ast.TypeSpec:
+			names[n.Name] = true
+			mark(n.Type)
+		case *ast.CompositeLit:
+			mark(n.Type)
+		case *ast.TypeAssertExpr:
+			mark(n.Type)
+		case *ast.TypeSwitchStmt:
+			for _, clause := range n.Body.List {
+				for _, e := range clause.(*ast.CaseClause).List {
+					if id, ok := e.(*ast.Ident); !ok || id.Name != "nil" {
+						mark(e)
+					}
+				}
+			}
+		}
+		return true
+	})
+	return names
+}
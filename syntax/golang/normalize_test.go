@@ -0,0 +1,59 @@
+package golang
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	loop := "func f() {\n\tfor i, item := range items {\n\t\tfmt.Println(i, item)\n\t}\n}"
+	renamed := "func g() {\n\tfor j, name := range names {\n\t\tfmt.Println(j, name)\n\t}\n}"
+	otherPkg := "func f() {\n\tfor i, item := range items {\n\t\tlog.Println(i, item)\n\t}\n}"
+	otherSel := "func f() {\n\tfor i, item := range items {\n\t\tfmt.Print(i, item)\n\t}\n}"
+	decl := "func f(data []byte) error {\n\tvar cfg Config\n\treturn json.Unmarshal(data, &cfg)\n}"
+	declRenamed := "func g(b []byte) error {\n\tvar opts Config\n\treturn json.Unmarshal(b, &opts)\n}"
+	declType := "func g(b []byte) error {\n\tvar opts Options\n\treturn json.Unmarshal(b, &opts)\n}"
+	lit := "func f() { x := &Config{}; use(x) }"
+	litType := "func f() { x := &Options{}; use(x) }"
+	param := "func f(c *Config) { use(c) }"
+	paramType := "func f(c *Options) { use(c) }"
+	assert := "func f() { c, ok := v.(Config); use(c, ok) }"
+	assertType := "func f() { c, ok := v.(Options); use(c, ok) }"
+	conv := "func f() { use(Config(v)) }"
+	convType := "func f() { use(Options(v)) }"
+
+	testCases := []struct {
+		a, b string
+		mode Normalization
+		same bool
+	}{
+		{loop, renamed, NormalizeAll, true},
+		{loop, otherPkg, NormalizeAll, true},
+
+		{loop, renamed, NormalizeLocals, true},
+		{loop, otherPkg, NormalizeLocals, false},
+		{loop, otherSel, NormalizeLocals, false},
+		{decl, declType, NormalizeLocals, true},
+
+		{loop, renamed, NormalizeTypes, true},
+		{loop, otherPkg, NormalizeTypes, false},
+		{decl, declRenamed, NormalizeTypes, true},
+		{decl, declType, NormalizeTypes, false},
+		{lit, litType, NormalizeTypes, false},
+		{param, paramType, NormalizeTypes, false},
+		{assert, assertType, NormalizeTypes, false},
+		{"type Config struct{ A int }", "type Options struct{ A int }", NormalizeTypes, false},
+		{"type T struct{ A int }", "type T struct{ B int }", NormalizeTypes, true},
+		{conv, convType, NormalizeTypes, true},
+
+		{loop, loop, NormalizeNone, true},
+		{loop, renamed, NormalizeNone, false},
+		{decl, declRenamed, NormalizeNone, false},
+	}
+	for _, tc := range testCases {
+		a, b := shape(t, tc.a, Options{Normalize: tc.mode}), shape(t, tc.b, Options{Normalize: tc.mode})
+		if same := reflect.DeepEqual(a, b); same != tc.same {
+			t.Errorf("mode %d: %q and %q: got the same %v, want %v", tc.mode, tc.a, tc.b, same, tc.same)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package golang
+
+import (
+	"go/ast"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+// stmts transforms the statement list. If the order of independent
+// statements is to be ignored, each run of adjacent statements that can
+// be freely reordered is sorted by the structure of the statements.
+func (t *transformer) stmts(list []ast.Stmt) []*syntax.Node {
+	nodes := make([]*syntax.Node, len(list))
+	for i, stmt := range list {
+		nodes[i] = t.trans(stmt)
+	}
+	if !t.opts.ReorderTolerant {
+		return nodes
+	}
+	for i := 0; i < len(list); {
+		j := i + 1
+		if e, ok := effectsOf(list[i]); ok {
+			run := []effects{e}
+			for ; j < len(list); j++ {
+				e, ok := effectsOf(list[j])
+				if !ok || !independentOfAll(e, run) {
+					break
+				}
+				run = append(run, e)
+			}
+			sortByStructure(nodes[i:j])
+		}
+		i = j
+	}
+	return nodes
+}
+
+// effects are the variables a statement reads and writes.
+type effects struct {
+	reads, writes map[string]bool
+}
+
+// effectsOf returns the effects of the statement and whether the statement
+// may be moved at all. Only assignments, increments, and declarations to
+// plain variables without any function calls or channel operations may
+// be moved, as the effects of the others are unknown.
+func effectsOf(stmt ast.Stmt) (effects, bool) {
+	e := effects{reads: make(map[string]bool), writes: make(map[string]bool)}
+	var lhs []ast.Expr
+	var rhs []ast.Node
+	switch s := stmt.(type) {
+	case *ast.AssignStmt:
+		lhs = s.Lhs
+		for _, x := range s.Rhs {
+			rhs = append(rhs, x)
+		}
+		if s.Tok != token.ASSIGN && s.Tok != token.DEFINE {
+			// x += y reads x too
+			for _, x := range s.Lhs {
+				rhs = append(rhs, x)
+			}
+		}
+	case *ast.IncDecStmt:
+		lhs = []ast.Expr{s.X}
+		rhs = []ast.Node{s.X}
+	case *ast.DeclStmt:
+		gen, ok := s.Decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.VAR {
+			return e, false
+		}
+		for _, spec := range gen.Specs {
+			vs := spec.(*ast.ValueSpec)
+			for _, name := range vs.Names {
+				lhs = append(lhs, name)
+			}
+			if vs.Type != nil {
+				rhs = append(rhs, vs.Type)
+			}
+			for _, x := range vs.Values {
+				rhs = append(rhs, x)
+			}
+		}
+	default:
+		return e, false
+	}
+	for _, x := range lhs {
+		id, ok := x.(*ast.Ident)
+		if !ok {
+			// Writing through a pointer, a field, or an element may
+			// change any variable.
+			return e, false
+		}
+		e.writes[id.Name] = true
+	}
+	pure := true
+	for _, x := range rhs {
+		ast.Inspect(x, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.CallExpr, *ast.FuncLit:
+				pure = false
+			case *ast.UnaryExpr:
+				if n.Op == token.ARROW {
+					pure = false
+				}
+			case *ast.Ident:
+				e.reads[n.Name] = true
+			}
+			return pure
+		})
+	}
+	delete(e.writes, "_")
+	return e, pure
+}
+
+func independentOfAll(e effects, run []effects) bool {
+	for _, r := range run {
+		if overlap(e.writes, r.reads) || overlap(e.reads, r.writes) || overlap(e.writes, r.writes) {
+			return false
+		}
+	}
+	return true
+}
+
+func overlap(a, b map[string]bool) bool {
+	for k := range a {
+		if b[k] {
+			return true
+		}
+	}
+	return false
+}
+
+// sortByStructure sorts the nodes by their types and the types of the
+// nodes they contain, keeping the order of the nodes of the same structure.
+func sortByStructure(nodes []*syntax.Node) {
+	if len(nodes) < 2 {
+		return
+	}
+	keys := make(map[*syntax.Node]string, len(nodes))
+	for _, n := range nodes {
+		var b strings.Builder
+		for _, m := range syntax.Serialize(n) {
+			b.WriteString(strconv.Itoa(m.Type))
+			b.WriteByte('/')
+			b.WriteString(strconv.Itoa(m.Owns))
+			b.WriteByte(' ')
+		}
+		keys[n] = b.String()
+	}
+	sort.SliceStable(nodes, func(i, j int) bool { return keys[nodes[i]] < keys[nodes[j]] })
+}
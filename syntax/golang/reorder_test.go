@@ -0,0 +1,36 @@
+package golang
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReorderTolerant(t *testing.T) {
+	testCases := []struct {
+		a, b string
+		same bool
+	}{
+		// independent
+		{"func f() { a := 1; b := c + d }", "func f() { b := c + d; a := 1 }", true},
+		{"func f() { var a int; b++ }", "func f() { b++; var a int }", true},
+		// dependent: y reads x
+		{"func f() { x := 1; y := x + 1 }", "func f() { y := x + 1; x := 1 }", false},
+		// dependent: both write x
+		{"func f() { x = 1; x += y }", "func f() { x += y; x = 1 }", false},
+		// calls may have any effects
+		{"func f() { x := f(); g(x) }", "func f() { g(x); x := f() }", false},
+		{"func f() { a := f(); b := 1 }", "func f() { b := 1; a := f() }", false},
+		// writing through a pointer may change any variable
+		{"func f() { *p = 1; b := 2 }", "func f() { b := 2; *p = 1 }", false},
+	}
+	for _, tc := range testCases {
+		opts := Options{ReorderTolerant: true}
+		a, b := shape(t, tc.a, opts), shape(t, tc.b, opts)
+		if same := reflect.DeepEqual(a, b); same != tc.same {
+			t.Errorf("%q and %q: got same %v, want %v", tc.a, tc.b, same, tc.same)
+		}
+		if tc.same && reflect.DeepEqual(shape(t, tc.a, Options{}), shape(t, tc.b, Options{})) {
+			t.Errorf("%q and %q are the same even without -reorder-tolerant", tc.a, tc.b)
+		}
+	}
+}
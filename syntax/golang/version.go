@@ -0,0 +1,63 @@
+package golang
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// checkVersion returns an error about the first construct in the file
+// that the Go release 1.minor does not accept, if there is one.
+// The syntax checked is that of type aliases (1.9), of the number
+// literals with a base prefix or digit separators (1.13), and of type
+// parameters and type sets (1.18).
+func checkVersion(fset *token.FileSet, file *ast.File, minor int) error {
+	var err error
+	require := func(pos token.Pos, what string, since int) {
+		if err == nil && minor < since {
+			err = fmt.Errorf("%s: %s requires go1.%d or later (-go-version is go1.%d)",
+				fset.Position(pos), what, since, minor)
+		}
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if err != nil {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.TypeSpec:
+			if n.Assign.IsValid() {
+				require(n.Pos(), "type alias", 9)
+			}
+			if n.TypeParams != nil {
+				require(n.TypeParams.Pos(), "type parameter", 18)
+			}
+		case *ast.FuncType:
+			if n.TypeParams != nil {
+				require(n.TypeParams.Pos(), "type parameter", 18)
+			}
+		case *ast.IndexListExpr:
+			require(n.Pos(), "type instantiation", 18)
+		case *ast.InterfaceType:
+			for _, f := range n.Methods.List {
+				if _, ok := f.Type.(*ast.BinaryExpr); ok && len(f.Names) == 0 {
+					require(f.Pos(), "type set", 18)
+				}
+			}
+		case *ast.UnaryExpr:
+			if n.Op == token.TILDE {
+				require(n.Pos(), "type set", 18)
+			}
+		case *ast.BasicLit:
+			if n.Kind == token.INT || n.Kind == token.FLOAT || n.Kind == token.IMAG {
+				lit := strings.ToLower(n.Value)
+				if strings.Contains(lit, "_") || strings.HasPrefix(lit, "0b") ||
+					strings.HasPrefix(lit, "0o") || strings.HasPrefix(lit, "0x") && n.Kind != token.INT {
+					require(n.Pos(), "number literal "+n.Value, 13)
+				}
+			}
+		}
+		return true
+	})
+	return err
+}
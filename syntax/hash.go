@@ -0,0 +1,116 @@
+package syntax
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash/fnv"
+	"math/bits"
+)
+
+// Hasher hashes the serialized structure of a node sequence. The clones
+// are grouped, and the fingerprints identified, by the hashes, so two
+// different structures with the same hash are taken for clones.
+type Hasher interface {
+	Sum(data []byte) []byte
+}
+
+// HasherFunc is a function used as a Hasher.
+type HasherFunc func(data []byte) []byte
+
+// Sum returns f(data).
+func (f HasherFunc) Sum(data []byte) []byte { return f(data) }
+
+// Hashers are the available hashers by name:
+//
+//	sha1    SHA-1, 160 bits; the default
+//	sha256  SHA-256, 256 bits
+//	fnv     64-bit FNV-1a
+//	xxhash  64-bit xxHash (XXH64)
+var Hashers = map[string]Hasher{
+	"sha1": HasherFunc(func(data []byte) []byte {
+		sum := sha1.Sum(data)
+		return sum[:]
+	}),
+	"sha256": HasherFunc(func(data []byte) []byte {
+		sum := sha256.Sum256(data)
+		return sum[:]
+	}),
+	"fnv": HasherFunc(func(data []byte) []byte {
+		h := fnv.New64a()
+		h.Write(data)
+		return h.Sum(nil)
+	}),
+	"xxhash": HasherFunc(func(data []byte) []byte {
+		var sum [8]byte
+		binary.BigEndian.PutUint64(sum[:], xxh64(data))
+		return sum[:]
+	}),
+}
+
+// SeqHasher is the hasher of the node sequences.
+var SeqHasher = Hashers["sha1"]
+
+const (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+// xxh64 returns the XXH64 hash of data with the seed 0.
+func xxh64(data []byte) uint64 {
+	n := len(data)
+	var h uint64
+	if n >= 32 {
+		// The sums wrap around, which constants do not.
+		p1, p2 := xxPrime1, xxPrime2
+		v1 := p1 + p2
+		v2 := p2
+		v3 := uint64(0)
+		v4 := -p1
+		for ; len(data) >= 32; data = data[32:] {
+			v1 = xxRound(v1, binary.LittleEndian.Uint64(data[0:]))
+			v2 = xxRound(v2, binary.LittleEndian.Uint64(data[8:]))
+			v3 = xxRound(v3, binary.LittleEndian.Uint64(data[16:]))
+			v4 = xxRound(v4, binary.LittleEndian.Uint64(data[24:]))
+		}
+		h = bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) +
+			bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+		for _, v := range []uint64{v1, v2, v3, v4} {
+			h ^= xxRound(0, v)
+			h = h*xxPrime1 + xxPrime4
+		}
+	} else {
+		h = xxPrime5
+	}
+	h += uint64(n)
+
+	for ; len(data) >= 8; data = data[8:] {
+		h ^= xxRound(0, binary.LittleEndian.Uint64(data))
+		h = bits.RotateLeft64(h, 27)*xxPrime1 + xxPrime4
+	}
+	if len(data) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(data)) * xxPrime1
+		h = bits.RotateLeft64(h, 23)*xxPrime2 + xxPrime3
+		data = data[4:]
+	}
+	for _, b := range data {
+		h ^= uint64(b) * xxPrime5
+		h = bits.RotateLeft64(h, 11) * xxPrime1
+	}
+
+	h ^= h >> 33
+	h *= xxPrime2
+	h ^= h >> 29
+	h *= xxPrime3
+	h ^= h >> 32
+	return h
+}
+
+func xxRound(acc, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxPrime1
+}
@@ -0,0 +1,22 @@
+package syntax
+
+import "testing"
+
+func TestXXH64(t *testing.T) {
+	testCases := []struct {
+		in   string
+		want uint64
+	}{
+		{"", 0xef46db3751d8e999},
+		{"a", 0xd24ec4f1a98c6e5b},
+		{"as", 0x1c330fb2d66be179},
+		{"asd", 0x631c37ce72a97393},
+		{"asdf", 0x415872f599cea71e},
+		{"Call me Ishmael. Some years ago--never mind how long precisely-", 0x02a2e85470d6fd96},
+	}
+	for _, tc := range testCases {
+		if got := xxh64([]byte(tc.in)); got != tc.want {
+			t.Errorf("xxh64(%q) = %#x, want %#x", tc.in, got, tc.want)
+		}
+	}
+}
@@ -1,7 +1,7 @@
 package syntax
 
 import (
-	"crypto/sha1"
+	"encoding/binary"
 
 	"github.com/mibk/dupl/suffixtree"
 )
@@ -10,6 +10,7 @@ type Node struct {
 	Type     int
 	Filename string
 	Pos, End int
+	Parent   *Node
 	Children []*Node
 	Owns     int
 }
@@ -19,6 +20,9 @@ func NewNode() *Node {
 }
 
 func (n *Node) AddChildren(children ...*Node) {
+	for _, c := range children {
+		c.Parent = n
+	}
 	n.Children = append(n.Children, children...)
 }
 
@@ -29,6 +33,9 @@ func (n *Node) Val() int {
 type Match struct {
 	Hash  string
 	Frags [][]*Node
+
+	// Tokens is the number of nodes in each fragment.
+	Tokens int
 }
 
 func Serialize(n *Node) []*Node {
@@ -47,33 +54,55 @@ func serial(n *Node, stream *[]*Node) int {
 	return count + 1
 }
 
+// Unique removes the fragments starting at the same position
+// as a preceding fragment from the group.
+func Unique(group [][]*Node) [][]*Node {
+	fileMap := make(map[string]map[int]struct{})
+
+	var newGroup [][]*Node
+	for _, seq := range group {
+		node := seq[0]
+		file, ok := fileMap[node.Filename]
+		if !ok {
+			file = make(map[int]struct{})
+			fileMap[node.Filename] = file
+		}
+		if _, ok := file[node.Pos]; !ok {
+			file[node.Pos] = struct{}{}
+			newGroup = append(newGroup, seq)
+		}
+	}
+	return newGroup
+}
+
 // FindSyntaxUnits finds all complete syntax units in the match group and returns them
-// with the corresponding hash.
-func FindSyntaxUnits(data []*Node, m suffixtree.Match, threshold int) Match {
+// with the corresponding hash. The units are split into several matches wherever
+// they are not consecutive, e.g. when the match group spans multiple files.
+func FindSyntaxUnits(data []*Node, m suffixtree.Match, threshold int) []Match {
 	if len(m.Ps) == 0 {
-		return Match{}
+		return nil
 	}
+	// The sequences are not sliced out of data upfront; a repetitive
+	// input yields a huge number of matches with many positions each.
 	firstSeq := data[m.Ps[0] : m.Ps[0]+m.Len]
-	indexes := getUnitsIndexes(firstSeq, threshold)
-
-	// TODO: is this really working?
-	indexCnt := len(indexes)
-	if indexCnt > 0 {
-		lasti := indexes[indexCnt-1]
-		firstn := firstSeq[lasti]
-		for i := 1; i < len(m.Ps); i++ {
-			n := data[int(m.Ps[i])+lasti]
-			if firstn.Owns != n.Owns {
-				indexes = indexes[:indexCnt-1]
-				break
-			}
+	var matches []Match
+	for _, indexes := range getUnitsRuns(data, m.Ps, int(m.Len), threshold) {
+		if match, ok := newMatch(data, m, firstSeq, indexes); ok {
+			matches = append(matches, match)
 		}
 	}
-	if len(indexes) == 0 || isCyclic(indexes, firstSeq) || spansMultipleFiles(indexes, firstSeq) {
-		return Match{}
+	return matches
+}
+
+func newMatch(data []*Node, m suffixtree.Match, firstSeq []*Node, indexes []int) (Match, bool) {
+	if isCyclic(indexes, firstSeq) || spansMultipleFiles(indexes, firstSeq) {
+		return Match{}, false
 	}
 
 	match := Match{Frags: make([][]*Node, len(m.Ps))}
+	for _, index := range indexes {
+		match.Tokens += firstSeq[index].Owns + 1
+	}
 	for i, pos := range m.Ps {
 		match.Frags[i] = make([]*Node, len(indexes))
 		for j, index := range indexes {
@@ -82,33 +111,80 @@ func FindSyntaxUnits(data []*Node, m suffixtree.Match, threshold int) Match {
 	}
 
 	lastIndex := indexes[len(indexes)-1]
-	match.Hash = hashSeq(firstSeq[indexes[0] : lastIndex+firstSeq[lastIndex].Owns])
-	return match
+	match.Hash = hashSeq(firstSeq[indexes[0] : lastIndex+firstSeq[lastIndex].Owns+1])
+	return match, true
 }
 
-func getUnitsIndexes(nodeSeq []*Node, threshold int) []int {
+// Unit is a complete syntax unit starting at index Index of a node sequence.
+type Unit struct {
+	Hash  string
+	Index int
+	Nodes []*Node
+}
+
+// Units returns all complete syntax units in seq consisting of at least
+// threshold nodes. A unit is always listed before the units nested in it.
+func Units(seq []*Node, threshold int) []Unit {
+	var units []Unit
+	for i, n := range seq {
+		if n.Owns+1 < threshold || i+n.Owns >= len(seq) {
+			continue
+		}
+		nodes := seq[i : i+n.Owns+1]
+		units = append(units, Unit{Hash: hashSeq(nodes), Index: i, Nodes: nodes})
+	}
+	return units
+}
+
+// getUnitsRuns returns the indexes of all complete syntax units of at least
+// threshold nodes in the node sequences of the same types, split into runs
+// of consecutive units. The sequences of the given length start at the
+// positions ps of data. A unit must be complete in all the sequences,
+// not just in the first one, so the result does not depend on their order.
+func getUnitsRuns(data []*Node, ps []suffixtree.Pos, length, threshold int) [][]int {
+	nodeSeq := data[ps[0] : int(ps[0])+length]
+	var runs [][]int
 	var indexes []int
-	var split bool
+	split := func() {
+		if len(indexes) > 0 {
+			runs = append(runs, indexes)
+			indexes = nil
+		}
+	}
 	for i := 0; i < len(nodeSeq); {
 		n := nodeSeq[i]
 		switch {
 		case n.Owns >= len(nodeSeq)-i:
 			// not complete syntax unit
 			i++
-			split = true
+			split()
 			continue
 		case n.Owns+1 < threshold:
-			split = true
+			// The nodes nested in the unit are even smaller, so there is
+			// no need to check whether it is complete in all sequences.
+			split()
+		case !sameOwns(data, ps, i):
+			// not complete syntax unit in every sequence
+			i++
+			split()
+			continue
 		default:
-			if split {
-				indexes = indexes[:0]
-				split = false
-			}
 			indexes = append(indexes, i)
 		}
 		i += n.Owns + 1
 	}
-	return indexes
+	split()
+	return runs
+}
+
+func sameOwns(data []*Node, ps []suffixtree.Pos, i int) bool {
+	owns := data[int(ps[0])+i].Owns
+	for _, pos := range ps[1:] {
+		if data[int(pos)+i].Owns != owns {
+			return false
+		}
+	}
+	return true
 }
 
 // isCyclic finds out whether there is a repetive pattern in the found clone. If positive,
@@ -164,12 +240,18 @@ func spansMultipleFiles(indexes []int, nodes []*Node) bool {
 	return false
 }
 
+// hashSeq returns a hash of the structure of the nodes, that is their
+// types and the numbers of nodes they own, by SeqHasher. It does not
+// depend on anything else, like the files the nodes come from or their
+// order.
 func hashSeq(nodes []*Node) string {
-	h := sha1.New()
-	bytes := make([]byte, len(nodes))
-	for i, node := range nodes {
-		bytes[i] = byte(node.Type)
+	buf := make([]byte, 0, 2*len(nodes))
+	var tmp [binary.MaxVarintLen64]byte
+	for _, node := range nodes {
+		n := binary.PutVarint(tmp[:], int64(node.Type))
+		buf = append(buf, tmp[:n]...)
+		n = binary.PutUvarint(tmp[:], uint64(node.Owns))
+		buf = append(buf, tmp[:n]...)
 	}
-	h.Write(bytes)
-	return string(h.Sum(nil))
+	return string(SeqHasher.Sum(buf))
 }
@@ -1,6 +1,11 @@
 package syntax
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mibk/dupl/suffixtree"
+)
 
 func TestSerialization(t *testing.T) {
 	n := genNodes(7)
@@ -42,30 +47,59 @@ func compareSeries(t *testing.T, stream []*Node, owns []int) {
 	}
 }
 
-func TestGetUnitsIndexes(t *testing.T) {
+func TestGetUnitsRuns(t *testing.T) {
 	testCases := []struct {
 		seq       string
 		threshold int
-		expected  []int
+		expected  [][]int
 	}{
-		{"a8 a0 a2 a0 a0", 3, []int{2}},
-		{"a0 a8 a2 a0 a0", 1, []int{2}},
-		{"a3 a0 a0 a0 a1", 3, []int{0}},
-		{"a3 a0 a0 a0 a0", 1, []int{0, 4}},
-		{"a1 a0 a1 a0 a0", 2, []int{0, 2}},
+		{"a8 a0 a2 a0 a0", 3, [][]int{{2}}},
+		{"a0 a8 a2 a0 a0", 1, [][]int{{0}, {2}}},
+		{"a3 a0 a0 a0 a1", 3, [][]int{{0}}},
+		{"a3 a0 a0 a0 a0", 1, [][]int{{0, 4}}},
+		{"a1 a0 a1 a0 a0", 2, [][]int{{0, 2}}},
+		{"a1 a0 a0 a1 a0", 2, [][]int{{0}, {3}}},
 	}
 
-Loop:
 	for _, tc := range testCases {
 		nodes := str2nodes(tc.seq)
-		indexes := getUnitsIndexes(nodes, tc.threshold)
-		for i := range tc.expected {
-			if i > len(indexes)-1 || tc.expected[i] != indexes[i] {
-				t.Errorf("for seq '%s', got %v, want %v", tc.seq, indexes, tc.expected)
-			}
-			continue Loop
+		runs := getUnitsRuns(nodes, []suffixtree.Pos{0}, len(nodes), tc.threshold)
+		if !reflect.DeepEqual(runs, tc.expected) {
+			t.Errorf("for seq '%s', got %v, want %v", tc.seq, runs, tc.expected)
+		}
+	}
+}
+
+func TestFindSyntaxUnitsRuns(t *testing.T) {
+	// c5 is not a complete unit within the match, so it splits the
+	// units of each copy into two runs. Both are reported, not only
+	// the last one: which of the runs comes last depends on how far
+	// the match extends, and so on the order of the files.
+	data := str2nodes("a1 b0 c5 d1 e0 a1 b0 c5 d1 e0")
+	m := suffixtree.Match{Ps: []suffixtree.Pos{0, 5}, Len: 5}
+	matches := FindSyntaxUnits(data, m, 2)
+	if len(matches) != 2 {
+		t.Fatalf("got %d matches, want 2", len(matches))
+	}
+	for i, want := range [][2]int{{0, 5}, {3, 8}} {
+		frags := matches[i].Frags
+		if len(frags) != 2 || frags[0][0] != data[want[0]] || frags[1][0] != data[want[1]] {
+			t.Errorf("match %d: got fragments %v, want ones starting at %v", i, frags, want)
+		}
+		if matches[i].Tokens != 2 {
+			t.Errorf("match %d: got %d tokens, want 2", i, matches[i].Tokens)
 		}
 	}
+	if matches[0].Hash == matches[1].Hash {
+		t.Error("the runs of different units have the same hash")
+	}
+
+	// A unit complete in one copy but not in the other is left out.
+	data = str2nodes("a1 b0 c0 a2 b0 c0")
+	m = suffixtree.Match{Ps: []suffixtree.Pos{0, 3}, Len: 3}
+	if matches := FindSyntaxUnits(data, m, 2); len(matches) != 0 {
+		t.Errorf("got %d matches of units incomplete in a copy, want 0", len(matches))
+	}
 }
 
 func TestCyclicDupl(t *testing.T) {
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mibk/dupl/printer"
+)
+
+// shapeGroup sets the shape of the group, the source of its
+// representative fragment with the tokens the fragments differ in
+// replaced by the placeholders $1, $2, and so on, and sets what every
+// fragment fills the placeholders with. Tokens differing the same way
+// in all the fragments, like a variable used several times, share
+// a placeholder.
+func shapeGroup(g *printer.Group) error {
+	files := make([][]byte, len(g.Frags))
+	tokens := make([][]string, len(g.Frags))
+	for i, frag := range g.Frags {
+		file, err := readFile(frag.Filename)
+		if err != nil {
+			return err
+		}
+		files[i] = file
+		for _, l := range leaves(nil, frag.Nodes) {
+			tokens[i] = append(tokens[i], string(file[l.Pos:l.End]))
+		}
+		if len(tokens[i]) != len(tokens[0]) {
+			// The fragments do not correspond token by token.
+			return nil
+		}
+	}
+
+	// The placeholders are numbered in the order of the source
+	// of the representative fragment.
+	rep := representative(g)
+	repLeaves := leaves(nil, g.Frags[rep].Nodes)
+	type hole struct {
+		pos, end int
+		texts    []string
+		n        int
+	}
+	var holes []hole
+	for j, l := range repLeaves {
+		h := hole{pos: l.Pos, end: l.End, texts: make([]string, len(g.Frags))}
+		same := true
+		for i := range g.Frags {
+			h.texts[i] = tokens[i][j]
+			same = same && h.texts[i] == h.texts[0]
+		}
+		if !same {
+			holes = append(holes, h)
+		}
+	}
+	sort.Slice(holes, func(i, j int) bool { return holes[i].pos < holes[j].pos })
+	placeholders := make(map[string]int)
+	for k, h := range holes {
+		key := strings.Join(h.texts, "\x00")
+		n, ok := placeholders[key]
+		if !ok {
+			n = len(placeholders) + 1
+			placeholders[key] = n
+			for i := range g.Frags {
+				g.Frags[i].Holes = append(g.Frags[i].Holes, h.texts[i])
+			}
+		}
+		holes[k].n = n
+	}
+
+	frag, file := g.Frags[rep], files[rep]
+	start := bytes.LastIndexByte(file[:frag.Pos], '\n') + 1
+	var shape bytes.Buffer
+	for _, r := range string(file[start:frag.Pos]) {
+		if r == '\t' {
+			shape.WriteByte('\t')
+		} else {
+			shape.WriteByte(' ')
+		}
+	}
+	pos := frag.Pos
+	for _, h := range holes {
+		shape.Write(file[pos:h.pos])
+		fmt.Fprintf(&shape, "$%d", h.n)
+		pos = h.end
+	}
+	shape.Write(file[pos:frag.End])
+	g.Shape = shape.Bytes()
+	return nil
+}
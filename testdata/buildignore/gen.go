@@ -0,0 +1,13 @@
+//go:build ignore
+
+// The generator of a.go.
+
+package fixture
+
+func gen(m map[string]int) {
+	for k, v := range m {
+		if v > 1 {
+			println(k, v*3)
+		}
+	}
+}
@@ -0,0 +1,5 @@
+//go:build linux || ignore
+
+package fixture
+
+func linux() {}
@@ -0,0 +1,9 @@
+package fixture
+
+func a(m map[string]int) {
+	for k, v := range m {
+		if v > 1 {
+			println(k, v*3)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package determinism
+
+func aSum(xs []int) int {
+	total := 0
+	for _, x := range xs {
+		if x > 0 {
+			total += x
+		}
+	}
+	return total
+}
+
+func aMax(xs []int) int {
+	best := xs[0]
+	for i := 1; i < len(xs); i++ {
+		if xs[i] > best {
+			best = xs[i]
+		}
+	}
+	return best
+}
+
+func aSwap(m map[string]int) map[int]string {
+	out := make(map[int]string, len(m))
+	for k, v := range m {
+		out[v] = k
+	}
+	return out
+}
+
+func aCount(s string, c byte) int {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == c {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,29 @@
+package determinism
+
+func bMax(xs []int) int {
+	best := xs[0]
+	for i := 1; i < len(xs); i++ {
+		if xs[i] > best {
+			best = xs[i]
+		}
+	}
+	return best
+}
+
+func bSwap(m map[string]int) map[int]string {
+	out := make(map[int]string, len(m))
+	for k, v := range m {
+		out[v] = k
+	}
+	return out
+}
+
+func bSum(xs []int) int {
+	total := 0
+	for _, x := range xs {
+		if x > 0 {
+			total += x
+		}
+	}
+	return total
+}
@@ -0,0 +1,39 @@
+package determinism
+
+func cSwap(m map[string]int) map[int]string {
+	out := make(map[int]string, len(m))
+	for k, v := range m {
+		out[v] = k
+	}
+	return out
+}
+
+func cCount(s string, c byte) int {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == c {
+			n++
+		}
+	}
+	return n
+}
+
+func cSum(xs []int) int {
+	total := 0
+	for _, x := range xs {
+		if x > 0 {
+			total += x
+		}
+	}
+	return total
+}
+
+func cMax(xs []int) int {
+	best := xs[0]
+	for i := 1; i < len(xs); i++ {
+		if xs[i] > best {
+			best = xs[i]
+		}
+	}
+	return best
+}
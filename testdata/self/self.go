@@ -0,0 +1,30 @@
+package self
+
+func convert(kind string, xs []int) []int {
+	var out []int
+	switch kind {
+	case "double":
+		for i, x := range xs {
+			if x > 0 && i%2 == 0 {
+				out = append(out, x*2)
+			}
+		}
+	case "triple":
+		for i, x := range xs {
+			if x > 0 && i%2 == 0 {
+				out = append(out, x*3)
+			}
+		}
+	}
+	return out
+}
+
+func quadruple(xs []int) []int {
+	var out []int
+	for i, x := range xs {
+		if x > 0 && i%2 == 0 {
+			out = append(out, x*4)
+		}
+	}
+	return out
+}
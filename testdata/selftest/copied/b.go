@@ -0,0 +1,15 @@
+package fixture
+
+func sum(items []int, limit int) (int, error) {
+	total := 0
+	for i, item := range items {
+		if item < 0 {
+			return 0, fmt.Errorf("item %d is negative", i)
+		}
+		total += item
+		if total > limit {
+			return limit, nil
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,15 @@
+package fixture
+
+func total(values []int, max int) (int, error) {
+	acc := 0
+	for j, v := range values {
+		if v < 0 {
+			return 0, fmt.Errorf("value %d is bad", j)
+		}
+		acc += v
+		if acc > max {
+			return max, nil
+		}
+	}
+	return acc, nil
+}
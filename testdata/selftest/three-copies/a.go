@@ -0,0 +1,34 @@
+package fixture
+
+func loops(m map[string][]int, out chan<- string) {
+	for k, vs := range m {
+		for i := range vs {
+			if vs[i] > 10 {
+				vs[i] = vs[i] * 2
+				out <- fmt.Sprint(k, i)
+			} else {
+				delete(m, k)
+			}
+		}
+	}
+	for k, vs := range m {
+		for i := range vs {
+			if vs[i] > 20 {
+				vs[i] = vs[i] * 3
+				out <- fmt.Sprint(k, i)
+			} else {
+				delete(m, k)
+			}
+		}
+	}
+	for k, vs := range m {
+		for i := range vs {
+			if vs[i] > 30 {
+				vs[i] = vs[i] * 4
+				out <- fmt.Sprint(k, i)
+			} else {
+				delete(m, k)
+			}
+		}
+	}
+}
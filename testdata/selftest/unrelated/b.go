@@ -0,0 +1,9 @@
+package fixture
+
+type point struct{ x, y float64 }
+
+func (p point) scale(f float64) point {
+	return point{p.x * f, p.y * f}
+}
+
+var origin = point{}
@@ -0,0 +1,24 @@
+package strict
+
+import "strings"
+
+// sumLengths returns the total length of the words.
+func sumLengths(words []string) int {
+	total := 0
+	for _, w := range words {
+		if strings.HasPrefix(w, "#") {
+			continue // skip the comments
+		}
+		total += len(w)
+	}
+	return total
+}
+
+func countBytes(lines []string) int {
+	n := 1
+	for _, line := range lines {
+		if strings.HasSuffix(line, "\\") { continue }
+		n += len(line)
+	}
+	return n
+}
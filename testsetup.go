@@ -0,0 +1,133 @@
+package main
+
+import (
+	"crypto/sha1"
+	"regexp"
+	"strings"
+
+	"github.com/mibk/dupl/printer"
+	"github.com/mibk/dupl/syntax"
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+// testFunc matches the names of the test functions.
+var testFunc = regexp.MustCompile(`^Test`)
+
+// testPreamble is a test function with the hashes of its statements.
+type testPreamble struct {
+	stmts  []*syntax.Node
+	hashes []string
+}
+
+// findTestSetups sends to duplChan the sequences of statements of at
+// least threshold tokens that several test functions start with. Each
+// set of test functions is sent with the longest sequence they share.
+func findTestSetups(data *[]*syntax.Node, threshold int, duplChan chan<- syntax.Match) {
+	defer close(duplChan)
+	tests, err := testPreambles(*data)
+	if err != nil {
+		tracef("searching for test functions: %v", err)
+		return
+	}
+	splitPreambles(tests, 0, threshold, duplChan)
+}
+
+// testPreambles returns the statements of the test functions in data.
+func testPreambles(data []*syntax.Node) ([]testPreamble, error) {
+	var tests []testPreamble
+	for i, n := range data {
+		if n.Type != golang.BlockStmt || n.Parent == nil || n.Parent.Type != golang.FuncDecl ||
+			!strings.HasSuffix(n.Filename, "_test.go") {
+			continue
+		}
+		file, err := readFile(n.Filename)
+		if err != nil {
+			return nil, err
+		}
+		if !testFunc.MatchString(funcName(n.Parent, file)) {
+			continue
+		}
+		var t testPreamble
+		for j := i + 1; j < i+1+n.Owns; j += data[j].Owns + 1 {
+			stmt := data[j : j+data[j].Owns+1]
+			t.stmts = append(t.stmts, stmt[0])
+			t.hashes = append(t.hashes, syntax.Units(stmt, 1)[0].Hash)
+		}
+		tests = append(tests, t)
+	}
+	return tests, nil
+}
+
+// splitPreambles groups the tests sharing the first depth statements
+// by their next statement, and sends each group that cannot be extended
+// by another statement.
+func splitPreambles(tests []testPreamble, depth, threshold int, duplChan chan<- syntax.Match) {
+	if stopped() {
+		return
+	}
+	byStmt := make(map[string][]testPreamble)
+	var order []string
+	for _, t := range tests {
+		if depth >= len(t.hashes) {
+			continue
+		}
+		h := t.hashes[depth]
+		if _, ok := byStmt[h]; !ok {
+			order = append(order, h)
+		}
+		byStmt[h] = append(byStmt[h], t)
+	}
+	if len(byStmt) == 1 && len(byStmt[order[0]]) == len(tests) {
+		splitPreambles(tests, depth+1, threshold, duplChan)
+		return
+	}
+	if depth > 0 && len(tests) > 1 {
+		sendPreambles(tests, depth, threshold, duplChan)
+	}
+	for _, h := range order {
+		if group := byStmt[h]; len(group) > 1 {
+			splitPreambles(group, depth+1, threshold, duplChan)
+		}
+	}
+}
+
+// sendPreambles sends the first n statements of the tests as a match
+// if they consist of at least threshold tokens.
+func sendPreambles(tests []testPreamble, n, threshold int, duplChan chan<- syntax.Match) {
+	match := syntax.Match{Frags: make([][]*syntax.Node, len(tests))}
+	for _, stmt := range tests[0].stmts[:n] {
+		match.Tokens += stmt.Owns + 1
+	}
+	if match.Tokens < threshold {
+		return
+	}
+	h := sha1.New()
+	for _, hash := range tests[0].hashes[:n] {
+		h.Write([]byte(hash))
+	}
+	match.Hash = string(h.Sum(nil))
+	for i, t := range tests {
+		match.Frags[i] = t.stmts[:n]
+	}
+	if seedLen > 0 && !hasSeedFrag(match) {
+		return
+	}
+	if !matchesFiles(match) {
+		return
+	}
+	duplChan <- match
+}
+
+// nameTests sets the context of every fragment of the group to
+// the name of the test function starting with it.
+func nameTests(g *printer.Group) error {
+	for i := range g.Frags {
+		frag := &g.Frags[i]
+		file, err := readFile(frag.Filename)
+		if err != nil {
+			return err
+		}
+		frag.Context = funcName(enclosingFunc(frag.Nodes[0]), file)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mibk/dupl/syntax"
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+// testSetups returns the test functions of each match findTestSetups
+// finds in the files, with the number of the statements they share.
+func testSetups(t *testing.T, threshold int, files map[string]string) []string {
+	t.Helper()
+	var data []*syntax.Node
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		addMemFile(t, name, files[name])
+		root, err := golang.Parse(name, []byte(files[name]), golang.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, syntax.Serialize(root)...)
+	}
+	duplChan := make(chan syntax.Match)
+	go findTestSetups(&data, threshold, duplChan)
+	var setups []string
+	for m := range duplChan {
+		var s strings.Builder
+		for _, frag := range m.Frags {
+			file, err := readFile(frag[0].Filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fmt.Fprintf(&s, "%s ", funcName(enclosingFunc(frag[0]), file))
+		}
+		fmt.Fprint(&s, len(m.Frags[0]))
+		setups = append(setups, s.String())
+	}
+	sort.Strings(setups)
+	return setups
+}
+
+func TestFindTestSetups(t *testing.T) {
+	const setup = "\tdb := open(t)\n\tdefer db.Close()\n\tdb.Reset()\n"
+	files := map[string]string{
+		"a_test.go": "package p\n\n" +
+			"func TestA(t *testing.T) {\n" + setup + "\tcheckA(db)\n}\n\n" +
+			"func TestB(t *testing.T) {\n" + setup + "\tcheckB(db, 1)\n}\n\n" +
+			// only the first statement of the setup
+			"func TestC(t *testing.T) {\n\tdb := open(t)\n\tcheckC(db)\n}\n\n" +
+			// not a test function
+			"func helper(t *testing.T) {\n" + setup + "\tcheckA(db)\n}\n",
+		// not a test file
+		"a.go": "package p\n\nfunc TestD(t *testing.T) {\n" + setup + "\tcheckD(db)\n}\n",
+		"b_test.go": "package p\n\n" +
+			// a setup shared by a single test
+			"func TestE(t *testing.T) {\n\tx := 1\n\ty := 2\n\tuse(x, y)\n}\n",
+	}
+	got := testSetups(t, 1, files)
+	want := []string{"TestA TestB 3", "TestA TestB TestC 1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got the setups %q, want %q", got, want)
+	}
+
+	// The shorter setup is left out by the threshold.
+	got = testSetups(t, 10, files)
+	want = []string{"TestA TestB 3"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("threshold 10: got the setups %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mibk/dupl/printer"
+)
+
+// langThresholds is a flag of the thresholds by language, given as
+// lang=size pairs separated by commas, like go=30,js=50. A language
+// is named by the extension of its files. A size without a language
+// sets -from-threshold, as -threshold did before it took languages.
+type langThresholds map[string]int
+
+func (l *langThresholds) String() string {
+	var s []string
+	for lang, size := range *l {
+		s = append(s, lang+"="+strconv.Itoa(size))
+	}
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
+
+func (l *langThresholds) Set(value string) error {
+	if *l == nil {
+		*l = make(langThresholds)
+	}
+	for _, pair := range strings.Split(value, ",") {
+		if size, err := strconv.Atoi(pair); err == nil {
+			if size <= 0 {
+				return fmt.Errorf("invalid threshold size in %q", pair)
+			}
+			*fromThreshold = size
+			continue
+		}
+		i := strings.Index(pair, "=")
+		if i <= 0 {
+			return fmt.Errorf("threshold must be lang=size or size, got %q", pair)
+		}
+		size, err := strconv.Atoi(pair[i+1:])
+		if err != nil || size <= 0 {
+			return fmt.Errorf("invalid threshold size in %q", pair)
+		}
+		(*l)[strings.TrimPrefix(pair[:i], ".")] = size
+	}
+	return nil
+}
+
+// pathThreshold is the threshold of the files in a path.
+type pathThreshold struct {
+	path string
+	size int
+}
+
+// pathThresholdList is a flag of the thresholds by path, given as
+// path:size, like backend:30; it can be repeated.
+type pathThresholdList []pathThreshold
+
+func (l *pathThresholdList) String() string {
+	var s []string
+	for _, t := range *l {
+		s = append(s, t.path+":"+strconv.Itoa(t.size))
+	}
+	return strings.Join(s, " ")
+}
+
+func (l *pathThresholdList) Set(value string) error {
+	// The path may contain a colon, the size may not.
+	i := strings.LastIndex(value, ":")
+	if i <= 0 {
+		return fmt.Errorf("threshold must be path:size, got %q", value)
+	}
+	size, err := strconv.Atoi(value[i+1:])
+	if err != nil || size <= 0 {
+		return fmt.Errorf("invalid threshold size in %q", value)
+	}
+	*l = append(*l, pathThreshold{filepath.Clean(value[:i]), size})
+	return nil
+}
+
+// lookup returns the threshold of the longest path containing the file.
+func (l pathThresholdList) lookup(file string) (size int, ok bool) {
+	longest := -1
+	for _, t := range l {
+		if len(t.path) > longest && (t.path == "." || inDir(file, t.path)) {
+			size, longest = t.size, len(t.path)
+		}
+	}
+	return size, longest >= 0
+}
+
+// fileLang returns the language of the file by its extension. Files
+// without one, like <stdin>, are taken for Go.
+func fileLang(file string) string {
+	if ext := filepath.Ext(file); ext != "" {
+		return ext[1:]
+	}
+	return "go"
+}
+
+// fileThreshold returns the threshold for the clones in the file:
+// the one of the longest path containing it, or else the one of its
+// language, or else -from-threshold.
+func fileThreshold(file string) int {
+	if size, ok := pathThresholds.lookup(file); ok {
+		return size
+	}
+	if size, ok := thresholds[fileLang(file)]; ok {
+		return size
+	}
+	return *fromThreshold
+}
+
+// thresholdRange returns the range of thresholds to search for clones
+// in the files with: from the lowest of their thresholds, up to the
+// highest one, or -to-threshold if it is higher.
+func thresholdRange(files []string) (from, to int) {
+	if len(thresholds) == 0 && len(pathThresholds) == 0 {
+		return *fromThreshold, *toThreshold
+	}
+	from, to = -1, *toThreshold
+	for _, file := range files {
+		t := fileThreshold(file)
+		if from < 0 || t < from {
+			from = t
+		}
+		to = max(to, t)
+	}
+	if from < 0 {
+		from = *fromThreshold
+	}
+	return from, max(from, to)
+}
+
+// groupThreshold returns the threshold for the clone group, the highest
+// of the thresholds of its fragments.
+func groupThreshold(g *printer.Group) int {
+	t := 0
+	for _, frag := range g.Frags {
+		t = max(t, fileThreshold(frag.Filename))
+	}
+	return t
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mibk/dupl/printer"
+)
+
+func TestLangThresholdsSet(t *testing.T) {
+	defer func(old int) { *fromThreshold = old }(*fromThreshold)
+	testCases := []struct {
+		value string
+		want  langThresholds
+		from  int // the -from-threshold after, 0 if unchanged
+		ok    bool
+	}{
+		{"go=30", langThresholds{"go": 30}, 0, true},
+		{"go=30,js=50", langThresholds{"go": 30, "js": 50}, 0, true},
+		{".go=30", langThresholds{"go": 30}, 0, true},
+		{"go=30,go=40", langThresholds{"go": 40}, 0, true},
+		{"30", langThresholds{}, 30, true},
+		{"30,js=50", langThresholds{"js": 50}, 30, true},
+		{"0", nil, 0, false},
+		{"-5", nil, 0, false},
+		{"go", nil, 0, false},
+		{"=30", nil, 0, false},
+		{"go=", nil, 0, false},
+		{"go=x", nil, 0, false},
+		{"go=0", nil, 0, false},
+		{"go=30,", nil, 0, false},
+	}
+	for _, tc := range testCases {
+		*fromThreshold = defaultThreshold
+		var l langThresholds
+		err := l.Set(tc.value)
+		if (err == nil) != tc.ok {
+			t.Errorf("Set(%q): error %v, want ok %v", tc.value, err, tc.ok)
+			continue
+		}
+		if !tc.ok {
+			continue
+		}
+		if !reflect.DeepEqual(l, tc.want) {
+			t.Errorf("Set(%q) = %v, want %v", tc.value, l, tc.want)
+		}
+		from := tc.from
+		if from == 0 {
+			from = defaultThreshold
+		}
+		if *fromThreshold != from {
+			t.Errorf("Set(%q): -from-threshold %d, want %d", tc.value, *fromThreshold, from)
+		}
+	}
+}
+
+func TestPathThresholdListSet(t *testing.T) {
+	testCases := []struct {
+		value string
+		want  pathThreshold
+		ok    bool
+	}{
+		{"backend:30", pathThreshold{"backend", 30}, true},
+		{"./backend/:30", pathThreshold{"backend", 30}, true},
+		{"c:/src:30", pathThreshold{filepath.Clean("c:/src"), 30}, true},
+		{".:20", pathThreshold{".", 20}, true},
+		{"backend", pathThreshold{}, false},
+		{":30", pathThreshold{}, false},
+		{"backend:", pathThreshold{}, false},
+		{"backend:0", pathThreshold{}, false},
+		{"backend:x", pathThreshold{}, false},
+	}
+	for _, tc := range testCases {
+		var l pathThresholdList
+		err := l.Set(tc.value)
+		if (err == nil) != tc.ok {
+			t.Errorf("Set(%q): error %v, want ok %v", tc.value, err, tc.ok)
+			continue
+		}
+		if tc.ok && (len(l) != 1 || l[0] != tc.want) {
+			t.Errorf("Set(%q) = %v, want [%v]", tc.value, l, tc.want)
+		}
+	}
+}
+
+func TestFileThreshold(t *testing.T) {
+	defer func(l langThresholds, p pathThresholdList, from, to int) {
+		thresholds, pathThresholds, *fromThreshold, *toThreshold = l, p, from, to
+	}(thresholds, pathThresholds, *fromThreshold, *toThreshold)
+	*fromThreshold, *toThreshold = 15, 15
+	thresholds = langThresholds{"go": 30, "js": 50}
+	pathThresholds = nil
+	for _, value := range []string{"clients:100", "clients/core:50", "backend:20"} {
+		if err := pathThresholds.Set(value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		file string
+		want int
+	}{
+		// The longest path containing the file applies.
+		{"clients/core/a.go", 50},
+		{"clients/api/a.go", 100},
+		{"clients/a.js", 100},
+		{"backend/a.go", 20},
+		// By whole path elements.
+		{"backend2/a.go", 30},
+		{"clients-old/a.go", 30},
+		// Outside the paths, the threshold of the language.
+		{"a.go", 30},
+		{"web/a.js", 50},
+		{"<stdin>", 30},
+		// Or else -from-threshold.
+		{"a.py", 15},
+	}
+	for _, tc := range testCases {
+		if got := fileThreshold(filepath.FromSlash(tc.file)); got != tc.want {
+			t.Errorf("fileThreshold(%q) = %d, want %d", tc.file, got, tc.want)
+		}
+	}
+
+	from, to := thresholdRange([]string{"a.py", filepath.FromSlash("clients/core/a.go"), "a.go"})
+	if from != 15 || to != 50 {
+		t.Errorf("thresholdRange = %d, %d, want 15, 50", from, to)
+	}
+	*toThreshold = 80
+	if from, to := thresholdRange([]string{"a.go"}); from != 30 || to != 80 {
+		t.Errorf("thresholdRange with -to-threshold 80 = %d, %d, want 30, 80", from, to)
+	}
+
+	g := &printer.Group{Frags: []printer.Fragment{
+		{Filename: "a.go"},
+		{Filename: filepath.FromSlash("clients/core/b.go")},
+		{Filename: filepath.FromSlash("backend/c.go")},
+	}}
+	if got := groupThreshold(g); got != 50 {
+		t.Errorf("groupThreshold = %d, want 50", got)
+	}
+
+	// A path of the whole tree applies to the files outside the others.
+	if err := pathThresholds.Set(".:40"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fileThreshold("a.py"); got != 40 {
+		t.Errorf("with .:40, fileThreshold(%q) = %d, want 40", "a.py", got)
+	}
+	if got := fileThreshold(filepath.FromSlash("backend/a.go")); got != 20 {
+		t.Errorf("with .:40, fileThreshold(%q) = %d, want 20", "backend/a.go", got)
+	}
+}
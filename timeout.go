@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// timeoutStatus is the exit status of a scan aborted by -timeout.
+const timeoutStatus = 3
+
+var errTimeout = errors.New("scan timed out")
+
+// errStopped is returned by the crawling once the scan is stopped.
+var errStopped = errors.New("scan stopped")
+
+// aborted is closed when the scan runs out of time. Without -timeout
+// it is nil, and so never ready.
+var aborted chan struct{}
+
+// scanStop is closed to stop the goroutines of the scan: the crawling,
+// the parsing and the search for the matches. Each of them stops
+// sending, but keeps draining what it receives, so that the stages
+// before it end too.
+var (
+	scanStop     = make(chan struct{})
+	scanStopOnce sync.Once
+)
+
+// stopScan stops the scan, when it runs out of time or has collected
+// as many clones as it is allowed to report.
+func stopScan() {
+	scanStopOnce.Do(func() { close(scanStop) })
+}
+
+// stopped reports whether the scan is stopped.
+func stopped() bool {
+	select {
+	case <-scanStop:
+		return true
+	default:
+		return false
+	}
+}
+
+// startTimeout arranges for aborted to be closed, and the scan stopped,
+// after d, unless d is not positive.
+func startTimeout(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	aborted = make(chan struct{})
+	time.AfterFunc(d, func() {
+		close(aborted)
+		stopScan()
+	})
+}
+
+// timedOut reports whether the scan has run out of time.
+func timedOut() bool {
+	select {
+	case <-aborted:
+		return true
+	default:
+		return false
+	}
+}
+
+// exitTimeout reports the timeout and exits with timeoutStatus.
+func exitTimeout(closeOutput func()) {
+	closeOutput()
+	stopProfiling()
+	log.Printf("%v after %v; the results are incomplete", errTimeout, *timeout)
+	os.Exit(timeoutStatus)
+}
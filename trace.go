@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/mibk/dupl/printer"
+	"github.com/mibk/dupl/syntax"
+)
+
+// tracef logs the explanation of a decision about a match
+// if requested by -verbose-matches.
+func tracef(format string, v ...interface{}) {
+	if *verboseMatches {
+		log.Printf(format, v...)
+	}
+}
+
+// reject explains why the group is not reported and returns false.
+func reject(g *printer.Group, reason string) (bool, error) {
+	if *verboseMatches {
+		var pos []string
+		for _, frag := range g.Frags {
+			pos = append(pos, lines.position(frag.Filename, frag.Pos))
+		}
+		tracef("group at %s rejected: %s", strings.Join(pos, ", "), reason)
+	}
+	return false, nil
+}
+
+// fragPositions describes the positions of the fragments.
+func fragPositions(frags [][]*syntax.Node) string {
+	var pos []string
+	for _, seq := range frags {
+		pos = append(pos, lines.position(seq[0].Filename, seq[0].Pos))
+	}
+	return strings.Join(pos, ", ")
+}
+
+// lines resolves positions for tracing, reading each file only once.
+var lines = &lineCache{files: make(map[string][]byte)}
+
+type lineCache struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func (c *lineCache) position(filename string, offset int) string {
+	c.mu.Lock()
+	file, ok := c.files[filename]
+	if !ok {
+		file, _ = readFile(filename)
+		c.files[filename] = file
+	}
+	c.mu.Unlock()
+	if offset > len(file) {
+		return fmt.Sprintf("%s:#%d", filename, offset)
+	}
+	return fmt.Sprintf("%s:%d", filename, bytes.Count(file[:offset], []byte{'\n'})+1)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/mibk/dupl/printer"
+)
+
+// vendorPairKey returns the key pairing a file with its counterparts
+// in or out of the vendor directory: the name of its directory and
+// its own name, e.g. bar/x.go for both vendor/example.com/bar/x.go and
+// internal/bar/x.go.
+func vendorPairKey(file string) string {
+	return filepath.Join(filepath.Base(filepath.Dir(file)), filepath.Base(file))
+}
+
+// keepVendorPairs removes the fragments of the group that are not
+// in a file paired with a file of another fragment, one of them
+// vendored and the other not.
+func keepVendorPairs(g *printer.Group) {
+	vendored := make(map[string]bool)
+	own := make(map[string]bool)
+	for _, frag := range g.Frags {
+		if isVendored(frag.Filename) {
+			vendored[vendorPairKey(frag.Filename)] = true
+		} else {
+			own[vendorPairKey(frag.Filename)] = true
+		}
+	}
+	frags := g.Frags[:0]
+	for _, frag := range g.Frags {
+		if key := vendorPairKey(frag.Filename); vendored[key] && own[key] {
+			frags = append(frags, frag)
+		}
+	}
+	g.Frags = frags
+}